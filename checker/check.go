@@ -15,11 +15,12 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-// The checker package for evaluating solutions.
+// The checker package for evaluating solutions and instances.
 package checker
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -27,6 +28,17 @@ import (
 	"github.com/brennie/spaghetti/tt"
 )
 
+// The exit statuses of the check mode. A status of 1 means that the instance
+// or solution could not be read.
+const (
+	exitFeasible   = 0 // The timetable is valid and feasible.
+	exitInvalid    = 2 // The timetable violates hard constraints.
+	exitInfeasible = 3 // The timetable is valid but does not assign every event.
+)
+
+// Check a solution against its instance and print its value in the requested
+// format. With --report, every violation is listed as well. The process exits
+// with a non-zero status if the timetable is not valid or not feasible.
 func Check(opts options.CheckOptions) {
 	instFile, err := os.Open(opts.Instance)
 	if err != nil {
@@ -40,25 +52,187 @@ func Check(opts options.CheckOptions) {
 	}
 	defer solnFile.Close()
 
-	inst, err := tt.Parse(instFile)
+	inst, err := tt.ParseWithWeek(instFile, opts.Week)
 	if err != nil {
 		log.Fatalf("Could not parse %s: %s\n", opts.Instance, err.Error())
 	}
 
+	if inst.Format() != tt.PostEnrolment && opts.Week != tt.StandardWeek {
+		log.Printf("Ignoring --week: the week only applies to post-enrolment instances\n")
+	}
+
+	if opts.Extension != "" {
+		extensionFile, err := os.Open(opts.Extension)
+		if err != nil {
+			log.Fatalf("Could not %s\n", err.Error())
+		}
+		defer extensionFile.Close()
+
+		if err = inst.ReadExtension(extensionFile); err != nil {
+			log.Fatalf("Could not parse %s: %s\n", opts.Extension, err.Error())
+		}
+	}
+
+	if opts.Weights != "" {
+		weightsFile, err := os.Open(opts.Weights)
+		if err != nil {
+			log.Fatalf("Could not %s\n", err.Error())
+		}
+		defer weightsFile.Close()
+
+		weights, err := tt.ReadSoftWeights(weightsFile)
+		if err != nil {
+			log.Fatalf("Could not parse %s: %s\n", opts.Weights, err.Error())
+		}
+
+		if err = inst.SetSoftWeights(weights); err != nil {
+			log.Fatalf("Could not use %s: %s\n", opts.Weights, err.Error())
+		}
+	}
+
 	soln, err := inst.ParseSolution(solnFile)
 	if err != nil {
 		log.Fatalf("Could not parse %s: %s\n", opts.Solution, err.Error())
 	}
 
+	switch opts.Format {
+	case "json":
+		writeJSON(os.Stdout, inst, soln, opts.Report)
+
+	default:
+		writeText(os.Stdout, inst, soln, opts.Report)
+	}
+
+	if soln.Violations() > 0 {
+		os.Exit(exitInvalid)
+	} else if soln.Distance() > 0 {
+		os.Exit(exitInfeasible)
+	}
+}
+
+// Write the value of the solution as text, followed by every violation if
+// report is set.
+func writeText(w io.Writer, inst *tt.Instance, soln *tt.Solution, report bool) {
 	violations := soln.Violations()
 	distance := soln.Distance()
 	fitness := soln.Fitness()
 
-	fmt.Printf("Hard constraint violations: %d\nDistance to feasibility: %d\nSoft Constraint Violations: %d\n", violations, distance, fitness)
+	fmt.Fprintf(w, "Hard constraint violations: %d\nDistance to feasibility: %d\nSoft Constraint Violations: %d\n", violations, distance, fitness)
 
 	if violations > 0 {
-		fmt.Println("This is not a valid timetable.")
+		fmt.Fprintln(w, "This is not a valid timetable.")
 	} else if distance > 0 {
-		fmt.Println("This is not a feasible timetable.")
+		fmt.Fprintln(w, "This is not a feasible timetable.")
+	}
+
+	if report {
+		printReport(w, inst, soln.Report())
+	}
+}
+
+// Print every violation in the report, grouped by kind.
+func printReport(w io.Writer, inst *tt.Instance, r *tt.Report) {
+	if r.Curriculum != nil {
+		fmt.Fprintf(w, "\nConflicting lectures (%d):\n", len(r.Curriculum.Conflicts))
+		for _, conflict := range r.Curriculum.Conflicts {
+			fmt.Fprintf(w, "  lectures %d and %d must not be at the same time\n", conflict.EventA, conflict.EventB)
+		}
+	} else {
+		fmt.Fprintf(w, "\nStudent clashes (%d):\n", len(r.StudentClashes))
+		for _, clash := range r.StudentClashes {
+			fmt.Fprintf(w, "  student %d at time %d (%s): events %v\n", clash.Student, clash.Time, inst.FormatTime(clash.Time), clash.Events)
+		}
+	}
+
+	fmt.Fprintf(w, "\nRoom clashes (%d):\n", len(r.RatClashes))
+	for _, clash := range r.RatClashes {
+		fmt.Fprintf(w, "  room %d at time %d (%s): events %v (%d violations)\n", clash.Rat.Room, clash.Rat.Time, inst.FormatTime(clash.Rat.Time), clash.Events, clash.Violations)
+	}
+
+	fmt.Fprintf(w, "\nBroken precedence constraints (%d):\n", len(r.Precedence))
+	for _, broken := range r.Precedence {
+		fmt.Fprintf(w, "  event %d must happen before event %d\n", broken.Before, broken.After)
+	}
+
+	if r.Exam != nil {
+		fmt.Fprintf(w, "\nBroken coincidence constraints (%d):\n", len(r.Exam.Coincidence))
+		for _, broken := range r.Exam.Coincidence {
+			fmt.Fprintf(w, "  exams %d and %d must be in the same period\n", broken.EventA, broken.EventB)
+		}
+
+		fmt.Fprintf(w, "\nBroken exclusion constraints (%d):\n", len(r.Exam.Exclusion))
+		for _, broken := range r.Exam.Exclusion {
+			fmt.Fprintf(w, "  exams %d and %d must not be in the same period\n", broken.EventA, broken.EventB)
+		}
+	}
+
+	fmt.Fprintf(w, "\nUnassigned events (%d):\n", len(r.Unassigned))
+	if len(r.Unassigned) > 0 {
+		fmt.Fprintf(w, "  %v\n", r.Unassigned)
+	}
+
+	c := countReport(r)
+
+	if r.Exam != nil {
+		fmt.Fprintf(w, "\nSoft constraint penalties (%d students):\n", len(r.Exam.Students))
+		fmt.Fprintf(w, "  %-8s %8s %8s %8s %6s\n", "student", "in-a-row", "in-a-day", "spread", "total")
+		for _, penalty := range r.Exam.Students {
+			fmt.Fprintf(w, "  %-8d %8d %8d %8d %6d\n", penalty.Student, penalty.TwoInARow, penalty.TwoInADay, penalty.PeriodSpread, penalty.Total())
+		}
+		fmt.Fprintf(w, "  %-8s %8d %8d %8d %6d\n", "total", c.TwoInARow, c.TwoInADay, c.PeriodSpread, c.TwoInARow+c.TwoInADay+c.PeriodSpread)
+
+		fmt.Fprintf(w, "\nMixed durations: %d\nFront load: %d\nRoom penalties: %d\nPeriod penalties: %d\n", c.MixedDurations, c.FrontLoad, c.RoomPenalty, c.PeriodPenalty)
+		return
+	}
+
+	if r.Curriculum != nil {
+		fmt.Fprintf(w, "\nSoft constraint penalties (%d courses):\n", len(r.Curriculum.Courses))
+		fmt.Fprintf(w, "  %-12s %9s %9s %10s %6s\n", "course", "capacity", "min-days", "stability", "total")
+		for _, penalty := range r.Curriculum.Courses {
+			fmt.Fprintf(w, "  %-12s %9d %9d %10d %6d\n", penalty.Name, penalty.RoomCapacity, penalty.MinWorkingDays, penalty.RoomStability, penalty.Total())
+		}
+		fmt.Fprintf(w, "  %-12s %9d %9d %10d %6d\n", "total", c.RoomCapacity, c.MinWorkingDays, c.RoomStability, c.RoomCapacity+c.MinWorkingDays+c.RoomStability)
+
+		fmt.Fprintf(w, "\nCurriculum compactness (%d curricula):\n", len(r.Curriculum.Curricula))
+		for _, penalty := range r.Curriculum.Curricula {
+			fmt.Fprintf(w, "  %-12s %6d\n", penalty.Name, penalty.Compactness)
+		}
+		fmt.Fprintf(w, "  %-12s %6d\n", "total", c.CurriculumCompactness)
+		return
 	}
+
+	fmt.Fprintf(w, "\nSoft constraints (%d):\n", len(r.Soft))
+	for _, soft := range r.Soft {
+		fmt.Fprintf(w, "  %-24s weight %4d  penalty %6d\n", soft.Name, soft.Weight, soft.Penalty)
+	}
+
+	// There is a column for each soft constraint, wide enough for its name.
+	fmt.Fprintf(w, "\nSoft constraint penalties (%d students):\n  %-8s", len(r.Students), "student")
+	for _, soft := range r.Soft {
+		fmt.Fprintf(w, " %*s", columnWidth(soft.Name), soft.Name)
+	}
+	fmt.Fprintf(w, " %6s\n", "total")
+
+	for _, penalty := range r.Students {
+		fmt.Fprintf(w, "  %-8d", penalty.Student)
+		for _, soft := range r.Soft {
+			fmt.Fprintf(w, " %*d", columnWidth(soft.Name), penalty.Penalties[soft.Name])
+		}
+		fmt.Fprintf(w, " %6d\n", penalty.Total())
+	}
+
+	fmt.Fprintf(w, "  %-8s", "total")
+	for _, soft := range r.Soft {
+		fmt.Fprintf(w, " %*d", columnWidth(soft.Name), soft.Penalty)
+	}
+	fmt.Fprintf(w, " %6d\n", r.Value.Fitness)
+}
+
+// Determine the width of the column of a table with the given heading.
+func columnWidth(heading string) int {
+	if len(heading) < 8 {
+		return 8
+	}
+
+	return len(heading)
 }
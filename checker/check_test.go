@@ -0,0 +1,91 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Read the tiny instance, a post-enrolment instance with a 2x3 week: room 0
+// seats three students and room 1 seats one; event 0 is attended by students
+// 0 and 2, event 1 by students 0 and 1, event 2 by student 1, and event 3 by
+// student 0; and event 0 must be before event 2. Then create a solution in
+// which events 0 and 1 share room 0 and student 0 at time 3, event 2 is before
+// event 0, and event 3 is unassigned.
+func tinySolution(t *testing.T) (*tt.Instance, *tt.Solution) {
+	f, err := os.Open("testdata/tiny.tim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	inst, err := tt.ParseWithWeek(f, tt.Week{Days: 2, Periods: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return inst, inst.SolutionFromRats([]tt.Rat{{Room: 0, Time: 3}, {Room: 0, Time: 3}, {Room: 1, Time: 0}, {Room: -1, Time: -1}})
+}
+
+func TestWriteText(t *testing.T) {
+	inst, soln := tinySolution(t)
+	defer soln.Free()
+
+	var buf bytes.Buffer
+	writeText(&buf, inst, soln, false)
+
+	want := fmt.Sprintf("Hard constraint violations: 3\nDistance to feasibility: 1\nSoft Constraint Violations: %d\nThis is not a valid timetable.\n", soln.Fitness())
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextReport(t *testing.T) {
+	inst, soln := tinySolution(t)
+	defer soln.Free()
+
+	var buf bytes.Buffer
+	writeText(&buf, inst, soln, true)
+	got := buf.String()
+
+	for _, want := range []string{
+		"\nStudent clashes (1):\n  student 0 at time 3 (day 2, period 1): events [0 1]\n",
+		"\nRoom clashes (1):\n  room 0 at time 3 (day 2, period 1): events [0 1] (1 violations)\n",
+		"\nBroken precedence constraints (1):\n  event 0 must happen before event 2\n",
+		"\nUnassigned events (1):\n  [3]\n",
+		"\nSoft constraints (3):\n",
+		"\nSoft constraint penalties (",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the report does not contain %q:\n%s", want, got)
+		}
+	}
+
+	// The examination and curriculum-based sections are left out.
+	for _, unwanted := range []string{"Conflicting lectures", "coincidence", "Curriculum compactness"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("the report contains %q:\n%s", unwanted, got)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package checker
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The version of the JSON output. It changes whenever a field is removed or
+// its meaning changes; see doc/check.md.
+const schemaVersion = 2
+
+// The result of checking a solution, as written by --format json.
+type result struct {
+	Schema     int       `json:"schema"`            // The version of the schema.
+	Format     tt.Format `json:"format"`            // The format of the instance.
+	Week       string    `json:"week,omitempty"`    // The week of a course timetabling instance.
+	Violations int       `json:"violations"`        // The number of hard constraint violations.
+	Distance   int       `json:"distance"`          // The distance to feasibility.
+	Fitness    int       `json:"fitness"`           // The soft constraint penalty.
+	Valid      bool      `json:"valid"`             // Are there no hard constraint violations?
+	Feasible   bool      `json:"feasible"`          // Is the timetable valid with every event assigned?
+	Counts     counts    `json:"counts"`            // The violations and penalties of each kind of constraint.
+	Details    *details  `json:"details,omitempty"` // Every violation, if --report was given.
+}
+
+// The violations and penalties of each kind of constraint. The hard
+// constraint counts sum to the number of violations and the soft constraint
+// counts sum to the fitness. Only the counts for the format of the instance
+// are written.
+type counts struct {
+	StudentClashes int `json:"student_clashes"` // The violations from students attending events at the same time.
+	RoomClashes    int `json:"room_clashes"`    // The violations from rooms holding events they cannot.
+	Precedence     int `json:"precedence"`      // The number of broken precedence constraints.
+	Unassigned     int `json:"unassigned"`      // The number of unassigned events.
+
+	*postEnrolmentCounts
+	*examCounts
+	*curriculumCounts
+}
+
+// The counts that only apply to post-enrolment instances.
+type postEnrolmentCounts struct {
+	SoftConstraints []tt.SoftPenalty `json:"soft_constraints"` // The penalty of each enabled soft constraint.
+}
+
+// The counts that only apply to examination instances.
+type examCounts struct {
+	Coincidence    int `json:"coincidence"`     // The number of broken coincidence constraints.
+	Exclusion      int `json:"exclusion"`       // The number of broken exclusion constraints.
+	TwoInARow      int `json:"two_in_a_row"`    // The penalty for students with two exams in a row.
+	TwoInADay      int `json:"two_in_a_day"`    // The penalty for students with two exams in a day.
+	PeriodSpread   int `json:"period_spread"`   // The penalty for students with exams within the period spread.
+	MixedDurations int `json:"mixed_durations"` // The penalty for rooms holding exams of different durations.
+	FrontLoad      int `json:"front_load"`      // The penalty for large exams in the last periods.
+	RoomPenalty    int `json:"room_penalty"`    // The penalties of the rooms of the exams.
+	PeriodPenalty  int `json:"period_penalty"`  // The penalties of the periods of the exams.
+}
+
+// The counts that only apply to curriculum-based instances. The conflicts are
+// counted here rather than as student clashes.
+type curriculumCounts struct {
+	Conflicts             int `json:"conflicts"`              // The number of pairs of conflicting lectures at the same time.
+	RoomCapacity          int `json:"room_capacity"`          // The penalty for students without a seat.
+	MinWorkingDays        int `json:"min_working_days"`       // The penalty for courses spread over too few days.
+	CurriculumCompactness int `json:"curriculum_compactness"` // The penalty for isolated lectures of curricula.
+	RoomStability         int `json:"room_stability"`         // The penalty for courses using more than one room.
+}
+
+// Every violation of a solution.
+type details struct {
+	StudentClashes []tt.StudentClash        `json:"student_clashes"`
+	RoomClashes    []tt.RatClash            `json:"room_clashes"`
+	Precedence     []tt.PrecedenceViolation `json:"precedence"`
+	Unassigned     []int                    `json:"unassigned"`
+	Students       interface{}              `json:"students"` // Either []tt.StudentPenalty or []tt.ExamStudentPenalty.
+
+	*examDetails
+	*curriculumDetails
+}
+
+// The violations that only apply to examination instances.
+type examDetails struct {
+	Coincidence []tt.ConstraintPair `json:"coincidence"`
+	Exclusion   []tt.ConstraintPair `json:"exclusion"`
+}
+
+// The violations and penalties that only apply to curriculum-based instances.
+type curriculumDetails struct {
+	Conflicts []tt.ConstraintPair    `json:"conflicts"`
+	Courses   []tt.CoursePenalty     `json:"courses"`
+	Curricula []tt.CurriculumPenalty `json:"curricula"`
+}
+
+// Count the violations and penalties of each kind of constraint in the report.
+func countReport(r *tt.Report) (c counts) {
+	for _, clash := range r.StudentClashes {
+		c.StudentClashes += len(clash.Events) - 1
+	}
+
+	for _, clash := range r.RatClashes {
+		c.RoomClashes += clash.Violations
+	}
+
+	c.Precedence = len(r.Precedence)
+	c.Unassigned = len(r.Unassigned)
+
+	if r.Exam != nil {
+		c.examCounts = &examCounts{
+			len(r.Exam.Coincidence),
+			len(r.Exam.Exclusion),
+			0,
+			0,
+			0,
+			r.Exam.MixedDurations,
+			r.Exam.FrontLoad,
+			r.Exam.RoomPenalty,
+			r.Exam.PeriodPenalty,
+		}
+
+		for _, penalty := range r.Exam.Students {
+			c.TwoInARow += penalty.TwoInARow
+			c.TwoInADay += penalty.TwoInADay
+			c.PeriodSpread += penalty.PeriodSpread
+		}
+
+		return
+	}
+
+	if r.Curriculum != nil {
+		c.curriculumCounts = &curriculumCounts{len(r.Curriculum.Conflicts), 0, 0, 0, 0}
+
+		for _, penalty := range r.Curriculum.Courses {
+			c.RoomCapacity += penalty.RoomCapacity
+			c.MinWorkingDays += penalty.MinWorkingDays
+			c.RoomStability += penalty.RoomStability
+		}
+
+		for _, penalty := range r.Curriculum.Curricula {
+			c.CurriculumCompactness += penalty.Compactness
+		}
+
+		return
+	}
+
+	c.postEnrolmentCounts = &postEnrolmentCounts{r.Soft}
+
+	return
+}
+
+// Write the result of checking the solution as JSON, including every
+// violation if report is set.
+func writeJSON(w io.Writer, inst *tt.Instance, soln *tt.Solution, report bool) {
+	r := soln.Report()
+
+	res := result{
+		schemaVersion,
+		inst.Format(),
+		"",
+		r.Value.Violations,
+		r.Distance,
+		r.Value.Fitness,
+		r.Value.IsValid(),
+		r.Value.IsValid() && r.Distance == 0,
+		countReport(r),
+		nil,
+	}
+
+	if inst.Format() != tt.Examination {
+		res.Week = inst.Week().String()
+	}
+
+	if report {
+		res.Details = &details{r.StudentClashes, r.RatClashes, r.Precedence, r.Unassigned, r.Students, nil, nil}
+
+		switch {
+		case r.Exam != nil:
+			res.Details.Students = r.Exam.Students
+			res.Details.examDetails = &examDetails{r.Exam.Coincidence, r.Exam.Exclusion}
+
+		case r.Curriculum != nil:
+			res.Details.curriculumDetails = &curriculumDetails{r.Curriculum.Conflicts, r.Curriculum.Courses, r.Curriculum.Curricula}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(res); err != nil {
+		log.Fatalf("Could not write result: %s\n", err)
+	}
+}
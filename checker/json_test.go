@@ -0,0 +1,124 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package checker
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// Write the result of checking the tiny solution as JSON and decode it.
+func tinyJSON(t *testing.T, report bool) map[string]interface{} {
+	inst, soln := tinySolution(t)
+	defer soln.Free()
+
+	var buf bytes.Buffer
+	writeJSON(&buf, inst, soln, report)
+
+	var res map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatalf("%s:\n%s", err, buf.String())
+	}
+
+	return res
+}
+
+func TestWriteJSON(t *testing.T) {
+	_, soln := tinySolution(t)
+	fitness := float64(soln.Fitness())
+	soln.Free()
+
+	res := tinyJSON(t, false)
+
+	want := map[string]interface{}{
+		"schema":     float64(schemaVersion),
+		"format":     "post-enrolment",
+		"week":       "2x3",
+		"violations": 3.0,
+		"distance":   1.0,
+		"fitness":    fitness,
+		"valid":      false,
+		"feasible":   false,
+	}
+
+	for field, value := range want {
+		if res[field] != value {
+			t.Errorf("got %s %v; want %v", field, res[field], value)
+		}
+	}
+
+	if _, ok := res["details"]; ok {
+		t.Error("got details without --report")
+	}
+
+	counts := res["counts"].(map[string]interface{})
+	for field, value := range map[string]float64{"student_clashes": 1, "room_clashes": 1, "precedence": 1, "unassigned": 1} {
+		if counts[field] != value {
+			t.Errorf("got %v %s; want %g", counts[field], field, value)
+		}
+	}
+
+	// The soft constraint counts sum to the fitness, and only the counts of
+	// post-enrolment instances are written.
+	total := 0.0
+	for _, soft := range counts["soft_constraints"].([]interface{}) {
+		total += soft.(map[string]interface{})["penalty"].(float64)
+	}
+
+	if total != fitness {
+		t.Errorf("the soft constraints sum to %g; want %g", total, fitness)
+	}
+
+	for _, field := range []string{"coincidence", "two_in_a_row", "conflicts", "room_capacity"} {
+		if _, ok := counts[field]; ok {
+			t.Errorf("got the %s count for a post-enrolment instance", field)
+		}
+	}
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	details, ok := tinyJSON(t, true)["details"].(map[string]interface{})
+	if !ok {
+		t.Fatal("got no details with --report")
+	}
+
+	// The details are encoded again from maps, so their keys are sorted.
+	want := map[string]string{
+		"student_clashes": `[{"events":[0,1],"student":0,"time":3}]`,
+		"room_clashes":    `[{"events":[0,1],"rat":{"room":0,"time":3},"violations":1}]`,
+		"precedence":      `[{"after":2,"before":0}]`,
+		"unassigned":      `[3]`,
+	}
+
+	for field, value := range want {
+		if got, err := json.Marshal(details[field]); err != nil || string(got) != value {
+			t.Errorf("got %s %s; want %s", field, got, value)
+		}
+	}
+
+	if _, ok := details["students"].([]interface{}); !ok {
+		t.Errorf("got the students %v; want a list", details["students"])
+	}
+
+	for _, field := range []string{"coincidence", "exclusion", "conflicts", "courses", "curricula"} {
+		if _, ok := details[field]; ok {
+			t.Errorf("got the %s details for a post-enrolment instance", field)
+		}
+	}
+}
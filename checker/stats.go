@@ -0,0 +1,162 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The version of the JSON output of the stats mode; see doc/stats.md.
+const statsSchemaVersion = 1
+
+// The statistics of an instance, as written by --format json.
+type statsResult struct {
+	Schema int `json:"schema"` // The version of the schema.
+	*tt.Stats
+	Week               string  `json:"week,omitempty"`       // The week of a course timetabling instance.
+	UtilisationBound   float64 `json:"utilisation_bound"`    // The share of every room that must be used.
+	TightestRoomsBound float64 `json:"tightest_rooms_bound"` // The share of the tightest rooms that must be used.
+
+	Infeasible []tt.Infeasibility `json:"infeasible"` // The proofs that the instance has no feasible solution.
+	Values     int                `json:"values"`     // The number of rooms and times in the events' domains.
+	Reducible  int                `json:"reducible"`  // The number of them that preprocessing removes.
+}
+
+// Print the statistics of an instance in the requested format.
+func Stats(opts options.StatsOptions) {
+	instFile, err := os.Open(opts.Instance)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err.Error())
+	}
+	defer instFile.Close()
+
+	inst, err := tt.ParseWithWeek(instFile, opts.Week)
+	if err != nil {
+		log.Fatalf("Could not parse %s: %s\n", opts.Instance, err.Error())
+	}
+
+	if inst.Format() != tt.PostEnrolment && opts.Week != tt.StandardWeek {
+		log.Printf("Ignoring --week: the week only applies to post-enrolment instances\n")
+	}
+
+	r := statsResult{
+		Schema:     statsSchemaVersion,
+		Stats:      inst.Stats(),
+		Infeasible: inst.Diagnose(),
+	}
+
+	r.UtilisationBound = r.Utilisation.Utilisation()
+	r.TightestRoomsBound = r.TightestRooms.Utilisation()
+
+	if inst.Format() != tt.Examination {
+		r.Week = inst.Week().String()
+	}
+
+	for _, domain := range inst.Domains {
+		r.Values += len(domain)
+	}
+
+	// The domains of an instance that has no feasible solution can be reduced
+	// to nothing, so they are only reduced if no reason was found.
+	if len(r.Infeasible) == 0 {
+		r.Infeasible = []tt.Infeasibility{}
+		r.Reducible = inst.ReduceDomains()
+	}
+
+	switch opts.Format {
+	case "json":
+		writeStatsJSON(os.Stdout, r)
+
+	default:
+		writeStatsText(os.Stdout, inst, r)
+	}
+}
+
+// Write the statistics as JSON.
+func writeStatsJSON(w io.Writer, r statsResult) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(r); err != nil {
+		log.Fatalf("Could not write JSON: %s\n", err)
+	}
+}
+
+// Write the statistics as text.
+func writeStatsText(w io.Writer, inst *tt.Instance, r statsResult) {
+	stats := r.Stats
+
+	fmt.Fprintf(w, "Format: %s\nEvents: %d\nRooms: %d\n", stats.Format, stats.NEvents, stats.NRooms)
+	if stats.Format == tt.Examination {
+		fmt.Fprintf(w, "Periods: %d\n", stats.NTimes)
+	} else {
+		fmt.Fprintf(w, "Times: %d (a %s week)\n", stats.NTimes, inst.Week())
+	}
+	if stats.Format == tt.CurriculumBased {
+		fmt.Fprintf(w, "Curricula: %d\n", stats.NStudents)
+	} else {
+		fmt.Fprintf(w, "Students: %d\n", stats.NStudents)
+	}
+
+	fmt.Fprintf(w, "\nDomain sizes: %s\n", formatDistribution(stats.DomainSizes))
+	fmt.Fprintf(w, "Events with empty domains (%d): %v\n", len(stats.EmptyDomains), stats.EmptyDomains)
+	fmt.Fprintf(w, "Events with a single room and time (%d): %v\n", len(stats.SingletonDomains), stats.SingletonDomains)
+
+	fmt.Fprintf(w, "\nConflict graph: %d edges, density %.4f\n", stats.Conflicts, stats.Density)
+	fmt.Fprintf(w, "Degrees: %s\n", formatDistribution(stats.Degrees))
+	fmt.Fprintf(w, "Largest clique found (%d events): %v\n", len(stats.Clique), stats.Clique)
+	times := "times"
+	if stats.Format == tt.Examination {
+		times = "periods"
+	}
+
+	fmt.Fprintf(w, "At least %d of the %d %s are needed", len(stats.Clique), stats.NTimes, times)
+	if len(stats.Clique) > stats.NTimes {
+		fmt.Fprintf(w, ", so the instance has no feasible solution")
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "\nOrdered pairs of events: %d\nLongest chain of ordered events: %d\n", stats.PrecedencePairs, stats.LongestChain)
+	if len(stats.Cyclic) > 0 {
+		fmt.Fprintf(w, "Events on or after a cycle of ordered events (%d): %v\n", len(stats.Cyclic), stats.Cyclic)
+	}
+
+	fmt.Fprintf(w, "\nRoom utilisation of every room: at least %.1f%% (%d of %d)\n", 100*stats.Utilisation.Utilisation(), stats.Utilisation.Load, stats.Utilisation.Capacity)
+	fmt.Fprintf(w, "Tightest rooms %v: at least %.1f%% (%d of %d, from %d events)\n", stats.TightestRooms.Rooms, 100*stats.TightestRooms.Utilisation(), stats.TightestRooms.Load, stats.TightestRooms.Capacity, stats.TightestRooms.NEvents)
+
+	if len(r.Infeasible) > 0 {
+		fmt.Fprintf(w, "\nThe instance has no feasible solution (%d reasons):\n", len(r.Infeasible))
+		for _, reason := range r.Infeasible {
+			fmt.Fprintf(w, "  %s\n", reason)
+		}
+	} else {
+		fmt.Fprintf(w, "\nPreprocessing removes %d of the %d rooms and times in the events' domains\n", r.Reducible, r.Values)
+	}
+}
+
+// Format a distribution on a single line.
+func formatDistribution(d tt.Distribution) string {
+	return fmt.Sprintf("min %d, quartiles %d/%d/%d, max %d, mean %.1f", d.Min, d.Q1, d.Median, d.Q3, d.Max, d.Mean)
+}
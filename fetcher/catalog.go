@@ -0,0 +1,127 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"fmt"
+)
+
+// An instance file of a dataset.
+type Instance struct {
+	File   string // The name of the file.
+	Size   int64  // The size of the file in bytes, or 0 if it is not known.
+	SHA256 string // The SHA-256 checksum of the file in hex, or "" if it is not known.
+	Best   int    // The best known soft constraint penalty of a feasible solution, or -1 if it is not known.
+}
+
+// A set of instances that spaghetti knows about.
+type Dataset struct {
+	Name        string     // The name of the dataset.
+	Description string     // A description of the dataset.
+	URL         string     // The URL of each file, with %s for its name, or "" if the dataset can only be imported.
+	Instances   []Instance // The instances of the dataset.
+}
+
+// The datasets that spaghetti knows about. An instance is only installed if its
+// file matches its size and checksum. Instances whose size and checksum are not
+// known cannot be verified, so they are only installed when that is asked for.
+var Catalog = []Dataset{
+	{
+		"itc2007-post-enrolment",
+		"ITC2007 track 2: post-enrolment course timetabling",
+		"http://www.cs.qub.ac.uk/itc2007/postenrolcourse/initialdatasets/%s",
+		numbered("comp-2007-2-%d.tim", 1, 24),
+	},
+	{
+		"itc2007-examination",
+		"ITC2007 track 1: examination timetabling",
+		"",
+		numbered("exam_comp_set%d.exam", 1, 12),
+	},
+	{
+		"itc2007-curriculum-based",
+		"ITC2007 track 3: curriculum-based course timetabling",
+		"",
+		numbered("comp%02d.ctt", 1, 21),
+	},
+}
+
+// Create the instances of a dataset whose files are numbered from first to
+// last, none of whose sizes, checksums, or best known values are known.
+func numbered(format string, first, last int) (instances []Instance) {
+	for i := first; i <= last; i++ {
+		instances = append(instances, Instance{fmt.Sprintf(format, i), 0, "", -1})
+	}
+
+	return
+}
+
+// Find the dataset and instance with the given file name, or return nil if no
+// dataset has the file.
+func findInstance(file string) (*Dataset, *Instance) {
+	for i := range Catalog {
+		for j := range Catalog[i].Instances {
+			if Catalog[i].Instances[j].File == file {
+				return &Catalog[i], &Catalog[i].Instances[j]
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// Determine the best known soft constraint penalty of the instance with the
+// given file name, if it is in the catalog and the penalty is known.
+func BestKnown(file string) (int, bool) {
+	if _, inst := findInstance(file); inst != nil && inst.Best >= 0 {
+		return inst.Best, true
+	}
+
+	return 0, false
+}
+
+// Determine if the size and checksum of the instance are known, so that its
+// file can be verified.
+func (inst *Instance) verifiable() bool {
+	return inst.Size != 0 && inst.SHA256 != ""
+}
+
+// Determine if the file with the given name may be installed: a file of the
+// catalog that cannot be verified is only installed if unverified files are
+// allowed.
+func checkInstallable(file string, unverified bool) error {
+	if _, inst := findInstance(file); inst != nil && !inst.verifiable() && !unverified {
+		return fmt.Errorf("%s cannot be verified, since its size and checksum are not in the catalog", file)
+	}
+
+	return nil
+}
+
+// Check a file against the size and checksum of the instance, where they are
+// known.
+func (inst *Instance) verify(size int64, sum string) error {
+	if inst.Size != 0 && size != inst.Size {
+		return fmt.Errorf("%s has %d bytes; expected %d", inst.File, size, inst.Size)
+	}
+
+	if inst.SHA256 != "" && sum != inst.SHA256 {
+		return fmt.Errorf("%s has the SHA-256 checksum %s; expected %s", inst.File, sum, inst.SHA256)
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The configuration of a download of the datasets in the catalog.
+type DownloadConfig struct {
+	BaseURL    string        // The URL of a mirror holding every file, or "" to use the URL of each dataset.
+	Jobs       int           // The number of files downloaded at once.
+	Retries    int           // The number of times a failed download is retried.
+	Backoff    time.Duration // The wait before the first retry, which doubles with each retry after it.
+	Client     *http.Client  // The client to download with, or nil for http.DefaultClient.
+	Unverified bool          // Whether to download instances that cannot be verified.
+}
+
+// The default configuration.
+func DefaultDownloadConfig() DownloadConfig {
+	return DownloadConfig{
+		Jobs:    4,
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+// Determine if the configuration is usable.
+func (cfg DownloadConfig) validate() error {
+	switch {
+	case cfg.Jobs < 1:
+		return fmt.Errorf("there must be at least 1 download at once, not %d", cfg.Jobs)
+
+	case cfg.Retries < 0:
+		return fmt.Errorf("the number of retries must be non-negative, not %d", cfg.Retries)
+
+	case cfg.Backoff < 0:
+		return fmt.Errorf("the backoff must be non-negative, not %s", cfg.Backoff)
+	}
+
+	return nil
+}
+
+// The outcome of downloading an instance.
+type Downloaded struct {
+	File    string // The name of the file.
+	Size    int64  // The size of the file in bytes.
+	Skipped bool   // Whether the file was already installed and unchanged, so was not downloaded.
+}
+
+// An error that may go away if the download is retried.
+type transientError struct {
+	err error
+}
+
+func (err transientError) Error() string {
+	return err.err.Error()
+}
+
+// Download every dataset in the catalog that has a URL into the directory,
+// which is created if it does not exist. Files that are already installed and
+// unchanged are skipped, and files that cannot be verified are not downloaded
+// unless cfg.Unverified is true. The instances downloaded or skipped are returned in
+// the order of the catalog; if some could not be downloaded, the others still
+// are, and the error is a *FilesError.
+func (cfg DownloadConfig) Download(ctx context.Context, directory string) ([]Downloaded, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+
+	m, err := readManifest(directory)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the manifest of %s: %s", directory, err)
+	}
+
+	var urls, files []string
+	for _, dataset := range Catalog {
+		if dataset.URL == "" {
+			continue
+		}
+
+		for _, inst := range dataset.Instances {
+			url := fmt.Sprintf(dataset.URL, inst.File)
+			if cfg.BaseURL != "" {
+				url = strings.TrimSuffix(cfg.BaseURL, "/") + "/" + inst.File
+			}
+
+			urls = append(urls, url)
+			files = append(files, inst.File)
+		}
+	}
+
+	results := make([]Downloaded, len(files))
+	errs := make([]error, len(files))
+	done := make([]bool, len(files))
+
+	// The manifest is shared by the workers.
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+
+	for worker := 0; worker < cfg.Jobs; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				// Copy the record, since other workers may move it.
+				var old *installed
+				mutex.Lock()
+				if record := m.find(files[i]); record != nil {
+					copied := *record
+					old = &copied
+				}
+				mutex.Unlock()
+
+				if installedUnchanged(directory, files[i], old) {
+					results[i] = Downloaded{files[i], old.Size, true}
+					done[i] = true
+					continue
+				}
+
+				if err := checkInstallable(files[i], cfg.Unverified); err != nil {
+					errs[i] = err
+					continue
+				}
+
+				record, err := cfg.downloadFile(ctx, directory, files[i], urls[i])
+				if err != nil {
+					errs[i] = fmt.Errorf("%s: %s", urls[i], err)
+					continue
+				}
+
+				mutex.Lock()
+				m.record(record)
+				mutex.Unlock()
+
+				results[i] = Downloaded{files[i], record.Size, false}
+				done[i] = true
+			}
+		}()
+	}
+
+	for i := range files {
+		if ctx.Err() != nil {
+			errs[i] = fmt.Errorf("%s: %s", urls[i], ctx.Err())
+			continue
+		}
+
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var downloaded []Downloaded
+	var failed []error
+	for i := range files {
+		if done[i] {
+			downloaded = append(downloaded, results[i])
+		} else {
+			failed = append(failed, errs[i])
+		}
+	}
+
+	// Record what was downloaded even if some files failed.
+	if err := m.write(directory); err != nil {
+		return downloaded, fmt.Errorf("could not write the manifest of %s: %s", directory, err)
+	}
+
+	if len(failed) > 0 {
+		return downloaded, &FilesError{"download", failed}
+	}
+
+	return downloaded, nil
+}
+
+// Determine if the file was installed and has not changed since, and matches
+// the catalog.
+func installedUnchanged(directory, file string, record *installed) bool {
+	if record == nil {
+		return false
+	}
+
+	size, sum, err := checksum(filepath.Join(directory, file))
+	if err != nil || size != record.Size || sum != record.SHA256 {
+		return false
+	}
+
+	_, inst := findInstance(file)
+
+	return inst == nil || inst.verify(size, sum) == nil
+}
+
+// Download a file into the directory, retrying with exponential backoff while
+// the failures may be transient.
+func (cfg DownloadConfig) downloadFile(ctx context.Context, directory, file, url string) (record installed, err error) {
+	backoff := cfg.Backoff
+
+	for attempt := 0; ; attempt++ {
+		record, err = cfg.tryDownload(ctx, directory, file, url)
+
+		if _, transient := err.(transientError); !transient || attempt == cfg.Retries {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return record, ctx.Err()
+
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// Download a file into the directory once. Failures of the network, server
+// errors, and files that do not match the catalog (which may have been cut
+// short) are transient.
+func (cfg DownloadConfig) tryDownload(ctx context.Context, directory, file, url string) (installed, error) {
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return installed{}, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return installed{}, ctx.Err()
+		}
+
+		return installed{}, transientError{err}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		return installed{}, transientError{fmt.Errorf("got HTTP %s", resp.Status)}
+
+	default:
+		return installed{}, fmt.Errorf("got HTTP %s; expected HTTP 200 OK", resp.Status)
+	}
+
+	record, err := install(directory, file, url, resp.Body)
+	if err != nil && ctx.Err() == nil {
+		return record, transientError{err}
+	}
+
+	return record, err
+}
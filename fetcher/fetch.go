@@ -15,60 +15,79 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-// The fetcher package for fetching instances from the web.
+// The fetcher package for fetching instances from the web or importing them
+// from local files, and keeping track of the instances installed.
 package fetcher
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
+	"strings"
 
 	"github.com/brennie/spaghetti/options"
 )
 
-const (
-	nInstances = 24
-	baseFile   = "comp-2007-2-%d.tim"
-	baseUrl    = "http://www.cs.qub.ac.uk/itc2007/postenrolcourse/initialdatasets/comp-2007-2-%d.tim"
-)
-
-// Fetch the instances and store them in the given directory, or defaultDir if
-// that is nil.
+// Fetch the instances and store them in the given directory, import them from
+// a local directory or archive, or list the instances installed, as requested.
 func Fetch(opts options.FetchOptions) {
-	err := os.Mkdir(opts.Directory, 0700)
-	if err == nil || os.IsExist(err) {
-		if err := os.Chdir(opts.Directory); err != nil {
-			log.Fatal(err)
+	switch {
+	case opts.List:
+		if err := List(os.Stdout, opts.Directory); err != nil {
+			log.Fatalf("Could not list the instances in %s: %s\n", opts.Directory, err)
 		}
 
-		for i := 1; i <= nInstances; i++ {
-			filename := fmt.Sprintf(baseFile, i)
-			url := fmt.Sprintf(baseUrl, i)
+	case opts.From != "":
+		files, err := Import(opts.From, opts.Directory, opts.Unverified)
+		for _, file := range files {
+			log.Printf("Imported %s%c%s\n", opts.Directory, os.PathSeparator, file)
+		}
 
-			file, err := os.Create(filename)
-			if err != nil {
-				log.Fatal(err)
-			}
+		if err != nil {
+			log.Fatalf("Could not import instances from %s: %s\n", opts.From, err)
+		} else if len(files) == 0 {
+			log.Fatalf("Found no instances in %s\n", opts.From)
+		}
+
+	default:
+		cfg := DefaultDownloadConfig()
+		cfg.BaseURL = opts.Mirror
+		cfg.Jobs = opts.Jobs
+		cfg.Unverified = opts.Unverified
 
-			resp, err := http.Get(url)
-			if err != nil {
-				log.Fatal(err)
+		for _, dataset := range Catalog {
+			if dataset.URL == "" {
+				log.Printf("Not downloading %s: it can only be imported with --from\n", dataset.Name)
 			}
+		}
 
-			if resp.StatusCode != 200 {
-				log.Fatalf("Got HTTP %s; expected HTTP 200 OK for %s", resp.StatusCode, url)
+		downloaded, err := cfg.Download(context.Background(), opts.Directory)
+		for _, d := range downloaded {
+			if d.Skipped {
+				log.Printf("Skipped %s%c%s instance: it is already installed\n", opts.Directory, os.PathSeparator, d.File)
+			} else {
+				log.Printf("Downloaded %s%c%s instance (%d bytes)\n", opts.Directory, os.PathSeparator, d.File, d.Size)
 			}
+		}
 
-			io.Copy(file, resp.Body)
+		if err != nil {
+			log.Fatalf("Could not download the instances: %s\n", err)
+		}
+	}
+}
 
-			resp.Body.Close()
-			file.Close()
+// The error for files that could not be downloaded or imported.
+type FilesError struct {
+	Action string  // What was done to the files.
+	Errors []error // The error for each file.
+}
 
-			log.Printf("Downloaded %s%c%s instance (%d bytes)\n", opts.Directory, os.PathSeparator, filename, resp.ContentLength)
-		}
-	} else {
-		log.Fatal(err)
+func (err *FilesError) Error() string {
+	msgs := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		msgs[i] = e.Error()
 	}
+
+	return fmt.Sprintf("could not %s %d files: %s", err.Action, len(err.Errors), strings.Join(msgs, "; "))
 }
@@ -0,0 +1,408 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The files of the test sources: two instances in the catalog, one that is
+// not, and a file that is not an instance.
+var sourceFiles = map[string]string{
+	"track2/comp-2007-2-1.tim": "first instance\n",
+	"track2/comp-2007-2-2.tim": "second instance\n",
+	"random.tim":               "random instance\n",
+	"README":                   "not an instance\n",
+}
+
+// Write the source files as a directory tree.
+func writeDirectory(t *testing.T, directory string) {
+	for name, contents := range sourceFiles {
+		path := filepath.Join(directory, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Write the source files as a tar archive, compressed with gzip.
+func writeTarGz(t *testing.T, archive string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, contents := range sourceFiles {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(contents)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		tw.Write([]byte(contents))
+	}
+
+	tw.Close()
+	gz.Close()
+
+	if err := os.WriteFile(archive, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Write the source files as a zip archive.
+func writeZip(t *testing.T, archive string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, contents := range sourceFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write([]byte(contents))
+	}
+
+	zw.Close()
+
+	if err := os.WriteFile(archive, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Determine the SHA-256 checksum of a string in hex.
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestImport(t *testing.T) {
+	tmp := t.TempDir()
+
+	sources := map[string]func(t *testing.T, source string){
+		"tree":       writeDirectory,
+		"set.tgz":    writeTarGz,
+		"set.zip":    writeZip,
+		"set.tar.gz": writeTarGz,
+	}
+
+	for name, write := range sources {
+		source := filepath.Join(tmp, name)
+		write(t, source)
+
+		directory := filepath.Join(tmp, "instances-"+name)
+		files, err := Import(source, directory, true)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		sort.Strings(files)
+		if got := fmt.Sprint(files); got != "[comp-2007-2-1.tim comp-2007-2-2.tim random.tim]" {
+			t.Errorf("%s: imported %s", name, got)
+		}
+
+		data, err := os.ReadFile(filepath.Join(directory, "comp-2007-2-2.tim"))
+		if err != nil || string(data) != "second instance\n" {
+			t.Errorf("%s: comp-2007-2-2.tim has %q (%v); want its contents", name, data, err)
+		}
+
+		m, err := readManifest(directory)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(m.Instances) != 3 {
+			t.Fatalf("%s: the manifest has %d instances; want 3", name, len(m.Instances))
+		}
+
+		want := installed{"comp-2007-2-1.tim", "itc2007-post-enrolment", 15, sha256Hex("first instance\n"), source}
+		if m.Instances[0] != want {
+			t.Errorf("%s: recorded %+v; want %+v", name, m.Instances[0], want)
+		}
+
+		if m.Instances[2].Dataset != "" {
+			t.Errorf("%s: random.tim was recorded as part of %s", name, m.Instances[2].Dataset)
+		}
+	}
+}
+
+func TestImportChecksum(t *testing.T) {
+	_, inst := findInstance("comp-2007-2-1.tim")
+	defer func(size int64, sum string) { inst.Size, inst.SHA256 = size, sum }(inst.Size, inst.SHA256)
+	inst.Size, inst.SHA256 = 24, sha256Hex("the real first instance\n")
+
+	tmp := t.TempDir()
+	source := filepath.Join(tmp, "tree")
+	directory := filepath.Join(tmp, "instances")
+	writeDirectory(t, source)
+
+	files, err := Import(source, directory, true)
+	if _, ok := err.(*FilesError); !ok {
+		t.Fatalf("got the error %v; want a *FilesError", err)
+	} else if !strings.Contains(err.Error(), "comp-2007-2-1.tim") {
+		t.Errorf("the error %q does not name the file", err)
+	}
+
+	if len(files) != 2 {
+		t.Errorf("imported %v; want the other two instances", files)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "comp-2007-2-1.tim")); !os.IsNotExist(err) {
+		t.Error("the file that does not match the catalog was kept")
+	}
+}
+
+func TestList(t *testing.T) {
+	tmp := t.TempDir()
+	source := filepath.Join(tmp, "set.zip")
+	directory := filepath.Join(tmp, "instances")
+	writeZip(t, source)
+
+	if _, err := Import(source, directory, true); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(directory, "comp-2007-2-2.tim"), []byte("edited\n"), 0600)
+	os.Remove(filepath.Join(directory, "random.tim"))
+	os.WriteFile(filepath.Join(directory, "comp01.ctt"), []byte("copied by hand\n"), 0600)
+
+	var buf bytes.Buffer
+	if err := List(&buf, directory); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+	find := func(prefix string) string {
+		for _, line := range lines {
+			if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+				return line
+			}
+		}
+
+		t.Errorf("the listing has no line for %s:\n%s", prefix, buf.String())
+		return ""
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"itc2007-post-enrolment", "2 of 24 installed"},
+		{"itc2007-curriculum-based", "1 of 21 installed"},
+		{"comp-2007-2-1.tim", statusUnverified},
+		{"comp-2007-2-2.tim", statusModified},
+		{"comp01.ctt", statusUnknown},
+		{"random.tim", statusMissing},
+	}
+
+	for _, test := range tests {
+		if line := find(test.prefix); !strings.HasSuffix(strings.TrimSpace(line), test.want) {
+			t.Errorf("got %q for %s; want it to end with %q", line, test.prefix, test.want)
+		}
+	}
+}
+
+func TestUnverified(t *testing.T) {
+	// Only the first instance can be verified.
+	_, inst := findInstance("comp-2007-2-1.tim")
+	defer func(size int64, sum string) { inst.Size, inst.SHA256 = size, sum }(inst.Size, inst.SHA256)
+	inst.Size, inst.SHA256 = 15, sha256Hex("first instance\n")
+
+	tmp := t.TempDir()
+	source := filepath.Join(tmp, "tree")
+	directory := filepath.Join(tmp, "instances")
+	writeDirectory(t, source)
+
+	files, err := Import(source, directory, false)
+	if fe, ok := err.(*FilesError); !ok || len(fe.Errors) != 1 || !strings.Contains(fe.Error(), "comp-2007-2-2.tim cannot be verified") {
+		t.Errorf("got the error %v; want a *FilesError for comp-2007-2-2.tim alone", err)
+	}
+
+	sort.Strings(files)
+	if got := fmt.Sprint(files); got != "[comp-2007-2-1.tim random.tim]" {
+		t.Errorf("imported %s; want the instance that can be verified and the one that is not in the catalog", got)
+	}
+
+	var buf bytes.Buffer
+	if err := List(&buf, directory); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), statusVerified) {
+		t.Errorf("the listing does not show the verified instance:\n%s", buf.String())
+	}
+
+	// Nothing that cannot be verified is downloaded.
+	inst.Size, inst.SHA256 = 0, ""
+	m := &mirror{requests: make(map[string]int)}
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	cfg := DefaultDownloadConfig()
+	cfg.BaseURL = server.URL
+
+	downloaded, err := cfg.Download(context.Background(), t.TempDir())
+	if fe, ok := err.(*FilesError); !ok || len(fe.Errors) != 24 {
+		t.Errorf("got the error %v; want a *FilesError for all 24 instances", err)
+	}
+
+	if len(downloaded) != 0 || len(m.requests) != 0 {
+		t.Errorf("downloaded %v after %d requests; want nothing", downloaded, len(m.requests))
+	}
+}
+
+// A mirror of the post-enrolment dataset that is missing one file and fails
+// the first requests for another.
+type mirror struct {
+	mutex    sync.Mutex
+	requests map[string]int // The number of requests for each file.
+	missing  string         // The file the mirror does not have.
+	flaky    string         // The file whose first requests fail.
+	failures int            // The number of requests for it that fail.
+}
+
+func (m *mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file := strings.TrimPrefix(r.URL.Path, "/")
+
+	m.mutex.Lock()
+	m.requests[file]++
+	n := m.requests[file]
+	m.mutex.Unlock()
+
+	switch {
+	case file == m.missing:
+		http.NotFound(w, r)
+
+	case file == m.flaky && n <= m.failures:
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+
+	default:
+		fmt.Fprintf(w, "the instance %s\n", file)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	m := &mirror{
+		requests: make(map[string]int),
+		missing:  "comp-2007-2-24.tim",
+		flaky:    "comp-2007-2-3.tim",
+		failures: 2,
+	}
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	directory := t.TempDir()
+	cfg := DefaultDownloadConfig()
+	cfg.BaseURL = server.URL
+	cfg.Backoff = time.Millisecond
+	cfg.Unverified = true
+
+	downloaded, err := cfg.Download(context.Background(), directory)
+	if fe, ok := err.(*FilesError); !ok || len(fe.Errors) != 1 || !strings.Contains(fe.Error(), m.missing) {
+		t.Errorf("got the error %v; want a *FilesError for %s alone", err, m.missing)
+	}
+
+	if len(downloaded) != 23 {
+		t.Fatalf("downloaded %d files; want 23", len(downloaded))
+	}
+
+	if m.requests[m.flaky] != 3 || m.requests[m.missing] != 1 {
+		t.Errorf("requested %s %d times and %s %d times; want 3 and 1", m.flaky, m.requests[m.flaky], m.missing, m.requests[m.missing])
+	}
+
+	data, err := os.ReadFile(filepath.Join(directory, m.flaky))
+	if err != nil || string(data) != "the instance "+m.flaky+"\n" {
+		t.Errorf("%s has %q (%v); want its contents", m.flaky, data, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, m.missing)); !os.IsNotExist(err) {
+		t.Errorf("a file was left for %s", m.missing)
+	}
+
+	// Downloading again skips every file that is installed and unchanged, and
+	// replaces those that have changed.
+	os.WriteFile(filepath.Join(directory, "comp-2007-2-1.tim"), []byte("edited\n"), 0600)
+	m.missing = ""
+
+	downloaded, err = cfg.Download(context.Background(), directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nSkipped := 0
+	for _, d := range downloaded {
+		if d.Skipped {
+			nSkipped++
+		} else if d.File != "comp-2007-2-1.tim" && d.File != "comp-2007-2-24.tim" {
+			t.Errorf("downloaded %s again", d.File)
+		}
+	}
+
+	if nSkipped != 22 || len(downloaded) != 24 {
+		t.Errorf("skipped %d of %d files; want 22 of 24", nSkipped, len(downloaded))
+	}
+
+	if entries, _ := os.ReadDir(directory); len(entries) != 25 {
+		t.Errorf("the directory has %d entries; want the 24 instances and the manifest", len(entries))
+	}
+}
+
+func TestDownloadFailures(t *testing.T) {
+	m := &mirror{requests: make(map[string]int), flaky: "comp-2007-2-1.tim", failures: 10}
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	cfg := DefaultDownloadConfig()
+	cfg.BaseURL = server.URL
+	cfg.Backoff = time.Millisecond
+	cfg.Retries = 2
+	cfg.Jobs = 1
+	cfg.Unverified = true
+
+	_, err := cfg.Download(context.Background(), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got the error %v; want one for HTTP 503", err)
+	}
+
+	if m.requests[m.flaky] != 3 {
+		t.Errorf("requested %s %d times; want 3", m.flaky, m.requests[m.flaky])
+	}
+
+	cfg.Jobs = 0
+	if _, err := cfg.Download(context.Background(), t.TempDir()); err == nil {
+		t.Error("a download with no jobs did not fail")
+	}
+}
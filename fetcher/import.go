@@ -0,0 +1,211 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The extensions of instance files.
+var instanceExtensions = []string{".tim", ".exam", ".ctt"}
+
+// Determine if the file with the given name is an instance, and so should be
+// imported: it must be in the catalog or have the extension of an instance.
+func IsInstance(file string) bool {
+	if _, inst := findInstance(file); inst != nil {
+		return true
+	}
+
+	for _, ext := range instanceExtensions {
+		if strings.HasSuffix(file, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Import the instances in a directory, a tar archive (which may be compressed
+// with gzip), or a zip archive into the given directory, which is created if
+// it does not exist. Instance files are found anywhere in the source and are
+// copied to the top of the directory. Instances of the catalog that cannot be
+// verified are only imported if unverified is true. The names of the files
+// imported are returned; if some files could not be imported, the others still
+// are, and the error is a *FilesError.
+func Import(source, directory string, unverified bool) (files []string, err error) {
+	if _, err = os.Stat(source); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(directory, 0700); err != nil {
+		return
+	}
+
+	m, err := readManifest(directory)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the manifest of %s: %s", directory, err)
+	}
+
+	var failed []error
+
+	// Install one file of the source.
+	add := func(name string, r io.Reader) {
+		file := path.Base(filepath.ToSlash(name))
+		if !IsInstance(file) {
+			return
+		}
+
+		if err := checkInstallable(file, unverified); err != nil {
+			failed = append(failed, fmt.Errorf("%s: %s", name, err))
+		} else if record, err := install(directory, file, source, r); err != nil {
+			failed = append(failed, fmt.Errorf("%s: %s", name, err))
+		} else {
+			m.record(record)
+			files = append(files, file)
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(source, ".zip"):
+		err = importZip(source, add)
+
+	case strings.HasSuffix(source, ".tar"):
+		err = importTar(source, false, add)
+
+	case strings.HasSuffix(source, ".tar.gz"), strings.HasSuffix(source, ".tgz"):
+		err = importTar(source, true, add)
+
+	default:
+		err = importDirectory(source, directory, add)
+	}
+
+	// Record what was imported even if the source could not be read to the
+	// end.
+	if writeErr := m.write(directory); err == nil {
+		err = writeErr
+	}
+
+	if err == nil && len(failed) > 0 {
+		err = &FilesError{"import", failed}
+	}
+
+	return
+}
+
+// Pass each regular file in the directory tree to add, except those already
+// in the destination directory.
+func importDirectory(directory, destination string, add func(name string, r io.Reader)) error {
+	info, err := os.Stat(directory)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory, a tar archive, or a zip archive", directory)
+	}
+
+	destination, err = filepath.Abs(destination)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(directory, func(name string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		if abs, err := filepath.Abs(name); err != nil || filepath.Dir(abs) == destination {
+			return err
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		add(name, file)
+
+		return nil
+	})
+}
+
+// Pass each regular file in the tar archive to add.
+func importTar(archive string, gzipped bool, add func(name string, r io.Reader)) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %s", archive, err)
+		}
+		defer gz.Close()
+
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %s", archive, err)
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			add(header.Name, tr)
+		}
+	}
+}
+
+// Pass each regular file in the zip archive to add.
+func importZip(archive string, add func(name string, r io.Reader)) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %s", archive, err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("could not read %s from %s: %s", f.Name, archive, err)
+		}
+
+		add(f.Name, r)
+		r.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+// The state of an instance in a directory.
+const (
+	statusVerified   = "verified"                        // It matches the checksum in the catalog.
+	statusOK         = "ok"                              // It is unchanged since it was installed.
+	statusUnverified = "unverified"                      // It is unchanged since it was installed, but the catalog has no checksum for it.
+	statusUnknown    = "not in the manifest"             // It was not installed by spaghetti.
+	statusModified   = "modified since it was installed" // It has changed since it was installed.
+	statusCorrupt    = "does not match the catalog"      // It does not match the size or checksum in the catalog.
+	statusMissing    = "missing"                         // It was installed but has since been removed.
+)
+
+// Determine the state of an instance in the directory, given its entry in the
+// catalog (or nil) and its record in the manifest (or nil). An instance that
+// is not there and was never installed has no state.
+func status(directory, file string, inst *Instance, record *installed) (size int64, state string, err error) {
+	size, sum, err := checksum(filepath.Join(directory, file))
+	if os.IsNotExist(err) {
+		if record != nil {
+			state = statusMissing
+		}
+
+		return 0, state, nil
+	} else if err != nil {
+		return
+	}
+
+	switch {
+	case inst != nil && inst.verify(size, sum) != nil:
+		state = statusCorrupt
+
+	case record == nil:
+		state = statusUnknown
+
+	case record.Size != size || record.SHA256 != sum:
+		state = statusModified
+
+	case inst != nil && inst.verifiable():
+		state = statusVerified
+
+	case inst != nil:
+		state = statusUnverified
+
+	default:
+		state = statusOK
+	}
+
+	return
+}
+
+// Write the instances of each dataset in the catalog that are in the
+// directory, and those of no dataset that were installed in it, along with
+// their sizes, best known values, and whether they have changed.
+func List(w io.Writer, directory string) error {
+	m, err := readManifest(directory)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	for i := range Catalog {
+		dataset := &Catalog[i]
+
+		var lines []string
+		nInstalled := 0
+
+		for j := range dataset.Instances {
+			inst := &dataset.Instances[j]
+
+			size, state, err := status(directory, inst.File, inst, m.find(inst.File))
+			if err != nil {
+				return err
+			} else if state == "" {
+				continue
+			}
+
+			best := "unknown"
+			if inst.Best >= 0 {
+				best = strconv.Itoa(inst.Best)
+			}
+
+			if state != statusMissing {
+				nInstalled++
+			}
+
+			lines = append(lines, fmt.Sprintf("  %s\t%d bytes\tbest %s\t%s\n", inst.File, size, best, state))
+		}
+
+		fmt.Fprintf(tw, "%s (%s): %d of %d installed\n", dataset.Name, dataset.Description, nInstalled, len(dataset.Instances))
+		for _, line := range lines {
+			fmt.Fprint(tw, line)
+		}
+	}
+
+	var lines []string
+	for _, record := range m.Instances {
+		if record.Dataset != "" {
+			continue
+		}
+
+		size, state, err := status(directory, record.File, nil, &record)
+		if err != nil {
+			return err
+		}
+
+		lines = append(lines, fmt.Sprintf("  %s\t%d bytes\tfrom %s\t%s\n", record.File, size, record.Source, state))
+	}
+
+	if len(lines) > 0 {
+		fmt.Fprintf(tw, "other instances (%d)\n", len(lines))
+		for _, line := range lines {
+			fmt.Fprint(tw, line)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,157 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The file in an instance directory that records the instances installed in
+// it.
+const manifestFile = "manifest.json"
+
+// An instance installed in a directory.
+type installed struct {
+	File    string `json:"file"`              // The name of the file.
+	Dataset string `json:"dataset,omitempty"` // The dataset of the instance, if it is in the catalog.
+	Size    int64  `json:"size"`              // The size of the file in bytes.
+	SHA256  string `json:"sha256"`            // The SHA-256 checksum of the file in hex.
+	Source  string `json:"source"`            // The URL, directory, or archive it was installed from.
+}
+
+// The record of the instances installed in a directory.
+type manifest struct {
+	Instances []installed `json:"instances"` // The instances, sorted by file name.
+}
+
+// Read the manifest of the given directory. A directory without one has an
+// empty manifest.
+func readManifest(directory string) (*manifest, error) {
+	m := &manifest{[]installed{}}
+
+	file, err := os.Open(filepath.Join(directory, manifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Write the manifest to the given directory.
+func (m *manifest) write(directory string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(directory, manifestFile), append(data, '\n'), 0666)
+}
+
+// Find the record of the given file, or return nil if it has none.
+func (m *manifest) find(file string) *installed {
+	for i := range m.Instances {
+		if m.Instances[i].File == file {
+			return &m.Instances[i]
+		}
+	}
+
+	return nil
+}
+
+// Record an installed instance, replacing any earlier record of its file.
+func (m *manifest) record(inst installed) {
+	if old := m.find(inst.File); old != nil {
+		*old = inst
+		return
+	}
+
+	m.Instances = append(m.Instances, inst)
+	sort.Slice(m.Instances, func(i, j int) bool {
+		return m.Instances[i].File < m.Instances[j].File
+	})
+}
+
+// Copy an instance into the directory and check it against the catalog,
+// returning its record for the manifest. The instance is written to a
+// temporary file that is only renamed once it is complete and matches the
+// catalog, so an existing file is never left half-written.
+func install(directory, file, source string, r io.Reader) (record installed, err error) {
+	tmp, err := os.CreateTemp(directory, "."+file+".*.tmp")
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return
+	}
+
+	record = installed{file, "", size, hex.EncodeToString(hash.Sum(nil)), source}
+
+	if dataset, inst := findInstance(file); inst != nil {
+		if err = inst.verify(record.Size, record.SHA256); err != nil {
+			return
+		}
+
+		record.Dataset = dataset.Name
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(directory, file))
+
+	return
+}
+
+// Compute the size and SHA-256 checksum of a file.
+func checksum(path string) (size int64, sum string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if size, err = io.Copy(hash, file); err != nil {
+		return
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,102 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package generator
+
+import (
+	"io"
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Generate a random instance as given in the options and write it to the
+// instance file, along with the planted solution, if there is one, to the
+// solution file. The planted solution is checked against the instance before
+// returning.
+func Generate(opts options.GenerateOptions) {
+	plant, err := ParsePlant(opts.Plant)
+	if err != nil {
+		log.Fatalf("Invalid value for --plant: %s\n", opts.Plant)
+	}
+
+	params := DefaultParams()
+	params.NEvents = opts.NEvents
+	params.NRooms = opts.NRooms
+	params.NFeatures = opts.NFeatures
+	params.NStudents = opts.NStudents
+	params.Week = opts.Week
+	params.Attendance = opts.Attendance
+	params.MinCapacity = opts.MinCapacity
+	params.MaxCapacity = opts.MaxCapacity
+	params.Unavailable = opts.Unavailable
+	params.Precedence = opts.Precedence
+	params.Plant = plant
+
+	gen, err := New(params, rand.New(rand.NewSource(opts.Seed)))
+	if err != nil {
+		log.Fatalf("Could not generate an instance: %s\n", err)
+	}
+
+	writeFile(opts.Instance, gen.WriteInstance)
+	log.Printf("Wrote a %d event instance with a %s week to %s (seed %d)\n", params.NEvents, params.Week, opts.Instance, opts.Seed)
+
+	if plant == PlantNone {
+		return
+	}
+
+	writeFile(opts.Solution, gen.WriteSolution)
+
+	instFile, err := os.Open(opts.Instance)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+	defer instFile.Close()
+
+	inst, err := tt.ParseWithWeek(instFile, params.Week)
+	if err != nil {
+		log.Fatalf("Could not parse %s: %s\n", opts.Instance, err)
+	}
+
+	soln := inst.SolutionFromRats(gen.Planted())
+	defer soln.Free()
+
+	if soln.Violations() > 0 || soln.Distance() > 0 || (plant == PlantPerfect && soln.Fitness() > 0) {
+		log.Fatalf("The planted solution is not %s: it has value %s\n", plant, soln.Value())
+	}
+
+	log.Printf("Wrote the planted %s solution to %s\n", plant, opts.Solution)
+}
+
+// Create the named file and write to it with the given function.
+func writeFile(fileName string, write func(w io.Writer) error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+
+	if err = write(f); err != nil {
+		log.Fatalf("Could not write %s: %s\n", fileName, err)
+	}
+
+	if err = f.Close(); err != nil {
+		log.Fatalf("Could not write %s: %s\n", fileName, err)
+	}
+}
@@ -0,0 +1,456 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// The generator package for creating random post-enrolment instances.
+package generator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The kind of solution planted in a generated instance.
+type Plant int
+
+const (
+	PlantNone     Plant = iota // Nothing is planted.
+	PlantFeasible              // A solution without hard constraint violations is planted.
+	PlantPerfect               // A feasible solution without soft constraint penalties is planted.
+)
+
+// The parameters of a generated instance.
+type Params struct {
+	NEvents   int     // The number of events.
+	NRooms    int     // The number of rooms.
+	NFeatures int     // The number of room features.
+	NStudents int     // The number of students.
+	Week      tt.Week // The week of the instance.
+
+	Attendance  float64 // The probability that a student attends an event.
+	MinCapacity int     // The smallest room capacity.
+	MaxCapacity int     // The largest room capacity.
+	Unavailable float64 // The probability that an event cannot be at a time.
+	Precedence  float64 // The probability that a pair of events is ordered.
+
+	RoomFeatures  float64 // The probability that a room has a feature.
+	EventFeatures float64 // The probability that an event requires a feature.
+
+	Plant Plant // The kind of solution to plant.
+}
+
+// A generated post-enrolment instance.
+type Instance struct {
+	params Params
+
+	capacities    []int    // The capacity of each room.
+	attends       [][]bool // Whether each student attends each event.
+	roomFeatures  [][]bool // Whether each room has each feature.
+	eventFeatures [][]bool // Whether each event requires each feature.
+	available     [][]bool // Whether each event can be at each time.
+	precedence    [][]int  // Whether each event is before (1) or after (-1) each other event.
+	planted       []tt.Rat // The planted solution, if any.
+}
+
+// Create the default parameters, which give an instance about a quarter of the
+// size of the ITC2007 instances with nothing planted.
+func DefaultParams() Params {
+	return Params{
+		NEvents:       100,
+		NRooms:        5,
+		NFeatures:     5,
+		NStudents:     100,
+		Week:          tt.StandardWeek,
+		Attendance:    0.05,
+		MinCapacity:   10,
+		MaxCapacity:   50,
+		Unavailable:   0.1,
+		Precedence:    0.005,
+		RoomFeatures:  0.5,
+		EventFeatures: 0.1,
+		Plant:         PlantNone,
+	}
+}
+
+// Determine the name of a kind of planted solution.
+func (p Plant) String() string {
+	switch p {
+	case PlantNone:
+		return "none"
+
+	case PlantFeasible:
+		return "feasible"
+
+	case PlantPerfect:
+		return "perfect"
+	}
+
+	return fmt.Sprintf("Plant(%d)", int(p))
+}
+
+// Determine the kind of planted solution with the given name.
+func ParsePlant(name string) (Plant, error) {
+	for _, p := range []Plant{PlantNone, PlantFeasible, PlantPerfect} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+
+	return PlantNone, fmt.Errorf("unknown kind of planted solution %q", name)
+}
+
+// Check that the parameters describe an instance that can be generated.
+func (p Params) validate() error {
+	switch {
+	case p.NEvents < 1 || p.NRooms < 1:
+		return errors.New("there must be at least one event and room")
+
+	case p.NFeatures < 0 || p.NStudents < 0:
+		return errors.New("the numbers of features and students must not be negative")
+
+	case p.Week.Days < 1 || p.Week.Periods < 1:
+		return fmt.Errorf("invalid week %s: expected at least one day and period", p.Week)
+
+	case p.MinCapacity < 1 || p.MaxCapacity < p.MinCapacity:
+		return fmt.Errorf("invalid room capacities %d to %d", p.MinCapacity, p.MaxCapacity)
+	}
+
+	probabilities := []struct {
+		name  string
+		value float64
+	}{
+		{"attendance", p.Attendance},
+		{"unavailability", p.Unavailable},
+		{"precedence", p.Precedence},
+		{"room feature", p.RoomFeatures},
+		{"event feature", p.EventFeatures},
+	}
+
+	for _, prob := range probabilities {
+		if prob.value < 0 || prob.value > 1 {
+			return fmt.Errorf("the %s probability must be between 0 and 1; got %g", prob.name, prob.value)
+		}
+	}
+
+	switch p.Plant {
+	case PlantNone:
+		break
+
+	case PlantFeasible:
+		if p.NEvents > p.NRooms*p.Week.NTimes() {
+			return fmt.Errorf("cannot plant a feasible solution: %d events do not fit in %d rooms at %d times", p.NEvents, p.NRooms, p.Week.NTimes())
+		}
+
+	case PlantPerfect:
+		if nTimes := p.Week.NTimes() - p.Week.Days; p.NEvents > p.NRooms*nTimes {
+			return fmt.Errorf("cannot plant a perfect solution: %d events do not fit in %d rooms at the %d times that are not the last period of a day", p.NEvents, p.NRooms, nTimes)
+		}
+
+	default:
+		return fmt.Errorf("unknown kind of planted solution %s", p.Plant)
+	}
+
+	return nil
+}
+
+// Create a random instance with the given parameters.
+//
+// If a solution is planted, the instance is built around it: every event is
+// given its own time and room first, and then students, features, the
+// availability of events, and precedence constraints are only added where they
+// keep the planted solution feasible. A perfect solution also never uses the
+// last period of a day, and no student has a single class on a day or more than
+// two classes in a row. Without a planted solution, the instance may have no
+// feasible solution at all.
+func New(params Params, rng *rand.Rand) (*Instance, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+
+	inst := &Instance{params: params}
+
+	inst.capacities = make([]int, params.NRooms)
+	for room := range inst.capacities {
+		inst.capacities[room] = params.MinCapacity + rng.Intn(params.MaxCapacity-params.MinCapacity+1)
+	}
+
+	inst.roomFeatures = randomMatrix(rng, params.NRooms, params.NFeatures, params.RoomFeatures)
+
+	if params.Plant != PlantNone {
+		inst.plant(rng)
+	}
+
+	inst.eventFeatures = make([][]bool, params.NEvents)
+	for event := range inst.eventFeatures {
+		inst.eventFeatures[event] = make([]bool, params.NFeatures)
+
+		for feature := range inst.eventFeatures[event] {
+			// A planted event can only require the features of its room.
+			if inst.planted != nil && !inst.roomFeatures[inst.planted[event].Room][feature] {
+				continue
+			}
+
+			inst.eventFeatures[event][feature] = rng.Float64() < params.EventFeatures
+		}
+	}
+
+	inst.enrol(rng)
+
+	inst.available = randomMatrix(rng, params.NEvents, params.Week.NTimes(), 1-params.Unavailable)
+	for event, rat := range inst.planted {
+		inst.available[event][rat.Time] = true
+	}
+
+	inst.precedence = make([][]int, params.NEvents)
+	for event := range inst.precedence {
+		inst.precedence[event] = make([]int, params.NEvents)
+	}
+
+	for first := 0; first < params.NEvents; first++ {
+		for second := first + 1; second < params.NEvents; second++ {
+			if rng.Float64() >= params.Precedence {
+				continue
+			}
+
+			before, after := first, second
+			if inst.planted != nil {
+				// Planted events are ordered by their times, and events at the
+				// same time cannot be ordered at all.
+				if inst.planted[first].Time == inst.planted[second].Time {
+					continue
+				} else if inst.planted[first].Time > inst.planted[second].Time {
+					before, after = second, first
+				}
+			}
+
+			inst.precedence[before][after] = 1
+			inst.precedence[after][before] = -1
+		}
+	}
+
+	return inst, nil
+}
+
+// Create a matrix of the given size where each entry is set with the given
+// probability.
+func randomMatrix(rng *rand.Rand, rows, columns int, p float64) [][]bool {
+	matrix := make([][]bool, rows)
+
+	for row := range matrix {
+		matrix[row] = make([]bool, columns)
+
+		for column := range matrix[row] {
+			matrix[row][column] = rng.Float64() < p
+		}
+	}
+
+	return matrix
+}
+
+// Give every event its own random time and room in the planted solution.
+func (inst *Instance) plant(rng *rand.Rand) {
+	week := inst.params.Week
+
+	var rats []tt.Rat
+	for room := 0; room < inst.params.NRooms; room++ {
+		for time := 0; time < week.NTimes(); time++ {
+			if inst.params.Plant == PlantPerfect && time%week.Periods == week.Periods-1 {
+				continue
+			}
+
+			rats = append(rats, tt.Rat{Room: room, Time: time})
+		}
+	}
+
+	inst.planted = make([]tt.Rat, inst.params.NEvents)
+	for event, index := range rng.Perm(len(rats))[:inst.params.NEvents] {
+		inst.planted[event] = rats[index]
+	}
+}
+
+// Decide which events each student attends. Each student attends each event
+// with the attendance probability, except where that would break the planted
+// solution.
+func (inst *Instance) enrol(rng *rand.Rand) {
+	week := inst.params.Week
+
+	inst.attends = make([][]bool, inst.params.NStudents)
+
+	// The number of students attending each event.
+	sizes := make([]int, inst.params.NEvents)
+
+	for student := range inst.attends {
+		inst.attends[student] = make([]bool, inst.params.NEvents)
+
+		if inst.planted == nil {
+			for event := range inst.attends[student] {
+				if rng.Float64() < inst.params.Attendance {
+					inst.attends[student][event] = true
+					sizes[event]++
+				}
+			}
+
+			continue
+		}
+
+		// The event the student attends at each time, or -1.
+		busy := make([]int, week.NTimes())
+		for time := range busy {
+			busy[time] = -1
+		}
+
+		// Determine if the student can attend an event without clashing,
+		// overfilling its room, or, for a perfect solution, having more than
+		// two classes in a row.
+		canAttend := func(event int) bool {
+			rat := inst.planted[event]
+
+			if busy[rat.Time] != -1 || sizes[event] >= inst.capacities[rat.Room] {
+				return false
+			}
+
+			if inst.params.Plant == PlantPerfect {
+				start := rat.Time - rat.Time%week.Periods
+				run := 1
+
+				for time := rat.Time - 1; time >= start && busy[time] != -1; time-- {
+					run++
+				}
+
+				for time := rat.Time + 1; time < start+week.Periods && busy[time] != -1; time++ {
+					run++
+				}
+
+				return run <= 2
+			}
+
+			return true
+		}
+
+		attend := func(event int) {
+			inst.attends[student][event] = true
+			busy[inst.planted[event].Time] = event
+			sizes[event]++
+		}
+
+		for event := range inst.attends[student] {
+			if rng.Float64() < inst.params.Attendance && canAttend(event) {
+				attend(event)
+			}
+		}
+
+		if inst.params.Plant != PlantPerfect {
+			continue
+		}
+
+		// A student with a single class on a day either gets a second class
+		// on that day or loses the first.
+		for day := 0; day < week.Days; day++ {
+			start := day * week.Periods
+
+			single, nClasses := -1, 0
+			for _, event := range busy[start : start+week.Periods] {
+				if event != -1 {
+					single = event
+					nClasses++
+				}
+			}
+
+			if nClasses != 1 {
+				continue
+			}
+
+			found := false
+			for _, event := range rng.Perm(inst.params.NEvents) {
+				if time := inst.planted[event].Time; time >= start && time < start+week.Periods && canAttend(event) {
+					attend(event)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				inst.attends[student][single] = false
+				busy[inst.planted[single].Time] = -1
+				sizes[single]--
+			}
+		}
+	}
+}
+
+// Determine the planted solution, which is nil if nothing was planted. The
+// returned slice must not be modified.
+func (inst *Instance) Planted() []tt.Rat {
+	return inst.planted
+}
+
+// Write the instance in the ITC2007 post-enrolment format, which tt.Parse
+// reads.
+func (inst *Instance) WriteInstance(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	p := inst.params
+
+	writeMatrix := func(matrix [][]bool) {
+		for _, row := range matrix {
+			for _, set := range row {
+				if set {
+					bw.WriteString("1\n")
+				} else {
+					bw.WriteString("0\n")
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(bw, "%d %d %d %d\n", p.NEvents, p.NRooms, p.NFeatures, p.NStudents)
+
+	for _, capacity := range inst.capacities {
+		fmt.Fprintf(bw, "%d\n", capacity)
+	}
+
+	writeMatrix(inst.attends)
+	writeMatrix(inst.roomFeatures)
+	writeMatrix(inst.eventFeatures)
+	writeMatrix(inst.available)
+
+	for _, row := range inst.precedence {
+		for _, order := range row {
+			fmt.Fprintf(bw, "%d\n", order)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Write the planted solution in the ITC2007 post-enrolment format, with the
+// time and room of each event on its own line.
+func (inst *Instance) WriteSolution(w io.Writer) error {
+	if inst.planted == nil {
+		return errors.New("no solution was planted")
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, rat := range inst.planted {
+		fmt.Fprintf(bw, "%d %d\n", rat.Time, rat.Room)
+	}
+
+	return bw.Flush()
+}
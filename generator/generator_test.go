@@ -0,0 +1,154 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package generator
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Generate an instance and parse it, along with its planted solution if there
+// is one.
+func generate(t *testing.T, params Params, seed int64) (*tt.Instance, *tt.Solution) {
+	gen, err := New(params, rand.New(rand.NewSource(seed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = gen.WriteInstance(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	inst, err := tt.ParseWithWeek(&buf, params.Week)
+	if err != nil {
+		t.Fatalf("could not parse the generated instance: %s", err)
+	}
+
+	if params.Plant == PlantNone {
+		if gen.Planted() != nil {
+			t.Error("a solution was planted in an instance without one")
+		}
+
+		return inst, nil
+	}
+
+	buf.Reset()
+	if err = gen.WriteSolution(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	soln, err := inst.ParseSolution(&buf)
+	if err != nil {
+		t.Fatalf("could not parse the planted solution: %s", err)
+	}
+
+	return inst, soln
+}
+
+func TestGenerate(t *testing.T) {
+	params := DefaultParams()
+	params.NEvents = 40
+	params.NStudents = 30
+	params.Week = tt.Week{Days: 3, Periods: 4}
+	params.Precedence = 0.05
+
+	inst, _ := generate(t, params, 1)
+	if inst.NEvents() != 40 || inst.Week() != params.Week {
+		t.Errorf("got %d events and a %s week; want 40 events and a %s week", inst.NEvents(), inst.Week(), params.Week)
+	}
+
+	// The same seed always gives the same instance.
+	var first, second bytes.Buffer
+	for _, buf := range []*bytes.Buffer{&first, &second} {
+		gen, err := New(params, rand.New(rand.NewSource(2)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gen.WriteInstance(buf)
+	}
+
+	if first.String() != second.String() {
+		t.Error("two instances generated with the same seed differ")
+	}
+}
+
+func TestPlant(t *testing.T) {
+	weeks := []tt.Week{tt.StandardWeek, {Days: 2, Periods: 3}, {Days: 7, Periods: 12}}
+
+	for _, plant := range []Plant{PlantFeasible, PlantPerfect} {
+		for _, week := range weeks {
+			for seed := int64(0); seed < 5; seed++ {
+				params := DefaultParams()
+				params.NEvents = 3 * week.NTimes() / 2
+				params.NStudents = 60
+				params.Week = week
+				params.Attendance = 0.2
+				params.MaxCapacity = 15
+				params.Unavailable = 0.5
+				params.Precedence = 0.1
+				params.Plant = plant
+
+				_, soln := generate(t, params, seed)
+
+				if soln.Violations() > 0 || soln.Distance() > 0 {
+					t.Errorf("%s, %s week, seed %d: the planted solution has value %s; want a feasible solution", plant, week, seed, soln.Value())
+				} else if plant == PlantPerfect && soln.Fitness() > 0 {
+					t.Errorf("%s, %s week, seed %d: the planted solution has value %s; want a perfect solution", plant, week, seed, soln.Value())
+				}
+
+				soln.Free()
+			}
+		}
+	}
+}
+
+func TestInvalidParams(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(p *Params)
+	}{
+		{"no events", func(p *Params) { p.NEvents = 0 }},
+		{"negative students", func(p *Params) { p.NStudents = -1 }},
+		{"invalid week", func(p *Params) { p.Week = tt.Week{Days: 0, Periods: 9} }},
+		{"no capacity", func(p *Params) { p.MinCapacity = 0 }},
+		{"inverted capacities", func(p *Params) { p.MinCapacity, p.MaxCapacity = 20, 10 }},
+		{"attendance above 1", func(p *Params) { p.Attendance = 1.5 }},
+		{"negative precedence", func(p *Params) { p.Precedence = -0.1 }},
+		{"too many events to plant", func(p *Params) { p.NEvents, p.Plant = 5*45+1, PlantFeasible }},
+		{"too many events to plant perfectly", func(p *Params) { p.NEvents, p.Plant = 5*40+1, PlantPerfect }},
+		{"unknown plant", func(p *Params) { p.Plant = Plant(7) }},
+	}
+
+	for _, test := range tests {
+		params := DefaultParams()
+		test.edit(&params)
+
+		if _, err := New(params, rand.New(rand.NewSource(1))); err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+	}
+
+	if _, err := ParsePlant("optimal"); err == nil {
+		t.Error("ParsePlant accepted an unknown kind of planted solution")
+	}
+}
@@ -15,9 +15,30 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package hpga
+// The testutil package holds the fixtures shared by the tests of the solvers.
+package testutil
 
-const (
-	pMutate = 5  // The probability of a mutation is 5%
-	pLocal  = 75 // The probability of a local crossover is 75%
+import (
+	_ "embed"
+	"strings"
+	"testing"
+
+	"github.com/brennie/spaghetti/tt"
 )
+
+// A small post-enrolment instance built by the generator package around a
+// planted valid solution, from its default parameters with 40 events and 40
+// students and a generator seeded with 1.
+//
+//go:embed testdata/planted.tim
+var planted string
+
+// Parse the small instance with a planted valid solution.
+func PlantedInstance(tb testing.TB) *tt.Instance {
+	inst, err := tt.Parse(strings.NewReader(planted))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return inst
+}
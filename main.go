@@ -23,6 +23,7 @@ import (
 
 	"github.com/brennie/spaghetti/checker"
 	"github.com/brennie/spaghetti/fetcher"
+	"github.com/brennie/spaghetti/generator"
 	"github.com/brennie/spaghetti/options"
 	"github.com/brennie/spaghetti/solver"
 )
@@ -34,13 +35,25 @@ func main() {
 	opts := options.Parse()
 
 	switch opts.Mode() {
+	case options.BenchMode:
+		solver.Bench(opts.(options.BenchOptions))
+
 	case options.CheckMode:
 		checker.Check(opts.(options.CheckOptions))
 
 	case options.FetchMode:
 		fetcher.Fetch(opts.(options.FetchOptions))
 
+	case options.GenerateMode:
+		generator.Generate(opts.(options.GenerateOptions))
+
 	case options.SolveMode:
 		solver.Solve(opts.(options.SolveOptions))
+
+	case options.StatsMode:
+		checker.Stats(opts.(options.StatsOptions))
+
+	case options.TuneMode:
+		solver.Tune(opts.(options.TuneOptions))
 	}
 }
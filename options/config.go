@@ -0,0 +1,341 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package options
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The settings of a solver run, as read from and written to configuration
+// files. Each setting that has a solve option has the name of that option;
+// the rest can only be set in a configuration file. See doc/config.md.
+type SolveConfig struct {
+	Algorithm string `json:"algorithm"` // The optimization algorithm.
+	Timeout   int    `json:"timeout"`   // The timeout in minutes.
+	Ideal     bool   `json:"ideal"`     // Whether to stop at an ideal solution rather than a valid one.
+	Seed      int64  `json:"seed"`      // The seed for the random number generator.
+
+	Islands       int  `json:"islands"`       // The number of islands.
+	Slaves        int  `json:"slaves"`        // The number of slaves per island.
+	MinPop        int  `json:"minpop"`        // The minimum population of each slave.
+	MaxPop        int  `json:"maxpop"`        // The maximum population of each slave.
+	Deterministic bool `json:"deterministic"` // Whether the HPGA schedules its goroutines in a fixed order.
+
+	Anneal          int    `json:"anneal"`           // The number of annealing moves slaves make on each new individual.
+	Schedule        string `json:"schedule"`         // The annealing cooling schedule.
+	ViolationWeight int    `json:"violation-weight"` // The weight of a violation relative to fitness when annealing.
+
+	Mutation       int     `json:"mutation"`        // The probability, in percent, that a slave's step is a mutation.
+	LocalCrossover int     `json:"local-crossover"` // The probability, in percent, that a slave's step is a local crossover.
+	MaxMutate      float64 `json:"max-mutate"`      // The largest share of an individual's events that a mutation changes.
+	HCTries        int     `json:"hc-tries"`        // The number of hill climbing moves made to weight the events.
+	HCCutOff       int     `json:"hc-cutoff"`       // The number of hill climbing moves made from each random solution.
+	GMRatio        float64 `json:"gm-ratio"`        // The share of the minimum population the GM operator generates.
+}
+
+// The settings that are also solve options, and whether each is a flag
+// rather than an option with a value.
+var configOptions = map[string]bool{
+	"algorithm":        false,
+	"timeout":          false,
+	"ideal":            true,
+	"seed":             false,
+	"islands":          false,
+	"slaves":           false,
+	"minpop":           false,
+	"maxpop":           false,
+	"deterministic":    true,
+	"anneal":           false,
+	"schedule":         false,
+	"violation-weight": false,
+}
+
+// Determine the configuration of a solver run.
+func (o SolveOptions) Config() SolveConfig {
+	return SolveConfig{
+		Algorithm:       o.Algorithm,
+		Timeout:         o.Timeout,
+		Ideal:           o.Ideal,
+		Seed:            o.Seed,
+		Islands:         o.NIslands,
+		Slaves:          o.NSlaves,
+		MinPop:          o.MinPop,
+		MaxPop:          o.MaxPop,
+		Deterministic:   o.Deterministic,
+		Anneal:          o.AnnealSteps,
+		Schedule:        o.Schedule,
+		ViolationWeight: o.ViolationWeight,
+		Mutation:        o.PMutate,
+		LocalCrossover:  o.PLocal,
+		MaxMutate:       o.MaxMutate,
+		HCTries:         o.HCTries,
+		HCCutOff:        o.HCCutOff,
+		GMRatio:         o.GMRatio,
+	}
+}
+
+// Write the configuration as JSON, which can be read back with --config.
+func (c SolveConfig) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// Read a configuration file, which is JSON if its name ends with .json and
+// YAML otherwise. The settings it gives are returned both by name and as a
+// configuration; settings it does not give are zero in the configuration.
+func readConfig(fileName string) (settings map[string]interface{}, cfg SolveConfig, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	if filepath.Ext(fileName) == ".json" {
+		decoder := json.NewDecoder(file)
+		decoder.UseNumber()
+		err = decoder.Decode(&settings)
+	} else {
+		settings, err = parseFlatYAML(file)
+	}
+
+	if err != nil {
+		return
+	}
+
+	for name, value := range settings {
+		if value == nil {
+			return nil, cfg, fmt.Errorf("%s has no value", name)
+		}
+	}
+
+	// Check the names and types of the settings by decoding them again.
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&cfg); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			err = fmt.Errorf("%s must be %s, not a %s", typeErr.Field, kinds[typeErr.Type.Kind()], typeErr.Value)
+		} else if strings.HasPrefix(err.Error(), "json: unknown field ") {
+			err = fmt.Errorf("unknown setting %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		}
+	}
+
+	return
+}
+
+// Determine if the two names refer to the same existing file.
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(aInfo, bInfo)
+}
+
+// The descriptions of the kinds of values of the settings.
+var kinds = map[reflect.Kind]string{
+	reflect.Bool:    "true or false",
+	reflect.Int:     "an integer",
+	reflect.Int64:   "an integer",
+	reflect.Float64: "a number",
+	reflect.String:  "a string",
+}
+
+// Parse a YAML document that is a flat mapping of settings to scalars, which
+// is all that a configuration file needs. Comments, quoted strings, and the
+// document start marker are supported; nested mappings and lists are not.
+func parseFlatYAML(r io.Reader) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(stripYAMLComment(scanner.Text()), " \t\r")
+
+		switch {
+		case text == "" || text == "---":
+			continue
+
+		case text[0] == ' ' || text[0] == '\t' || text[0] == '-':
+			return nil, fmt.Errorf("line %d: only a flat mapping of settings is supported", line)
+		}
+
+		colon := strings.Index(text, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: expected <setting>: <value>", line)
+		}
+
+		name := strings.TrimSpace(text[:colon])
+		value := strings.TrimSpace(text[colon+1:])
+
+		if value == "" {
+			return nil, fmt.Errorf("line %d: %s has no value; only a flat mapping of settings is supported", line, name)
+		} else if _, ok := settings[name]; ok {
+			return nil, fmt.Errorf("line %d: %s is given twice", line, name)
+		}
+
+		scalar, err := parseYAMLScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		settings[name] = scalar
+	}
+
+	return settings, scanner.Err()
+}
+
+// Remove the comment from a line of YAML, if it has one. A comment starts with
+// a # at the start of the line or after whitespace, outside of quotes.
+func stripYAMLComment(line string) string {
+	var quote rune
+
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+
+		case c == '"' || c == '\'':
+			quote = c
+
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// Parse a YAML scalar as a string, integer, float, boolean, or null.
+func parseYAMLScalar(value string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+
+		return s, nil
+
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+
+	switch strings.ToLower(value) {
+	case "true":
+		return true, nil
+
+	case "false":
+		return false, nil
+
+	case "null", "~":
+		return nil, nil
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, nil
+	}
+
+	return value, nil
+}
+
+// Determine the long options given on the commandline, by name, without the
+// leading dashes. Unique prefixes of the given names are expanded, as docopt
+// does.
+func givenOptions(argv []string, names []string) map[string]bool {
+	given := make(map[string]bool)
+
+	for _, arg := range argv {
+		if arg == "--" {
+			break
+		} else if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+
+		prefix := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)[0]
+
+		var matches []string
+		for _, name := range names {
+			if name == prefix {
+				matches = []string{name}
+				break
+			} else if strings.HasPrefix(name, prefix) {
+				matches = append(matches, name)
+			}
+		}
+
+		if len(matches) == 1 {
+			given[matches[0]] = true
+		}
+	}
+
+	return given
+}
+
+// Apply the settings of a configuration file that are solve options to the
+// parsed arguments, unless they were given on the commandline.
+func applyConfig(args map[string]interface{}, settings map[string]interface{}, given map[string]bool) {
+	for name, value := range settings {
+		isFlag, ok := configOptions[name]
+		if !ok || given[name] {
+			continue
+		}
+
+		if isFlag {
+			args["--"+name] = value.(bool)
+		} else if f, ok := value.(float64); ok {
+			args["--"+name] = strconv.FormatFloat(f, 'g', -1, 64)
+		} else {
+			args["--"+name] = fmt.Sprint(value)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package options
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Write a configuration file and read it back.
+func readConfigString(t *testing.T, name, contents string) (map[string]interface{}, SolveConfig, error) {
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(fileName, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return readConfig(fileName)
+}
+
+func TestReadConfig(t *testing.T) {
+	yaml := `---
+# A short run.
+algorithm: "hpga"   # the default
+seed: 9007199254740993
+ideal: true
+schedule: 'linear'
+mutation: 10
+max-mutate: 0.25
+`
+	json := `{"algorithm": "hpga", "seed": 9007199254740993, "ideal": true, "schedule": "linear", "mutation": 10, "max-mutate": 0.25}`
+
+	for name, contents := range map[string]string{"run.yaml": yaml, "run.json": json} {
+		settings, cfg, err := readConfigString(t, name, contents)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		want := SolveConfig{Algorithm: "hpga", Seed: 9007199254740993, Ideal: true, Schedule: "linear", Mutation: 10, MaxMutate: 0.25}
+		if cfg != want || len(settings) != 6 {
+			t.Errorf("%s: got %+v with %d settings; want %+v with 6", name, cfg, len(settings), want)
+		}
+
+		// The settings that are options are given to docopt's arguments as
+		// strings, except flags, unless they were given on the commandline.
+		args := map[string]interface{}{"--seed": nil, "--ideal": false, "--schedule": "geometric"}
+		applyConfig(args, settings, map[string]bool{"schedule": true})
+
+		if args["--seed"] != "9007199254740993" || args["--ideal"] != true || args["--schedule"] != "geometric" {
+			t.Errorf("%s: got the arguments %v", name, args)
+		}
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"unknown.yaml", "islandz: 2\n", `unknown setting "islandz"`},
+		{"type.yaml", "islands: two\n", "islands must be an integer, not a string"},
+		{"flag.json", `{"ideal": 1}`, "ideal must be true or false"},
+		{"nested.yaml", "hpga:\n  islands: 2\n", "line 1: hpga has no value"},
+		{"list.yaml", "- islands\n", "line 1: only a flat mapping"},
+		{"twice.yaml", "seed: 1\nseed: 2\n", "line 2: seed is given twice"},
+		{"null.yaml", "seed: ~\n", "seed has no value"},
+	}
+
+	for _, test := range tests {
+		if _, _, err := readConfigString(t, test.name, test.contents); err == nil {
+			t.Errorf("%s: got no error", test.name)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got the error %q; want it to contain %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestGivenOptions(t *testing.T) {
+	names := []string{"islands", "ideal", "seed", "slaves", "schedule"}
+	argv := []string{"solve", "--isl", "4", "--seed=3", "--s", "x", "--", "--ideal", "file.tim"}
+
+	given := givenOptions(argv, names)
+	if len(given) != 2 || !given["islands"] || !given["seed"] {
+		t.Errorf("got %v; want islands and seed", given)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := SolveOptions{Algorithm: "tabu", Seed: 5, GMRatio: 0.05}.Config()
+	if err := cfg.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "run.config.json")
+	os.WriteFile(fileName, buf.Bytes(), 0600)
+
+	if _, got, err := readConfig(fileName); err != nil || got != cfg {
+		t.Errorf("read back %+v (%v); want %+v", got, err, cfg)
+	}
+}
+
+func TestSameFile(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "comp01.config.json")
+	if err := os.WriteFile(fileName, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{fileName, fileName, true},
+		{fileName, filepath.Join(dir, ".", "comp01.config.json"), true},
+		{fileName, filepath.Join(dir, "comp02.config.json"), false},
+		{filepath.Join(dir, "comp02.config.json"), filepath.Join(dir, "comp02.config.json"), false},
+	}
+
+	for _, test := range tests {
+		if got := sameFile(test.a, test.b); got != test.want {
+			t.Errorf("sameFile(%q, %q) = %t; want %t", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -22,19 +22,26 @@ import (
 	"log"
 	"math"
 	"math/big"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/brennie/spaghetti/solver/hpga"
+	"github.com/brennie/spaghetti/tt"
 	"github.com/docopt/docopt-go"
 )
 
 type Mode int
 
 const (
-	CheckMode Mode = iota
+	BenchMode Mode = iota
+	CheckMode
 	FetchMode
+	GenerateMode
 	SolveMode
+	StatsMode
+	TuneMode
 )
 
 const (
@@ -42,30 +49,139 @@ const (
 University Timetabling Problem.
 
 Usage:
-  spaghetti solve [options] <instance>
-  spaghetti check <instance> <solution>
-  spaghetti fetch [<directory>]
+  spaghetti solve [options] [--week <shape>] [--weights <file>]
+                  [--extension <file>] [--seed <seed>] <instance>
+  spaghetti bench [options] [--week <shape>] [--weights <file>]
+                  [--seed <seed>] [--runs <n>] [--parallel <n>]
+                  [--reference <file>] [--csv <file>] [--json <file>]
+                  <directory>
+  spaghetti check [--report] [--format <format>] [--week <shape>]
+                  [--weights <file>] [--extension <file>] <instance> <solution>
+  spaghetti fetch [--mirror <url>] [--jobs <n>] [--unverified] [<directory>]
+  spaghetti fetch --from <source> [--unverified] [<directory>]
+  spaghetti fetch --list [<directory>]
+  spaghetti generate [--nevents <n>] [--nrooms <n>] [--nfeatures <n>]
+                     [--nstudents <n>] [--attendance <p>] [--capacity <range>]
+                     [--unavailable <p>] [--precedence <p>] [--plant <kind>]
+                     [--week <shape>] [--seed <seed>] <instance>
+  spaghetti stats [--format <format>] [--week <shape>] <instance>
+  spaghetti tune [options] [--week <shape>] [--weights <file>] [--seed <seed>]
+                 [--budget <n>] [--run-time <n>] [--parallel <n>] <directory>
   spaghetti -h | --help
   spaghetti --version
 
 Options:  
   -h --help         Show this information.
+  --algorithm <name>
+                    Set the optimization algorithm, which is one of hpga,
+                    tabu, or anneal [default: hpga].
+  --anneal <n>      Have each HPGA slave anneal the individuals it creates for
+                    <n> moves [default: 0].
+  --attendance <p>  Set the probability that a student attends each event of a
+                    generated instance [default: 0.05].
+  --budget <n>      Set the number of solver runs tune makes [default: 200].
+  --capacity <range>
+                    Set the range of the room capacities of a generated
+                    instance as <min>-<max>, or as a single capacity
+                    [default: 10-50].
+  --checkpoint <file>
+                    Periodically save the state of the HPGA to the given file
+                    so that the run can be resumed with --resume.
+  --csv <file>      Write the results of bench to the given file as CSV.
+  --config <file>   Read the settings of a solver run from a YAML or JSON file;
+                    options given on the commandline override it. See
+                    doc/config.md.
+  --checkpoint-interval <n>
+                    Set the time between checkpoints in minutes [default: 10].
+  --deterministic   Make HPGA runs reproducible: the islands and slaves take
+                    turns in a fixed order so that the same seed always gives
+                    the same solution. This is slower than a normal run.
+  --extension <file>
+                    Read the institutional data of a post-enrolment instance
+                    (lunch periods, preferred times, etc.) used by the
+                    institutional soft constraints; see doc/soft.md.
+  --format <format> Set the output format of check and stats, which is one of
+                    text or json [default: text].
+  --events <file>   Write HPGA progress events (new best solutions,
+                    selections, GM batches, etc.) to the given file as JSON
+                    Lines.
+  --from <source>   Import the instances in a local directory, tar archive
+                    (.tar, .tar.gz, or .tgz), or zip archive (.zip) instead of
+                    downloading them.
   --ideal           Spaghetti will stop when it detects an ideal solution --
                     not a valid one. Specifying --ideal with --timeout 0 may
                     cause the program to never terminate.
   --islands <n>     Set the number of islands [default: 2].
+  --json <file>     Write the results of bench, including every run, to the
+                    given file as JSON.
+  --jobs <n>        Set the number of instances downloaded at once [default: 4].
+  --list            List the instances installed in the directory and check
+                    them against the catalog of known datasets.
   --minpop <n>      Set the minimum population size [default: 50].
   --maxpop <n>      Set the maximum population size [default: 75].
   --maxprocs <n>    Set GOMAXPROCS to the given value instead of the number of
                     CPUs.
+  --mirror <url>    Download the instances from the given URL, which holds every
+                    file of the datasets, instead of their own sites.
+  --nevents <n>     Set the number of events of a generated instance
+                    [default: 100].
+  --nfeatures <n>   Set the number of room features of a generated instance
+                    [default: 5].
+  --nrooms <n>      Set the number of rooms of a generated instance
+                    [default: 5].
+  --nstudents <n>   Set the number of students of a generated instance
+                    [default: 100].
+  --no-preprocess   Solve the instance as it is given, without checking it for
+                    infeasibility or removing the rooms and times that no
+                    feasible timetable can give its events.
+  --parallel <n>    Set the number of bench or tune runs made at once
+                    [default: 1].
+  --plant <kind>    Build a generated instance around a planted solution, which
+                    is one of none, feasible, or perfect [default: none]. The
+                    solution is written next to the instance, with the
+                    extension .planted.sln.
+  --precedence <p>  Set the probability that two events of a generated
+                    instance must happen in a given order [default: 0.005].
   --profile <file>  Collect profiling information in the given file. 
-  --seed <seed>     Specify the seed for the random number generator.
+  --reference <file>
+                    Compare the results of bench with the best known soft
+                    constraint penalties in the given CSV file, which has a
+                    line <instance>,<penalty> for each instance.
+  --report          List every constraint violation and each student's soft
+                    constraint penalties when checking a solution.
+  --resume <file>   Resume an HPGA run from the given checkpoint. The instance
+                    (with its preprocessing, weights, and extension) and the
+                    number of islands, slaves, and population sizes must match
+                    the run that wrote it.
+  --run-time <n>    Set the time of each run of tune in seconds [default: 60].
+  --runs <n>        Set the number of runs of bench on each instance, each with
+                    its own seed [default: 5].
+  --schedule <name> Set the simulated annealing cooling schedule, which is one
+                    of geometric, linear, or reheating [default: geometric].
+  --seed <seed>     Specify the seed for the random number generator. The runs
+                    of bench and tune use consecutive seeds starting with it.
   --slaves <n>      Set the number of slaves per island [default: 2].
   --timeout <n>     Set the timeout time in minutes [default: 30]. A timeout of
                     0 means that spaghetti won't stop until it finds a valid
                     solution.
+  --unavailable <p> Set the probability that an event of a generated instance
+                    cannot be at a given time [default: 0.1].
+  --unverified      Install instances of the catalog whose size and checksum
+                    are not known, which cannot be verified.
   --version         Show version information.
-  --output <file>   Write the solution to the given file instead of stdout.`
+  --week <shape>    Set the shape of the week of a post-enrolment instance as
+                    <days>x<periods>, e.g., 6x10 for 6 days of 10 periods
+                    [default: 5x9].
+  --weights <file>  Read the weights of the soft constraints of a
+                    post-enrolment instance from the given file; see
+                    doc/soft.md.
+  --violation-weight <n>
+                    Set how many units of fitness a hard constraint violation
+                    is worth when simulated annealing compares solutions
+                    [default: 100].
+  --output <file>   Write the solution to the given file instead of stdout, or
+                    the configuration found by tune instead of
+                    tuned.config.json.`
 
 	version = "spaghetti v0.13"
 )
@@ -74,10 +190,30 @@ type Options interface {
 	Mode() Mode
 }
 
+// Commandline options for the bench Mode
+type BenchOptions struct {
+	SolveOptions        // The settings of each run; the seed is that of the first run.
+	Directory    string // The directory of instances to solve.
+	Runs         int    // The number of runs on each instance.
+	Parallel     int    // The number of runs made at once.
+	Reference    string // The file to read best known values from, if any.
+	CSV          string // The file to write the results to as CSV, if any.
+	JSON         string // The file to write the results to as JSON, if any.
+}
+
+func (o BenchOptions) Mode() Mode {
+	return BenchMode
+}
+
 // Commandline options for the check Mode
 type CheckOptions struct {
-	Instance string // The instance to check against.
-	Solution string // The solution to check.
+	Instance  string  // The instance to check against.
+	Solution  string  // The solution to check.
+	Report    bool    // Should every violation be listed?
+	Format    string  // The output format, which is one of text or json.
+	Week      tt.Week // The week of a post-enrolment instance.
+	Weights   string  // The file to read soft constraint weights from, if any.
+	Extension string  // The file to read the instance's institutional data from, if any.
 }
 
 func (o CheckOptions) Mode() Mode {
@@ -86,31 +222,109 @@ func (o CheckOptions) Mode() Mode {
 
 // Commandline options for the fetch Mode
 type FetchOptions struct {
-	Directory string // The directory to store the instances in.
+	Directory  string // The directory to store the instances in.
+	From       string // The directory or archive to import instances from, if any.
+	List       bool   // Whether to list the instances installed instead.
+	Mirror     string // The URL to download the instances from, if not their own sites.
+	Jobs       int    // The number of instances downloaded at once.
+	Unverified bool   // Whether to install instances of the catalog that cannot be verified.
 }
 
 func (o FetchOptions) Mode() Mode {
 	return FetchMode
 }
 
+// Commandline options for the generate Mode
+type GenerateOptions struct {
+	Instance    string  // The file to write the instance to.
+	Solution    string  // The file to write the planted solution to.
+	NEvents     int     // The number of events.
+	NRooms      int     // The number of rooms.
+	NFeatures   int     // The number of room features.
+	NStudents   int     // The number of students.
+	Week        tt.Week // The week of the instance.
+	Attendance  float64 // The probability that a student attends an event.
+	MinCapacity int     // The smallest room capacity.
+	MaxCapacity int     // The largest room capacity.
+	Unavailable float64 // The probability that an event cannot be at a time.
+	Precedence  float64 // The probability that a pair of events is ordered.
+	Plant       string  // The kind of solution to plant, which is one of none, feasible, or perfect.
+	Seed        int64   // The seed for the random number generator.
+}
+
+func (o GenerateOptions) Mode() Mode {
+	return GenerateMode
+}
+
 // Commandline options for the solve Mode
 type SolveOptions struct {
-	Instance string      // The instance we are solving or checking.
-	Solution string      // The filename for the resultant solution.
-	NIslands int         // The number of islands.
-	NSlaves  int         // The number of slaves per island.
-	MinPop   int         // The minimum population of each island.
-	MaxPop   int         // The maximum population of each island.
-	Profile  interface{} // Either a string or nil. Determines if profiling should be enabled.
-	Seed     int64       // The seed for the random number generator.
-	Timeout  int         // The timeout in minutes.
-	Ideal    bool        // Should we stop when we find an ideal solution (true) or merely a valid one (false).
+	Instance  string      // The instance we are solving or checking.
+	Solution  string      // The filename for the resultant solution.
+	Algorithm string      // The optimization algorithm.
+	NIslands  int         // The number of islands.
+	NSlaves   int         // The number of slaves per island.
+	MinPop    int         // The minimum population of each island.
+	MaxPop    int         // The maximum population of each island.
+	Profile   interface{} // Either a string or nil. Determines if profiling should be enabled.
+	Seed      int64       // The seed for the random number generator.
+	Timeout   int         // The timeout in minutes.
+	Ideal     bool        // Should we stop when we find an ideal solution (true) or merely a valid one (false).
+	Week      tt.Week     // The week of a post-enrolment instance.
+	Weights   string      // The file to read soft constraint weights from, if any.
+	Extension string      // The file to read the instance's institutional data from, if any.
+
+	Deterministic bool   // Should the HPGA schedule its goroutines in a fixed order?
+	Events        string // The file to write progress events to, if any.
+	NoPreprocess  bool   // Should the instance be solved without preprocessing?
+
+	Schedule        string // The simulated annealing cooling schedule.
+	ViolationWeight int    // The weight of a violation relative to fitness in simulated annealing.
+	AnnealSteps     int    // The number of annealing moves slaves make on each new individual.
+
+	Checkpoint         string // The file to write checkpoints to, if any.
+	CheckpointInterval int    // The time between checkpoints in minutes.
+	Resume             string // The checkpoint to resume from, if any.
+
+	ConfigFile      string // The configuration file the settings were read from, if any.
+	EffectiveConfig string // The file to write the configuration of the run to, or empty if that is the configuration file.
+
+	PMutate   int     // The probability, in percent, that an HPGA slave's step is a mutation.
+	PLocal    int     // The probability, in percent, that an HPGA slave's step is a local crossover.
+	MaxMutate float64 // The largest share of an individual's events that a mutation changes.
+	HCTries   int     // The number of hill climbing moves made to weight the events.
+	HCCutOff  int     // The number of hill climbing moves made from each random solution.
+	GMRatio   float64 // The share of the minimum population the GM operator generates.
 }
 
 func (o SolveOptions) Mode() Mode {
 	return SolveMode
 }
 
+// Commandline options for the stats Mode
+type StatsOptions struct {
+	Instance string  // The instance to analyze.
+	Format   string  // The output format, which is one of text or json.
+	Week     tt.Week // The week of a post-enrolment instance.
+}
+
+func (o StatsOptions) Mode() Mode {
+	return StatsMode
+}
+
+// Commandline options for the tune Mode
+type TuneOptions struct {
+	SolveOptions        // The settings the tuned configuration starts from; the seed is that of the first run.
+	Directory    string // The directory of training instances.
+	Budget       int    // The number of runs to make.
+	RunTime      int    // The time of each run in seconds.
+	Parallel     int    // The number of runs made at once.
+	Output       string // The file to write the tuned configuration to.
+}
+
+func (o TuneOptions) Mode() Mode {
+	return TuneMode
+}
+
 func Parse() Options {
 	args, err := docopt.Parse(usage, nil, true, version, false)
 
@@ -119,24 +333,114 @@ func Parse() Options {
 	}
 
 	switch {
+	case args["bench"].(bool):
+		return parseBenchOptions(args)
+
 	case args["check"].(bool):
 		return parseCheckOptions(args)
 
 	case args["fetch"].(bool):
 		return parseFetchOptions(args)
 
+	case args["generate"].(bool):
+		return parseGenerateOptions(args)
+
+	case args["stats"].(bool):
+		return parseStatsOptions(args)
+
+	case args["tune"].(bool):
+		return parseTuneOptions(args)
+
 	default:
 		return parseSolveOptions(args)
 	}
 }
 
+// Stop if any of the named options are given to a mode that makes many solver
+// runs, each of a different instance and seed, so that options naming the
+// files of a single run do not apply.
+func rejectRunFiles(args map[string]interface{}, mode string, names ...string) {
+	for _, name := range names {
+		if args[name] != nil {
+			log.Fatalf("%s is not supported by %s\n", name, mode)
+		}
+	}
+}
+
+func parseBenchOptions(args map[string]interface{}) (opts BenchOptions) {
+	rejectRunFiles(args, "bench", "--checkpoint", "--resume", "--events", "--extension", "--output", "--profile")
+
+	opts.SolveOptions = parseSolverSettings(args)
+	opts.Directory = args["<directory>"].(string)
+	opts.Runs = parseCount(args, "--runs", 1)
+	opts.Parallel = parseCount(args, "--parallel", 1)
+
+	if reference := args["--reference"]; reference != nil {
+		opts.Reference = reference.(string)
+	}
+
+	if csv := args["--csv"]; csv != nil {
+		opts.CSV = csv.(string)
+	}
+
+	if json := args["--json"]; json != nil {
+		opts.JSON = json.(string)
+	}
+
+	if opts.Timeout == 0 {
+		log.Fatalf("Invalid value for --timeout (0): bench runs must have a timeout\n")
+	}
+
+	return
+}
+
 func parseCheckOptions(args map[string]interface{}) (opts CheckOptions) {
 	opts.Instance = args["<instance>"].(string)
 	opts.Solution = args["<solution>"].(string)
+	opts.Report = args["--report"].(bool)
+	opts.Format = args["--format"].(string)
+
+	if opts.Format != "text" && opts.Format != "json" {
+		log.Fatalf("Invalid value for --format: %s\n", opts.Format)
+	}
+
+	opts.Week = parseWeek(args["--week"].(string))
+
+	if weights := args["--weights"]; weights != nil {
+		opts.Weights = weights.(string)
+	}
+
+	if extension := args["--extension"]; extension != nil {
+		opts.Extension = extension.(string)
+	}
 
 	return
 }
 
+// Parse the value of --week, which has the form <days>x<periods>.
+func parseWeek(shape string) (week tt.Week) {
+	fields := strings.Split(shape, "x")
+	if len(fields) != 2 {
+		log.Fatalf("Invalid value for --week: %s\n", shape)
+	}
+
+	days, err := strconv.Atoi(fields[0])
+	if err != nil {
+		log.Fatalf("Invalid value for --week: %s\n", shape)
+	}
+
+	periods, err := strconv.Atoi(fields[1])
+	if err != nil {
+		log.Fatalf("Invalid value for --week: %s\n", shape)
+	}
+
+	if days < 1 || periods < 1 {
+		log.Fatalf("Invalid value for --week (%s): there must be at least one day and period\n", shape)
+	}
+
+	return tt.Week{Days: days, Periods: periods}
+}
+
 func parseFetchOptions(args map[string]interface{}) (opts FetchOptions) {
 	if directory := args["<directory>"]; directory != nil {
 		opts.Directory = directory.(string)
@@ -144,22 +448,236 @@ func parseFetchOptions(args map[string]interface{}) (opts FetchOptions) {
 		opts.Directory = "instances"
 	}
 
+	if from := args["--from"]; from != nil {
+		opts.From = from.(string)
+	}
+
+	opts.List = args["--list"].(bool)
+
+	if mirror := args["--mirror"]; mirror != nil {
+		opts.Mirror = mirror.(string)
+	}
+
+	opts.Jobs = parseCount(args, "--jobs", 1)
+	opts.Unverified = args["--unverified"].(bool)
+
 	return
 }
 
-func parseSolveOptions(args map[string]interface{}) (opts SolveOptions) {
+func parseGenerateOptions(args map[string]interface{}) (opts GenerateOptions) {
+	opts.Instance = args["<instance>"].(string)
+
+	// The planted solution gets its own name so that solving the instance
+	// does not overwrite it.
+	opts.Solution = strings.TrimSuffix(opts.Instance, ".tim") + ".planted.sln"
+
+	opts.NEvents = parseCount(args, "--nevents", 1)
+	opts.NRooms = parseCount(args, "--nrooms", 1)
+	opts.NFeatures = parseCount(args, "--nfeatures", 0)
+	opts.NStudents = parseCount(args, "--nstudents", 0)
+	opts.Week = parseWeek(args["--week"].(string))
+
+	opts.Attendance = parseProbability(args, "--attendance")
+	opts.Unavailable = parseProbability(args, "--unavailable")
+	opts.Precedence = parseProbability(args, "--precedence")
+
+	// A single capacity is the same as a range of one.
+	capacity := args["--capacity"].(string)
+	fields := strings.Split(capacity, "-")
+	if len(fields) == 1 {
+		fields = append(fields, fields[0])
+	} else if len(fields) != 2 {
+		log.Fatalf("Invalid value for --capacity: %s\n", capacity)
+	}
+
 	var err error
+	if opts.MinCapacity, err = strconv.Atoi(fields[0]); err != nil {
+		log.Fatalf("Invalid value for --capacity: %s\n", capacity)
+	}
+
+	if opts.MaxCapacity, err = strconv.Atoi(fields[1]); err != nil {
+		log.Fatalf("Invalid value for --capacity: %s\n", capacity)
+	}
+
+	if opts.MinCapacity < 1 || opts.MaxCapacity < opts.MinCapacity {
+		log.Fatalf("Invalid value for --capacity (%s): the smallest capacity must be at least 1 and at most the largest\n", capacity)
+	}
+
+	switch opts.Plant = args["--plant"].(string); opts.Plant {
+	case "none", "feasible", "perfect":
+		break
+
+	default:
+		log.Fatalf("Invalid value for --plant: %s\n", opts.Plant)
+	}
+
+	opts.Seed = parseSeed(args)
+
+	return
+}
+
+// Set the HPGA settings that can only be given in a configuration file, from
+// the file if it gives them and to their defaults otherwise.
+func parseHPGASettings(opts *SolveOptions, settings map[string]interface{}, cfg SolveConfig) {
+	defaults := hpga.DefaultConfig()
+	opts.PMutate, opts.PLocal, opts.MaxMutate = defaults.PMutate, defaults.PLocal, defaults.MaxMutate
+	opts.HCTries, opts.HCCutOff, opts.GMRatio = defaults.HCTries, defaults.HCCutOff, defaults.GMRatio
+
+	for name := range settings {
+		switch name {
+		case "mutation":
+			opts.PMutate = cfg.Mutation
+
+		case "local-crossover":
+			opts.PLocal = cfg.LocalCrossover
+
+		case "max-mutate":
+			opts.MaxMutate = cfg.MaxMutate
+
+		case "hc-tries":
+			opts.HCTries = cfg.HCTries
+
+		case "hc-cutoff":
+			opts.HCCutOff = cfg.HCCutOff
+
+		case "gm-ratio":
+			opts.GMRatio = cfg.GMRatio
+		}
+	}
+
+	switch {
+	case opts.PMutate < 0 || opts.PMutate > 100:
+		log.Fatalf("Invalid value for mutation in %s (%d): value must be a percentage\n", opts.ConfigFile, opts.PMutate)
+
+	case opts.PLocal < 0 || opts.PLocal > 100:
+		log.Fatalf("Invalid value for local-crossover in %s (%d): value must be a percentage\n", opts.ConfigFile, opts.PLocal)
+
+	case opts.PMutate+opts.PLocal > 100:
+		log.Fatalf("Invalid values for mutation (%d) and local-crossover (%d) in %s: they must total at most 100\n", opts.PMutate, opts.PLocal, opts.ConfigFile)
+
+	case opts.MaxMutate <= 0 || opts.MaxMutate > 1:
+		log.Fatalf("Invalid value for max-mutate in %s (%g): value must be more than 0 and at most 1\n", opts.ConfigFile, opts.MaxMutate)
 
+	case opts.HCTries < 0:
+		log.Fatalf("Invalid value for hc-tries in %s (%d): value must be non-negative\n", opts.ConfigFile, opts.HCTries)
+
+	case opts.HCCutOff < 1:
+		log.Fatalf("Invalid value for hc-cutoff in %s (%d): value must be at least 1\n", opts.ConfigFile, opts.HCCutOff)
+
+	case opts.GMRatio < 0 || opts.GMRatio > 1:
+		log.Fatalf("Invalid value for gm-ratio in %s (%g): value must be between 0 and 1\n", opts.ConfigFile, opts.GMRatio)
+	}
+}
+
+// Parse the value of the named option as a number that is at least min.
+func parseCount(args map[string]interface{}, name string, min int) int {
+	n, err := strconv.Atoi(args[name].(string))
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %s\n", name, args[name].(string))
+	} else if n < min {
+		log.Fatalf("Invalid value for %s (%d): value must be at least %d\n", name, n, min)
+	}
+
+	return n
+}
+
+// Parse the value of the named option as a probability.
+func parseProbability(args map[string]interface{}, name string) float64 {
+	p, err := strconv.ParseFloat(args[name].(string), 64)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %s\n", name, args[name].(string))
+	} else if p < 0 || p > 1 {
+		log.Fatalf("Invalid value for %s (%g): value must be between 0 and 1\n", name, p)
+	}
+
+	return p
+}
+
+// Parse the value of --seed, or pick a random seed if there is none.
+func parseSeed(args map[string]interface{}) int64 {
+	if seed := args["--seed"]; seed != nil {
+		n, err := strconv.ParseInt(seed.(string), 10, 64)
+		if err != nil {
+			log.Fatalf("invalid value for --seed: %s\n", seed.(string))
+		}
+
+		return n
+	}
+
+	seed, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		log.Fatalf("Could not read from system random number generator: %s\n", err.Error())
+	}
+
+	return seed.Int64()
+}
+
+func parseStatsOptions(args map[string]interface{}) (opts StatsOptions) {
+	opts.Instance = args["<instance>"].(string)
+	opts.Format = args["--format"].(string)
+
+	if opts.Format != "text" && opts.Format != "json" {
+		log.Fatalf("Invalid value for --format: %s\n", opts.Format)
+	}
+
+	opts.Week = parseWeek(args["--week"].(string))
+
+	return
+}
+
+func parseSolveOptions(args map[string]interface{}) (opts SolveOptions) {
+	opts = parseSolverSettings(args)
 	opts.Instance = args["<instance>"].(string)
 
 	if solution := args["--output"]; solution != nil {
 		opts.Solution = solution.(string)
 	} else {
-		if ext := filepath.Ext(opts.Instance); ext == ".tim" {
-			opts.Solution = strings.TrimSuffix(opts.Instance, ".tim")
+		opts.Solution = strings.TrimSuffix(opts.Instance, filepath.Ext(opts.Instance)) + ".sln"
+	}
+
+	opts.EffectiveConfig = strings.TrimSuffix(opts.Solution, filepath.Ext(opts.Solution)) + ".config.json"
+
+	// Repeating a run with the configuration it wrote would otherwise replace
+	// that file, losing it if the run is interrupted.
+	if opts.ConfigFile != "" && sameFile(opts.EffectiveConfig, opts.ConfigFile) {
+		opts.EffectiveConfig = ""
+	}
+
+	return
+}
+
+// Parse the settings of a solver run that solve shares with bench, after
+// applying the configuration file, if one is given.
+func parseSolverSettings(args map[string]interface{}) (opts SolveOptions) {
+	var err error
+	var settings map[string]interface{}
+	var cfg SolveConfig
+
+	// The settings of a configuration file are parsed as though they were
+	// given on the commandline, unless they were.
+	if config := args["--config"]; config != nil {
+		opts.ConfigFile = config.(string)
+
+		if settings, cfg, err = readConfig(opts.ConfigFile); err != nil {
+			log.Fatalf("Could not read %s: %s\n", opts.ConfigFile, err)
 		}
 
-		opts.Solution += ".sln"
+		var names []string
+		for name := range args {
+			if strings.HasPrefix(name, "--") {
+				names = append(names, strings.TrimPrefix(name, "--"))
+			}
+		}
+
+		applyConfig(args, settings, givenOptions(os.Args[1:], names))
+	}
+
+	switch opts.Algorithm = args["--algorithm"].(string); opts.Algorithm {
+	case "hpga", "tabu", "anneal":
+		break
+
+	default:
+		log.Fatalf("Invalid value for --algorithm: %s\n", opts.Algorithm)
 	}
 
 	opts.NIslands, err = strconv.Atoi(args["--islands"].(string))
@@ -179,6 +697,8 @@ func parseSolveOptions(args map[string]interface{}) (opts SolveOptions) {
 	opts.MinPop, err = strconv.Atoi(args["--minpop"].(string))
 	if err != nil {
 		log.Fatalf("Invalid value for --minpop: %s\n", args["--minpop"].(string))
+	} else if opts.MinPop < 2 {
+		log.Fatalf("Invalid value for --minpop (%d): value must be at least 2", opts.MinPop)
 	}
 
 	opts.MaxPop, err = strconv.Atoi(args["--maxpop"].(string))
@@ -194,6 +714,71 @@ func parseSolveOptions(args map[string]interface{}) (opts SolveOptions) {
 	}
 
 	opts.Ideal = args["--ideal"].(bool)
+	opts.Week = parseWeek(args["--week"].(string))
+
+	if weights := args["--weights"]; weights != nil {
+		opts.Weights = weights.(string)
+	}
+
+	if extension := args["--extension"]; extension != nil {
+		opts.Extension = extension.(string)
+	}
+
+	opts.Deterministic = args["--deterministic"].(bool)
+	opts.NoPreprocess = args["--no-preprocess"].(bool)
+
+	switch opts.Schedule = args["--schedule"].(string); opts.Schedule {
+	case "geometric", "linear", "reheating":
+		break
+
+	default:
+		log.Fatalf("Invalid value for --schedule: %s\n", opts.Schedule)
+	}
+
+	opts.ViolationWeight, err = strconv.Atoi(args["--violation-weight"].(string))
+	if err != nil {
+		log.Fatalf("Invalid value for --violation-weight: %s\n", args["--violation-weight"].(string))
+	} else if opts.ViolationWeight < 1 {
+		log.Fatalf("Invalid value for --violation-weight (%d): value must be at least 1", opts.ViolationWeight)
+	}
+
+	opts.AnnealSteps, err = strconv.Atoi(args["--anneal"].(string))
+	if err != nil {
+		log.Fatalf("Invalid value for --anneal: %s\n", args["--anneal"].(string))
+	} else if opts.AnnealSteps < 0 {
+		log.Fatalf("Invalid value for --anneal (%d): value must be non-negative", opts.AnnealSteps)
+	}
+
+	if checkpoint := args["--checkpoint"]; checkpoint != nil {
+		opts.Checkpoint = checkpoint.(string)
+	}
+
+	opts.CheckpointInterval, err = strconv.Atoi(args["--checkpoint-interval"].(string))
+	if err != nil {
+		log.Fatalf("Invalid value for --checkpoint-interval: %s\n", args["--checkpoint-interval"].(string))
+	} else if opts.CheckpointInterval < 1 {
+		log.Fatalf("Invalid value for --checkpoint-interval (%d): value must be at least 1", opts.CheckpointInterval)
+	}
+
+	if resume := args["--resume"]; resume != nil {
+		opts.Resume = resume.(string)
+	}
+
+	if (opts.Checkpoint != "" || opts.Resume != "") && opts.Algorithm != "hpga" {
+		log.Fatalf("--checkpoint and --resume are only supported by --algorithm hpga\n")
+	}
+
+	if events := args["--events"]; events != nil {
+		opts.Events = events.(string)
+
+		if opts.Algorithm != "hpga" {
+			log.Fatalf("--events is only supported by --algorithm hpga\n")
+		}
+	}
+
+	if opts.Deterministic && opts.Algorithm != "hpga" {
+		log.Fatalf("--deterministic is only supported by --algorithm hpga; the other algorithms are always deterministic\n")
+	}
 
 	if profileName := args["--profile"]; profileName != nil {
 		opts.Profile = profileName
@@ -201,20 +786,30 @@ func parseSolveOptions(args map[string]interface{}) (opts SolveOptions) {
 		opts.Profile = nil
 	}
 
-	if seed := args["--seed"]; seed != nil {
-		opts.Seed, err = strconv.ParseInt(args["--seed"].(string), 10, 64)
+	opts.Seed = parseSeed(args)
 
-		if err != nil {
-			log.Fatalf("invalid value for --seed: %s\n", args["--seed"].(string))
-		}
+	parseHPGASettings(&opts, settings, cfg)
+
+	return
+}
 
+func parseTuneOptions(args map[string]interface{}) (opts TuneOptions) {
+	rejectRunFiles(args, "tune", "--checkpoint", "--resume", "--events", "--extension", "--profile")
+
+	opts.SolveOptions = parseSolverSettings(args)
+	opts.Directory = args["<directory>"].(string)
+	opts.Budget = parseCount(args, "--budget", 1)
+	opts.RunTime = parseCount(args, "--run-time", 1)
+	opts.Parallel = parseCount(args, "--parallel", 1)
+
+	if output := args["--output"]; output != nil {
+		opts.Output = output.(string)
 	} else {
-		seed, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
-		if err != nil {
-			log.Fatalf("Could not read from system random number generator: %s\n", err.Error())
-		}
+		opts.Output = "tuned.config.json"
+	}
 
-		opts.Seed = seed.Int64()
+	if opts.Algorithm != "hpga" {
+		log.Fatalf("tune only supports --algorithm hpga\n")
 	}
 
 	return
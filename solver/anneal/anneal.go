@@ -0,0 +1,286 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// The simulated annealing package.
+package anneal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+const (
+	pSwap           = 20   // The probability of a swap move is 20%.
+	coolingInterval = 100  // The number of moves made at each temperature.
+	nSamples        = 1000 // The number of moves sampled to estimate the initial temperature.
+	acceptance      = 0.8  // The probability of accepting an average worsening move at the initial temperature.
+
+	nLocalSamples   = 50   // The number of moves sampled when used for local improvement.
+	localAcceptance = 0.05 // The initial acceptance probability when used for local improvement.
+)
+
+// The configuration of a simulated annealing run.
+type Config struct {
+	Seed            int64  // The seed for the random number generator.
+	Ideal           bool   // Should the run stop at an ideal solution (true) or merely a valid one (false)?
+	Schedule        string // The cooling schedule, which is one of geometric, linear, or reheating.
+	ViolationWeight int    // The weight of a violation relative to the fitness.
+
+	Improved func(tt.Value) // Called with the value of each new best solution, if not nil.
+}
+
+// The default configuration.
+func DefaultConfig() Config {
+	return Config{Schedule: "geometric", ViolationWeight: 100}
+}
+
+// Determine if the configuration is usable.
+func (cfg Config) validate() error {
+	if err := CheckSchedule(cfg.Schedule); err != nil {
+		return err
+	} else if cfg.ViolationWeight < 1 {
+		return fmt.Errorf("violation weight must be at least 1, not %d", cfg.ViolationWeight)
+	}
+
+	return nil
+}
+
+// The state of a simulated annealing run.
+type annealer struct {
+	soln      *tt.Solution // The current solution.
+	rng       *rand.Rand   // The random number generator.
+	weight    int          // The weight of a violation relative to the fitness.
+	sched     schedule     // The cooling schedule.
+	temp      float64      // The current temperature.
+	best      []tt.Rat     // The assignments of the best solution found.
+	bestValue tt.Value     // The value of the best solution found.
+	improved  bool         // Has a better solution been found since the last cooling?
+}
+
+// Create a new annealer for the solution. The initial temperature is chosen so
+// that an average worsening move is accepted with the given probability.
+func newAnnealer(soln *tt.Solution, rng *rand.Rand, schedName string, weight int, samples int, p float64) *annealer {
+	a := &annealer{
+		soln,
+		rng,
+		weight,
+		nil,
+		0,
+		soln.Assignments(),
+		soln.Value(),
+		false,
+	}
+
+	a.temp = a.estimateTemperature(samples, p)
+	a.sched = newSchedule(schedName, a.temp)
+
+	return a
+}
+
+// Scalarize a value into an energy to minimize.
+func (a *annealer) energy(value tt.Value) float64 {
+	return float64(a.weight*value.Violations + value.Fitness)
+}
+
+// Estimate the initial temperature by sampling random moves from the current
+// solution and averaging the energy increase of the worsening ones.
+func (a *annealer) estimateTemperature(samples int, p float64) float64 {
+	current := a.energy(a.soln.Value())
+	total := 0.0
+	count := 0
+
+	for i := 0; i < samples; i++ {
+		event := a.rng.Intn(a.soln.NEvents())
+		if len(a.soln.Domains[event]) == 0 {
+			continue
+		}
+
+		rat := a.soln.Domains[event][a.rng.Intn(len(a.soln.Domains[event]))]
+		if delta := a.energy(a.soln.AssignValue(event, rat)) - current; delta > 0 {
+			total += delta
+			count++
+		}
+	}
+
+	if count == 0 {
+		return minTemp
+	}
+
+	return math.Max(-(total/float64(count))/math.Log(p), minTemp)
+}
+
+// Determine if a move changing the energy by delta is accepted at the current
+// temperature.
+func (a *annealer) accept(delta float64) bool {
+	return delta <= 0 || a.rng.Float64() < math.Exp(-delta/a.temp)
+}
+
+// Try to move a random event to a random Rat in its domain.
+func (a *annealer) tryAssign() {
+	event := a.rng.Intn(a.soln.NEvents())
+	if len(a.soln.Domains[event]) == 0 {
+		return
+	}
+
+	rat := a.soln.Domains[event][a.rng.Intn(len(a.soln.Domains[event]))]
+	delta := a.energy(a.soln.AssignValue(event, rat)) - a.energy(a.soln.Value())
+
+	if a.accept(delta) {
+		a.soln.Assign(event, rat)
+	}
+}
+
+// Try to swap the Rats of two random events. The swap is only attempted if
+// each event can be assigned to the other's Rat.
+func (a *annealer) trySwap() {
+	move := a.soln.SwapMove(a.rng.Intn(a.soln.NEvents()), a.rng.Intn(a.soln.NEvents()))
+	if !a.soln.CanApply(move) {
+		return
+	}
+
+	delta := a.energy(a.soln.MoveValue(move)) - a.energy(a.soln.Value())
+
+	if a.accept(delta) {
+		a.soln.Apply(move)
+	}
+}
+
+// Perform a single move and return whether or not a new best solution was
+// found.
+func (a *annealer) step() bool {
+	if a.rng.Intn(100) < pSwap {
+		a.trySwap()
+	} else {
+		a.tryAssign()
+	}
+
+	if value := a.soln.Value(); value.Less(a.bestValue) {
+		a.bestValue = value
+		a.best = a.soln.Assignments()
+		a.improved = true
+
+		return true
+	}
+
+	return false
+}
+
+// Perform a round of moves at the current temperature and then cool. Return
+// whether or not a new best solution was found.
+func (a *annealer) round() (improved bool) {
+	for i := 0; i < coolingInterval; i++ {
+		if a.step() {
+			improved = true
+		}
+	}
+
+	a.temp = a.sched.cool(a.temp, a.improved)
+	a.improved = false
+
+	return
+}
+
+// Improve a solution in place by annealing it for the given number of moves.
+// The starting temperature is low, so this acts as a local search that can
+// escape shallow local minima. The solution is left as the best one found,
+// and its value is returned.
+func Improve(soln *tt.Solution, rng *rand.Rand, schedName string, weight int, steps int) tt.Value {
+	a := newAnnealer(soln, rng, schedName, weight, nLocalSamples, localAcceptance)
+
+	for i := 0; i < steps; i++ {
+		a.step()
+
+		if (i+1)%coolingInterval == 0 {
+			a.temp = a.sched.cool(a.temp, a.improved)
+			a.improved = false
+		}
+	}
+
+	if a.bestValue.Less(soln.Value()) {
+		for event, rat := range a.best {
+			if rat.Assigned() {
+				soln.Assign(event, rat)
+			} else {
+				soln.Unassign(event)
+			}
+		}
+	}
+
+	return soln.Value()
+}
+
+// Determine if a good enough solution has been found to stop.
+func isDone(value tt.Value, ideal bool) bool {
+	if ideal && value.IsIdeal() {
+		log.Println("Found ideal solution. Stopping...")
+		return true
+	} else if !ideal && value.IsValid() {
+		log.Println("Found valid solution. Stopping...")
+		return true
+	}
+
+	return false
+}
+
+// Run simulated annealing on the instance, starting from a random assignment,
+// until a good enough solution is found or the context is done, and return
+// the best solution found.
+func (cfg Config) Solve(ctx context.Context, inst *tt.Instance) (*tt.Solution, tt.Value, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, tt.WorstValue(), err
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	soln := inst.NewSolution()
+	defer soln.Free()
+
+	for event := 0; event < inst.NEvents(); event++ {
+		if domain := inst.Domains[event]; len(domain) > 0 {
+			soln.Assign(event, domain[rng.Intn(len(domain))])
+		}
+	}
+
+	a := newAnnealer(soln, rng, cfg.Schedule, cfg.ViolationWeight, nSamples, acceptance)
+
+	log.Printf("Initial solution: %s\n", a.bestValue)
+	log.Printf("Initial temperature: %.2f\n", a.temp)
+
+annealLoop:
+	for !isDone(a.bestValue, cfg.Ideal) {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping: %s\n", ctx.Err())
+			break annealLoop
+
+		default:
+			if a.round() {
+				log.Printf("Found new best solution: %s (temperature %.2f)\n", a.bestValue, a.temp)
+
+				if cfg.Improved != nil {
+					cfg.Improved(a.bestValue)
+				}
+			}
+		}
+	}
+
+	return inst.SolutionFromRats(a.best), a.bestValue, nil
+}
@@ -0,0 +1,135 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package anneal
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/brennie/spaghetti/internal/testutil"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Create a solution with every event assigned to a random Rat in its domain.
+func randomSolution(inst *tt.Instance, rng *rand.Rand) *tt.Solution {
+	soln := inst.NewSolution()
+
+	for event, domain := range inst.Domains {
+		if len(domain) > 0 {
+			soln.Assign(event, domain[rng.Intn(len(domain))])
+		}
+	}
+
+	return soln
+}
+
+func TestSolveValid(t *testing.T) {
+	inst := testutil.PlantedInstance(t)
+
+	for _, schedule := range []string{"geometric", "linear", "reheating"} {
+		var improvements []tt.Value
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Schedule = schedule
+		cfg.Improved = func(value tt.Value) { improvements = append(improvements, value) }
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		soln, value, err := cfg.Solve(ctx, inst)
+		cancel()
+
+		if err != nil {
+			t.Fatalf("%s: %s", schedule, err)
+		}
+
+		if !value.IsValid() || soln.Value() != value {
+			t.Errorf("%s: got the value %s for a solution with value %s; want a valid one", schedule, value, soln.Value())
+		}
+
+		for i := 1; i < len(improvements); i++ {
+			if !improvements[i].Less(improvements[i-1]) {
+				t.Errorf("%s: improvement %d (%s) is no better than the one before it (%s)", schedule, i, improvements[i], improvements[i-1])
+			}
+		}
+
+		soln.Free()
+	}
+}
+
+func TestSolveInvalidConfig(t *testing.T) {
+	inst := testutil.PlantedInstance(t)
+
+	for _, cfg := range []Config{{Schedule: "exponential", ViolationWeight: 100}, {Schedule: "linear"}} {
+		if soln, _, err := cfg.Solve(context.Background(), inst); err == nil {
+			t.Errorf("%+v: got no error", cfg)
+			soln.Free()
+		}
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	if got := newSchedule("geometric", 10).cool(10, false); got != 10*alpha {
+		t.Errorf("geometric: cooled 10 to %g; want %g", got, 10*alpha)
+	}
+
+	// The linear schedule reaches the minimum after linearSteps coolings.
+	lin := newSchedule("linear", 10)
+	temp := 10.0
+	for i := 0; i < linearSteps-1; i++ {
+		temp = lin.cool(temp, false)
+	}
+
+	if temp <= minTemp || lin.cool(temp, false) != minTemp {
+		t.Errorf("linear: reached %g after %d coolings; want the minimum after %d", temp, linearSteps-1, linearSteps)
+	}
+
+	// The reheating schedule only reheats after reheatPatience coolings in a
+	// row without an improvement.
+	reheat := newSchedule("reheating", 10)
+	temp = 10
+	last := reheatPatience/2 + reheatPatience
+	for i := 0; i <= last; i++ {
+		if i == last && temp >= 10*reheatFactor {
+			t.Errorf("reheating: got %g before the last cooling; want to have cooled below %g", temp, 10*reheatFactor)
+		}
+
+		temp = reheat.cool(temp, i == reheatPatience/2)
+	}
+
+	if want := 10 * reheatFactor; temp != want {
+		t.Errorf("reheating: got %g after %d coolings; want to have reheated to %g", temp, last+1, want)
+	}
+}
+
+func TestImprove(t *testing.T) {
+	inst := testutil.PlantedInstance(t)
+	rng := rand.New(rand.NewSource(3))
+
+	for i := 0; i < 10; i++ {
+		soln := randomSolution(inst, rng)
+		before := soln.Value()
+
+		after := Improve(soln, rng, "geometric", 100, 500)
+		if before.Less(after) || soln.Value() != after {
+			t.Errorf("improved %s to %s, for a solution with value %s", before, after, soln.Value())
+		}
+
+		soln.Free()
+	}
+}
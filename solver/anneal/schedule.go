@@ -0,0 +1,110 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package anneal
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	alpha          = 0.995 // The factor the geometric schedule cools by.
+	linearSteps    = 2000  // The number of coolings the linear schedule takes to reach the minimum.
+	reheatPatience = 200   // The number of coolings without improvement before reheating.
+	reheatFactor   = 0.5   // The fraction of the initial temperature to reheat to.
+	minTemp        = 1e-3  // The lowest temperature any schedule will cool to.
+)
+
+// A cooling schedule, which determines how the temperature changes over the
+// course of the annealing.
+type schedule interface {
+	// Determine the next temperature given the current temperature and
+	// whether or not a new best solution was found since the last cooling.
+	cool(temp float64, improved bool) float64
+}
+
+// A geometric schedule multiplies the temperature by a constant factor.
+type geometric struct{}
+
+// Cool the temperature by a constant factor.
+func (_ geometric) cool(temp float64, _ bool) float64 {
+	return math.Max(temp*alpha, minTemp)
+}
+
+// A linear schedule decreases the temperature by a constant amount so that it
+// reaches the minimum after a fixed number of coolings.
+type linear struct {
+	step float64 // The amount to decrease the temperature by.
+}
+
+// Cool the temperature by a constant amount.
+func (l linear) cool(temp float64, _ bool) float64 {
+	return math.Max(temp-l.step, minTemp)
+}
+
+// A reheating schedule cools geometrically but raises the temperature again
+// once it has gone too long without finding a better solution.
+type reheating struct {
+	initial float64 // The initial temperature.
+	stale   int     // The number of coolings since the last improvement.
+}
+
+// Cool the temperature by a constant factor, or reheat if the search is stale.
+func (r *reheating) cool(temp float64, improved bool) float64 {
+	if improved {
+		r.stale = 0
+	} else {
+		r.stale++
+	}
+
+	if r.stale >= reheatPatience {
+		r.stale = 0
+		return r.initial * reheatFactor
+	}
+
+	return math.Max(temp*alpha, minTemp)
+}
+
+// Determine if there is a schedule with the given name.
+func CheckSchedule(name string) error {
+	switch name {
+	case "geometric", "linear", "reheating":
+		return nil
+
+	default:
+		return fmt.Errorf("unknown cooling schedule %q", name)
+	}
+}
+
+// Create the schedule with the given name starting at the given temperature.
+// The name must have been checked with CheckSchedule.
+func newSchedule(name string, initial float64) schedule {
+	switch name {
+	case "geometric":
+		return geometric{}
+
+	case "linear":
+		return linear{initial / linearSteps}
+
+	case "reheating":
+		return &reheating{initial, 0}
+
+	default:
+		panic(fmt.Sprintf("anneal: unknown schedule %q", name))
+	}
+}
@@ -0,0 +1,508 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brennie/spaghetti/fetcher"
+	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The outcome of one run of a solver on an instance. Times are in seconds.
+type benchRun struct {
+	Seed           int64    `json:"seed"`             // The seed of the run.
+	Value          tt.Value `json:"value"`            // The value of the best solution found.
+	Distance       int      `json:"distance"`         // The number of events the best solution leaves unassigned.
+	Time           float64  `json:"time"`             // How long the run took.
+	TimeToFeasible *float64 `json:"time_to_feasible"` // When the first valid solution was found, or nil if none was.
+	TimeToBest     float64  `json:"time_to_best"`     // When the best solution was found.
+}
+
+// The results of the runs on an instance.
+type benchResult struct {
+	Instance string     `json:"instance"`        // The file name of the instance.
+	Error    string     `json:"error,omitempty"` // Why the instance could not be solved, if it could not.
+	Runs     []benchRun `json:"runs"`            // The runs, in the order of their seeds.
+
+	Best   *tt.Value `json:"best"`   // The best value of the runs.
+	Median *tt.Value `json:"median"` // The median value of the runs; with an even number of runs, the better of the middle two.
+	Worst  *tt.Value `json:"worst"`  // The worst value of the runs.
+
+	Feasible             int      `json:"feasible"`                // The number of runs that found a valid solution.
+	MedianTimeToFeasible *float64 `json:"median_time_to_feasible"` // The median time to a valid solution of those runs.
+	MedianTimeToBest     *float64 `json:"median_time_to_best"`     // The median time to the best solution of the runs.
+
+	BestKnown *int `json:"best_known"` // The best known soft constraint penalty, if it is known.
+	Gap       *int `json:"gap"`        // How far the best penalty is above the best known, if the best solution is valid.
+}
+
+// The results of bench, as written to JSON.
+type benchResults struct {
+	Config    options.SolveConfig `json:"config"`    // The configuration of the runs; the seed is that of the first run.
+	Runs      int                 `json:"runs"`      // The number of runs on each instance.
+	Instances []benchResult       `json:"instances"` // The results of each instance.
+}
+
+// An instance to run the solver on.
+type benchInstance struct {
+	name string       // The file name of the instance.
+	inst *tt.Instance // The instance, or nil if it could not be read.
+	err  error        // Why the instance could not be read, if it could not.
+}
+
+// Read the best known soft constraint penalties of the instances from a CSV
+// file with a line <instance>,<penalty> for each instance. A first line whose
+// penalty is not a number is a header.
+func readReference(r io.Reader) (map[string]int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	reference := make(map[string]int)
+	for i, record := range records {
+		best, err := strconv.Atoi(record[1])
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+
+			return nil, fmt.Errorf("line %d: invalid penalty %q for %s", i+1, record[1], record[0])
+		}
+
+		reference[filepath.Base(record[0])] = best
+	}
+
+	return reference, nil
+}
+
+// Determine the median of sorted times, or nil if there are none.
+func medianTime(times []float64) *float64 {
+	n := len(times)
+	if n == 0 {
+		return nil
+	}
+
+	median := times[n/2]
+	if n%2 == 0 {
+		median = (times[n/2-1] + times[n/2]) / 2
+	}
+
+	return &median
+}
+
+// Summarize the runs of the result, comparing the best run with the best known
+// penalty if it is given.
+func (r *benchResult) summarize(bestKnown *int) {
+	r.BestKnown = bestKnown
+
+	if len(r.Runs) == 0 {
+		return
+	}
+
+	values := make([]tt.Value, len(r.Runs))
+	var toFeasible, toBest []float64
+
+	for i, run := range r.Runs {
+		values[i] = run.Value
+		toBest = append(toBest, run.TimeToBest)
+
+		if run.TimeToFeasible != nil {
+			toFeasible = append(toFeasible, *run.TimeToFeasible)
+		}
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i].Less(values[j]) })
+	sort.Float64s(toFeasible)
+	sort.Float64s(toBest)
+
+	r.Best = &values[0]
+	r.Median = &values[(len(values)-1)/2]
+	r.Worst = &values[len(values)-1]
+
+	r.Feasible = len(toFeasible)
+	r.MedianTimeToFeasible = medianTime(toFeasible)
+	r.MedianTimeToBest = medianTime(toBest)
+
+	if bestKnown != nil && r.Best.IsValid() {
+		gap := r.Best.Fitness - *bestKnown
+		r.Gap = &gap
+	}
+}
+
+// Read the soft constraint weights from the named file, if there is one, to
+// give to every post-enrolment instance.
+func readSoftWeights(fileName string) tt.SoftWeights {
+	if fileName == "" {
+		return nil
+	}
+
+	weightsFile, err := os.Open(fileName)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+	defer weightsFile.Close()
+
+	weights, err := tt.ReadSoftWeights(weightsFile)
+	if err != nil {
+		log.Fatalf("Could not parse %s: %s\n", fileName, err)
+	}
+
+	return weights
+}
+
+// Read the instances in the directory, preparing them as solve would.
+func readBenchInstances(directory string, opts options.SolveOptions, weights tt.SoftWeights) ([]benchInstance, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []benchInstance
+	for _, entry := range entries {
+		if entry.IsDir() || !fetcher.IsInstance(entry.Name()) {
+			continue
+		}
+
+		inst, err := readBenchInstance(filepath.Join(directory, entry.Name()), opts, weights)
+		instances = append(instances, benchInstance{entry.Name(), inst, err})
+	}
+
+	return instances, nil
+}
+
+// Read an instance, set its soft constraint weights if it is a post-enrolment
+// instance, and preprocess it unless preprocessing is disabled.
+func readBenchInstance(fileName string, opts options.SolveOptions, weights tt.SoftWeights) (*tt.Instance, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	inst, err := tt.ParseWithWeek(file, opts.Week)
+	if err != nil {
+		return nil, err
+	}
+
+	if weights != nil && inst.Format() == tt.PostEnrolment {
+		if err = inst.SetSoftWeights(weights); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.NoPreprocess {
+		if _, err = inst.Preprocess(); err != nil {
+			return nil, err
+		}
+	}
+
+	return inst, nil
+}
+
+// Run the solver once on the instance with the given seed, for at most the
+// given time.
+func benchOnce(ctx context.Context, opts options.SolveOptions, inst *tt.Instance, seed int64, timeout time.Duration) (benchRun, error) {
+	opts.Seed = seed
+	run := benchRun{Seed: seed}
+
+	// The solvers report improvements from the goroutine that runs them or
+	// delivers their events, and are done with it once they return.
+	start := time.Now()
+	var feasibleAt *float64
+	improved := func(value tt.Value, at time.Time) {
+		run.TimeToBest = at.Sub(start).Seconds()
+
+		if feasibleAt == nil && value.IsValid() {
+			at := run.TimeToBest
+			feasibleAt = &at
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	soln, value, err := newSolver(opts, nil, improved).Solve(ctx, inst)
+	if err != nil {
+		return run, err
+	}
+	defer soln.Free()
+
+	run.Value = value
+	run.Distance = soln.Distance()
+	run.Time = time.Since(start).Seconds()
+
+	// A valid initial solution is never reported as an improvement.
+	if feasibleAt == nil && value.IsValid() {
+		feasibleAt = new(float64)
+	}
+	run.TimeToFeasible = feasibleAt
+
+	return run, nil
+}
+
+// Run the solver on every instance in the directory with consecutive seeds,
+// and write a table of the results.
+func Bench(opts options.BenchOptions) {
+	weights := readSoftWeights(opts.Weights)
+
+	reference := make(map[string]int)
+	if opts.Reference != "" {
+		referenceFile, err := os.Open(opts.Reference)
+		if err != nil {
+			log.Fatalf("Could not %s\n", err)
+		}
+
+		reference, err = readReference(referenceFile)
+		referenceFile.Close()
+		if err != nil {
+			log.Fatalf("Could not parse %s: %s\n", opts.Reference, err)
+		}
+	}
+
+	instances, err := readBenchInstances(opts.Directory, opts.SolveOptions, weights)
+	if err != nil {
+		log.Fatalf("Could not read the instances in %s: %s\n", opts.Directory, err)
+	} else if len(instances) == 0 {
+		log.Fatalf("Found no instances in %s\n", opts.Directory)
+	}
+
+	results := make([]benchResult, len(instances))
+	for i, bi := range instances {
+		results[i].Instance = bi.name
+		results[i].Runs = make([]benchRun, opts.Runs)
+
+		if bi.err != nil {
+			results[i].Error = bi.err.Error()
+			log.Printf("Skipping %s: %s\n", bi.name, bi.err)
+		}
+	}
+
+	log.Printf("Running %s solver %d times on each of %d instances with seeds from %d\n", opts.Algorithm, opts.Runs, len(instances), opts.Seed)
+
+	if opts.ConfigFile != "" {
+		log.Printf("Using settings from %s\n", opts.ConfigFile)
+	}
+
+	// The solvers log every improvement, which is too much to follow when
+	// several runs are made at once, so only the outcome of each run is logged.
+	progress := log.New(log.Writer(), log.Prefix(), log.Flags())
+	log.SetOutput(io.Discard)
+
+	// The runs stop when interrupted, and the results of the runs that
+	// finished are still written. A second interrupt quits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	type job struct{ instance, run int }
+	jobs := make(chan job)
+	done := make([][]bool, len(instances))
+	for i := range done {
+		done[i] = make([]bool, opts.Runs)
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < opts.Parallel; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				name := instances[j.instance].name
+				seed := opts.Seed + int64(j.run)
+
+				run, err := benchOnce(ctx, opts.SolveOptions, instances[j.instance].inst, seed, time.Duration(opts.Timeout)*time.Minute)
+				if err != nil {
+					// A run that cannot start is a problem with the settings,
+					// which every other run shares.
+					progress.Fatalf("Could not solve %s: %s\n", name, err)
+				}
+
+				if ctx.Err() != nil {
+					continue
+				}
+
+				mutex.Lock()
+				results[j.instance].Runs[j.run] = run
+				done[j.instance][j.run] = true
+				mutex.Unlock()
+
+				progress.Printf("Run %d of %d on %s (seed %d): %s after %.2f seconds\n", j.run+1, opts.Runs, name, seed, run.Value, run.Time)
+			}
+		}()
+	}
+
+	for i, bi := range instances {
+		for r := 0; r < opts.Runs && bi.inst != nil && ctx.Err() == nil; r++ {
+			jobs <- job{i, r}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	log.SetOutput(progress.Writer())
+
+	if ctx.Err() != nil {
+		log.Printf("Interrupted; the results only include the runs that finished\n")
+	}
+
+	for i := range results {
+		runs := make([]benchRun, 0, opts.Runs)
+		for r, run := range results[i].Runs {
+			if done[i][r] {
+				runs = append(runs, run)
+			}
+		}
+		results[i].Runs = runs
+
+		var bestKnown *int
+		if best, ok := reference[results[i].Instance]; ok {
+			bestKnown = &best
+		} else if best, ok := fetcher.BestKnown(results[i].Instance); ok {
+			bestKnown = &best
+		} else {
+			log.Printf("No best known value for %s; give one with --reference\n", results[i].Instance)
+		}
+
+		results[i].summarize(bestKnown)
+	}
+
+	all := benchResults{opts.Config(), opts.Runs, results}
+
+	if opts.CSV == "" && opts.JSON == "" {
+		if err := writeBenchCSV(os.Stdout, results); err != nil {
+			log.Fatalf("Could not write the results: %s\n", err)
+		}
+	}
+
+	if opts.CSV != "" {
+		writeOutputFile(opts.CSV, func(w io.Writer) error { return writeBenchCSV(w, results) })
+	}
+
+	if opts.JSON != "" {
+		writeOutputFile(opts.JSON, func(w io.Writer) error { return writeBenchJSON(w, all) })
+	}
+}
+
+// Create the named file and write to it.
+func writeOutputFile(fileName string, write func(io.Writer) error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+	defer file.Close()
+
+	if err = write(file); err != nil {
+		log.Fatalf("Could not write %s: %s\n", fileName, err)
+	}
+
+	log.Printf("Wrote %s\n", fileName)
+}
+
+// The columns of the CSV table of results.
+var benchColumns = []string{
+	"instance", "runs", "feasible",
+	"best_violations", "best_fitness",
+	"median_violations", "median_fitness",
+	"worst_violations", "worst_fitness",
+	"median_time_to_feasible", "median_time_to_best",
+	"best_known", "gap", "error",
+}
+
+// Write the results as a CSV table with a row for each instance. Values that
+// are not known are left empty.
+func writeBenchCSV(w io.Writer, results []benchResult) error {
+	writer := csv.NewWriter(w)
+	writer.Write(benchColumns)
+
+	optInt := func(n *int) string {
+		if n == nil {
+			return ""
+		}
+
+		return strconv.Itoa(*n)
+	}
+
+	optTime := func(t *float64) string {
+		if t == nil {
+			return ""
+		}
+
+		return strconv.FormatFloat(*t, 'f', 2, 64)
+	}
+
+	optValue := func(v *tt.Value) []string {
+		if v == nil {
+			return []string{"", ""}
+		}
+
+		return []string{strconv.Itoa(v.Violations), strconv.Itoa(v.Fitness)}
+	}
+
+	for _, r := range results {
+		row := []string{r.Instance, strconv.Itoa(len(r.Runs)), strconv.Itoa(r.Feasible)}
+		row = append(row, optValue(r.Best)...)
+		row = append(row, optValue(r.Median)...)
+		row = append(row, optValue(r.Worst)...)
+		row = append(row, optTime(r.MedianTimeToFeasible), optTime(r.MedianTimeToBest))
+		row = append(row, optInt(r.BestKnown), optInt(r.Gap), r.Error)
+		writer.Write(row)
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// Write the results, including every run, as JSON.
+func writeBenchJSON(w io.Writer, results benchResults) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
@@ -0,0 +1,103 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+func TestReadReference(t *testing.T) {
+	reference, err := readReference(strings.NewReader("instance,best\n# track 2\ncomp-2007-2-1.tim, 1482\ninstances/comp01.ctt,5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reference) != 2 || reference["comp-2007-2-1.tim"] != 1482 || reference["comp01.ctt"] != 5 {
+		t.Errorf("got %v", reference)
+	}
+
+	for _, contents := range []string{"comp01.ctt,5\ncomp02.ctt,many\n", "comp01.ctt\n"} {
+		if _, err := readReference(strings.NewReader(contents)); err == nil {
+			t.Errorf("got no error for %q", contents)
+		}
+	}
+}
+
+// Create a run with the given value, taking the given times to feasibility
+// (negative if it never was) and to its best solution.
+func run(violations, fitness int, toFeasible, toBest float64) benchRun {
+	r := benchRun{Value: tt.Value{Violations: violations, Fitness: fitness}, TimeToBest: toBest}
+	if toFeasible >= 0 {
+		r.TimeToFeasible = &toFeasible
+	}
+
+	return r
+}
+
+func TestSummarize(t *testing.T) {
+	bestKnown := 20
+	r := benchResult{Instance: "comp-2007-2-1.tim", Runs: []benchRun{
+		run(0, 30, 1, 4),
+		run(2, 10, -1, 9),
+		run(0, 25, 3, 6),
+		run(0, 40, 2, 2),
+	}}
+	r.summarize(&bestKnown)
+
+	if *r.Best != (tt.Value{Fitness: 25}) || *r.Median != (tt.Value{Fitness: 30}) || *r.Worst != (tt.Value{Violations: 2, Fitness: 10}) {
+		t.Errorf("got best %s, median %s, and worst %s", r.Best, r.Median, r.Worst)
+	}
+
+	if r.Feasible != 3 || *r.MedianTimeToFeasible != 2 || *r.MedianTimeToBest != 5 {
+		t.Errorf("got %d feasible runs and median times %g and %g; want 3, 2, and 5", r.Feasible, *r.MedianTimeToFeasible, *r.MedianTimeToBest)
+	}
+
+	if r.Gap == nil || *r.Gap != 5 {
+		t.Errorf("got the gap %v; want 5", r.Gap)
+	}
+
+	// An instance with no valid run has no gap or time to feasibility.
+	r = benchResult{Instance: "comp-2007-2-2.tim", Runs: []benchRun{run(1, 0, -1, 1)}}
+	r.summarize(&bestKnown)
+
+	if r.Gap != nil || r.MedianTimeToFeasible != nil {
+		t.Errorf("got the gap %v and time to feasibility %v; want neither", r.Gap, r.MedianTimeToFeasible)
+	}
+}
+
+func TestWriteBenchCSV(t *testing.T) {
+	r := benchResult{Instance: "comp01.ctt", Runs: []benchRun{run(0, 7, 0.5, 1.25)}}
+	r.summarize(nil)
+
+	var buf bytes.Buffer
+	err := writeBenchCSV(&buf, []benchResult{r, {Instance: "comp02.ctt", Runs: []benchRun{}, Error: "could not parse"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join(benchColumns, ",") + "\n" +
+		"comp01.ctt,1,1,0,7,0,7,0,7,0.50,1.25,,,\n" +
+		"comp02.ctt,0,0,,,,,,,,,,,could not parse\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -24,75 +24,95 @@ import (
 )
 
 // Randomly assign a solution by using a random variable ordering and picking
-// random domain entries in (non-empty) domains to assign to them.
-func RandomAssignment(soln *tt.Solution) *tt.Solution {
-	for _, event := range rand.Perm(soln.NEvents()) {
-		rat := soln.Domains[event][rand.Intn(len(soln.Domains[event]))]
+// random domain entries in (non-empty) domains to assign to them. Events with
+// empty domains are left unassigned.
+func RandomAssignment(soln *tt.Solution, rng *rand.Rand) *tt.Solution {
+	for _, event := range rng.Perm(soln.NEvents()) {
+		if len(soln.Domains[event]) == 0 {
+			continue
+		}
+
+		rat := soln.Domains[event][rng.Intn(len(soln.Domains[event]))]
 		soln.Assign(event, rat)
 	}
 	return soln
 }
 
 // Follow the given variable ordering
-func RandomAssignmentWithOrdering(soln *tt.Solution, ordering []int) *tt.Solution {
+func RandomAssignmentWithOrdering(soln *tt.Solution, ordering []int, rng *rand.Rand) *tt.Solution {
 	domains := soln.MakeShrinkableDomains()
-	unassigned := make(map[int]bool)
+	unassigned := make([]int, 0)
 
 	for _, event := range ordering {
 		domainSize := len(domains[event])
 		if domainSize == 0 {
-			unassigned[event] = true
+			unassigned = append(unassigned, event)
 		} else {
+			// The remaining domain is walked in the order of the full domain
+			// rather than by ranging over the map so that the choice only
+			// depends on the random number generator.
 			var rat tt.Rat
-			offset := rand.Intn(len(domains[event]))
+			offset := rng.Intn(domainSize)
 			i := 0
 
-			for rat = range domains[event] {
-				if i == offset {
-					break
+			for _, rat = range soln.Domains[event] {
+				if domains[event][rat] {
+					if i == offset {
+						break
+					}
+					i++
 				}
-				i++
 			}
 
 			soln.AssignAndShrink(event, rat, domains)
 		}
 	}
 
-	for event := range unassigned {
-		ratIndex := rand.Intn(len(soln.Domains[event]))
+	for _, event := range unassigned {
+		if len(soln.Domains[event]) == 0 {
+			continue
+		}
+
+		ratIndex := rng.Intn(len(soln.Domains[event]))
 		soln.Assign(event, soln.Domains[event][ratIndex])
 	}
 
 	return soln
 }
 
-func OrderedWeightedAssignment(soln *tt.Solution, varOrdering []int, valWeights []map[tt.Rat]int) *tt.Solution {
-	unassigned := make(map[int]bool)
+func OrderedWeightedAssignment(soln *tt.Solution, varOrdering []int, valWeights []map[tt.Rat]int, rng *rand.Rand) *tt.Solution {
+	unassigned := make([]int, 0)
 	domains := soln.MakeShrinkableDomains()
 
 	for _, event := range varOrdering {
-		if len(domains[event]) == 0 {
-			unassigned[event] = true
-			continue
-		}
-
 		totalWeight := 0
 		var chosenRat tt.Rat
-		// We do a weighted reservoir sampling of size 1.
-		for rat, weight := range valWeights[event] {
-			if _, inDomain := domains[event][rat]; inDomain {
+		// We do a weighted reservoir sampling of size 1. The remaining domain
+		// is walked in the order of the full domain so that the choice only
+		// depends on the random number generator.
+		for _, rat := range soln.Domains[event] {
+			if weight := valWeights[event][rat]; domains[event][rat] && weight > 0 {
 				totalWeight += weight
-				if rand.Intn(totalWeight) < weight {
+				if rng.Intn(totalWeight) < weight {
 					chosenRat = rat
 				}
 			}
 		}
 
+		if totalWeight == 0 {
+			unassigned = append(unassigned, event)
+			continue
+		}
+
 		soln.AssignAndShrink(event, chosenRat, domains)
 	}
 
-	for event := range unassigned {
-		ratIndex := rand.Intn(len(soln.Domains[event]))
+	for _, event := range unassigned {
+		if len(soln.Domains[event]) == 0 {
+			continue
+		}
+
+		ratIndex := rng.Intn(len(soln.Domains[event]))
 		soln.Assign(event, soln.Domains[event][ratIndex])
 	}
 
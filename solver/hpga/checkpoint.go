@@ -0,0 +1,182 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package hpga
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/brennie/spaghetti/solver/hpga/population"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The state of an island. Islands capture their state right after a selection,
+// which is the only time that none of their slaves are modifying the
+// population.
+type islandState struct {
+	Population population.State  // The island's population.
+	VarWeights tt.WeightedValues // The metaheuristic's variable weights, or nil if it has not started.
+	ValWeights []map[tt.Rat]int  // The metaheuristic's value weights, or nil if it has not started.
+}
+
+// A checkpoint of an HPGA run, from which the run can be resumed.
+type checkpoint struct {
+	NEvents     int           // The number of events in the instance.
+	Fingerprint uint64        // The fingerprint of the instance.
+	NIslands    int           // The number of islands.
+	NSlaves     int           // The number of slaves per island.
+	MinPop      int           // The minimum population of each slave.
+	MaxPop      int           // The maximum population of each slave.
+	Seed        int64         // The seed for the random number generators of the resumed run.
+	Top         []tt.Rat      // The assignments of the best solution found.
+	Islands     []islandState // The state of each island.
+}
+
+// An island restored from a checkpoint.
+type restoredIsland struct {
+	pop      *population.Population // The island's population.
+	topValue tt.Value               // The best value in the population.
+	mh       *metaheuristic         // The island's metaheuristic, or nil if it had not started.
+}
+
+// Capture the state of an island. This must only be called when the
+// population is not being modified.
+func (i *island) state() *islandState {
+	state := &islandState{
+		Population: i.pop.State(),
+	}
+
+	if i.mh != nil {
+		state.VarWeights = make(tt.WeightedValues, len(i.mh.varWeights))
+		copy(state.VarWeights, i.mh.varWeights)
+
+		state.ValWeights = make([]map[tt.Rat]int, len(i.mh.valWeights))
+		for event := range i.mh.valWeights {
+			state.ValWeights[event] = make(map[tt.Rat]int)
+			for rat, weight := range i.mh.valWeights[event] {
+				state.ValWeights[event][rat] = weight
+			}
+		}
+	}
+
+	return state
+}
+
+// Determine if the checkpoint can be used to resume a run with the given
+// instance and configuration. The populations are checked when they are
+// restored.
+func (cp *checkpoint) check(inst *tt.Instance, cfg Config) error {
+	switch {
+	case cp.NEvents != inst.NEvents():
+		return fmt.Errorf("checkpoint is for an instance with %d events, not %d", cp.NEvents, inst.NEvents())
+
+	case cp.Fingerprint != inst.Fingerprint():
+		return fmt.Errorf("checkpoint is for a different instance, or one with different preprocessing, soft constraint weights, or extension")
+
+	case cp.NIslands != cfg.Islands:
+		return fmt.Errorf("checkpoint has %d islands, not %d", cp.NIslands, cfg.Islands)
+
+	case cp.NSlaves != cfg.Slaves:
+		return fmt.Errorf("checkpoint has %d slaves per island, not %d", cp.NSlaves, cfg.Slaves)
+
+	case cp.MinPop != cfg.MinPop || cp.MaxPop != cfg.MaxPop:
+		return fmt.Errorf("checkpoint has population sizes of %d to %d, not %d to %d", cp.MinPop, cp.MaxPop, cfg.MinPop, cfg.MaxPop)
+
+	case len(cp.Islands) != cp.NIslands:
+		return fmt.Errorf("checkpoint has state for %d islands, not %d", len(cp.Islands), cp.NIslands)
+
+	}
+
+	if err := inst.CheckRats(cp.Top); err != nil {
+		return fmt.Errorf("best solution: %s", err)
+	}
+
+	for i, state := range cp.Islands {
+		// The islands either all have the hill climbing weights or none do.
+		if (state.VarWeights == nil) != (cp.Islands[0].VarWeights == nil) {
+			return fmt.Errorf("island %d: weights do not match island 0", i)
+		} else if state.VarWeights != nil && (len(state.VarWeights) != inst.NEvents() || len(state.ValWeights) != inst.NEvents()) {
+			return fmt.Errorf("island %d: expected weights for %d events", i, inst.NEvents())
+		}
+	}
+
+	return nil
+}
+
+// Check the checkpoint against the instance and configuration, and restore the
+// islands from it. The value of every solution is computed from the instance.
+func (cp *checkpoint) restore(inst *tt.Instance, cfg Config) (islands []restoredIsland, err error) {
+	if err = cp.check(inst, cfg); err != nil {
+		return
+	}
+
+	islands = make([]restoredIsland, len(cp.Islands))
+	for i, state := range cp.Islands {
+		islands[i].pop = population.New(cfg.MinPop, cfg.MaxPop, cfg.Slaves)
+		if islands[i].topValue, err = islands[i].pop.Restore(inst, state.Population); err != nil {
+			return nil, fmt.Errorf("island %d: %s", i, err)
+		}
+
+		if state.VarWeights != nil {
+			islands[i].mh = newMH(state.VarWeights, state.ValWeights)
+		}
+	}
+
+	return
+}
+
+// Read a checkpoint from the given file.
+func readCheckpoint(filename string) (cp *checkpoint, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	cp = &checkpoint{}
+	if err = gob.NewDecoder(file).Decode(cp); err != nil {
+		cp = nil
+	}
+
+	return
+}
+
+// Write a checkpoint to the given file. The checkpoint is written to a
+// temporary file first so that a crash cannot leave a partial checkpoint
+// behind.
+func writeCheckpoint(filename string, cp *checkpoint) (err error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return
+	}
+
+	if err = gob.NewEncoder(tempFile).Encode(cp); err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return
+	}
+
+	if err = tempFile.Close(); err != nil {
+		os.Remove(tempFile.Name())
+		return
+	}
+
+	return os.Rename(tempFile.Name(), filename)
+}
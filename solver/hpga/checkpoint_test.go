@@ -0,0 +1,142 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package hpga
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Run the HPGA on the small instance until it times out, and return the final
+// checkpoint it writes.
+func runToCheckpoint(t *testing.T, inst *tt.Instance) (filename string, cp *checkpoint) {
+	filename = filepath.Join(t.TempDir(), "small.checkpoint")
+
+	cfg := smallConfig(1)
+	cfg.Ideal = true
+	cfg.Checkpoint = filename
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	if stop := solveAndStop(t, ctx, inst, cfg); stop.Reason != StopTimeout {
+		t.Skipf("stopped because %s before a checkpoint was written", stop.Reason)
+	}
+
+	cp, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func TestCheckpointResume(t *testing.T) {
+	inst := smallInstance(t)
+	filename, cp := runToCheckpoint(t, inst)
+
+	top := inst.SolutionFromRats(cp.Top)
+	defer top.Free()
+
+	cfg := smallConfig(2)
+	cfg.Ideal = true
+	cfg.Resume = filename
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	soln, value, err := cfg.Solve(ctx, inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer soln.Free()
+
+	// The resumed run starts from the best solution of the checkpoint.
+	if top.Value().Less(value) || soln.Value() != value {
+		t.Errorf("resuming from a checkpoint with best solution %s returned the value %s for a solution with value %s", top.Value(), value, soln.Value())
+	}
+}
+
+func TestCheckpointMismatch(t *testing.T) {
+	filename, _ := runToCheckpoint(t, smallInstance(t))
+
+	// Write a copy of the checkpoint, edited by the given function.
+	edited := func(edit func(cp *checkpoint)) string {
+		cp, err := readCheckpoint(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		edit(cp)
+
+		editedFilename := filepath.Join(t.TempDir(), "edited.checkpoint")
+		if err = writeCheckpoint(editedFilename, cp); err != nil {
+			t.Fatal(err)
+		}
+
+		return editedFilename
+	}
+
+	tests := []struct {
+		name string
+		edit func(inst *tt.Instance, cfg *Config) error
+	}{
+		{"more islands", func(inst *tt.Instance, cfg *Config) error {
+			cfg.Islands++
+			return nil
+		}},
+
+		{"soft constraint weights", func(inst *tt.Instance, cfg *Config) error {
+			return inst.SetSoftWeights(tt.SoftWeights{"single_class_day": 2, "consecutive_classes": 1, "last_period": 1})
+		}},
+
+		{"extension", func(inst *tt.Instance, cfg *Config) error {
+			return inst.ReadExtension(strings.NewReader("lunch 4\n"))
+		}},
+
+		{"best solution outside its domain", func(inst *tt.Instance, cfg *Config) error {
+			cfg.Resume = edited(func(cp *checkpoint) { cp.Top[0] = tt.Rat{Room: 1000, Time: 0} })
+			return nil
+		}},
+
+		{"individual outside its domain", func(inst *tt.Instance, cfg *Config) error {
+			cfg.Resume = edited(func(cp *checkpoint) { cp.Islands[1].Population[0][0].Rats[0] = tt.Rat{Room: 0, Time: 1000} })
+			return nil
+		}},
+	}
+
+	for _, test := range tests {
+		inst := smallInstance(t)
+		cfg := smallConfig(1)
+		cfg.Resume = filename
+
+		if err := test.edit(inst, &cfg); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if soln, _, err := cfg.Solve(context.Background(), inst); err == nil {
+			t.Errorf("%s: got no error", test.name)
+			soln.Free()
+		}
+	}
+}
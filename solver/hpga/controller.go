@@ -18,42 +18,71 @@
 package hpga
 
 import (
+	"context"
 	"log"
-	"os"
-	"os/signal"
+	"math/rand"
 	"time"
 
-	"github.com/brennie/spaghetti/options"
 	"github.com/brennie/spaghetti/tt"
 )
 
 // A controller is just a parent; its children are the islands.
 type controller struct {
 	parent
-	inst     *tt.Instance // The timetabling instance
-	topValue tt.Value     // The value of the top-valued solution.
-	top      *tt.Solution // The top-valued solution
-	ideal    bool         // Are we looking for an ideal solution?
+	inst       *tt.Instance // The timetabling instance
+	topValue   tt.Value     // The value of the top-valued solution.
+	top        *tt.Solution // The top-valued solution
+	ideal      bool         // Are we looking for an ideal solution?
+	cfg        Config       // The configuration the HPGA was run with.
+	hasWeights bool         // Do the islands already have variable and value weights?
+	rng        *rand.Rand   // The controller's random number generator.
+	events     *eventQueue  // The queue progress events are emitted on.
+	stopReason string       // Why the HPGA is stopping.
+
+	states           map[int]islandState // The island states received for the pending checkpoint, or nil if there is none.
+	exitOnCheckpoint bool                // Should the controller exit once the pending checkpoint is written?
 }
 
 // Create a new controller. There will be nIslands islands, each with nSlaves
-// slaves.
-func newController(inst *tt.Instance, opts options.SolveOptions) *controller {
+// slaves. If cp is not nil, the controller resumes from it and the islands
+// resume from the given restored islands.
+func newController(inst *tt.Instance, cfg Config, cp *checkpoint, islands []restoredIsland, events *eventQueue) *controller {
 	fromChildren := make(chan message, 5)
+	master := rand.New(rand.NewSource(cfg.Seed))
 
 	c := &controller{
 		parent{
 			fromChildren,
-			make([]chan<- message, opts.NIslands),
+			make([]chan<- message, cfg.Islands),
+			nil,
 		},
 		inst,
 		tt.WorstValue(),
 		inst.NewSolution(),
-		opts.Ideal,
+		cfg.Ideal,
+		cfg,
+		false,
+		deriveRand(master),
+		events,
+		"",
+		nil,
+		false,
 	}
 
-	for i := 0; i < opts.NIslands; i++ {
-		c.parent.toChildren[i] = newIsland(i, inst, fromChildren, opts)
+	if cp != nil {
+		c.top.Free()
+		c.top = inst.SolutionFromRats(cp.Top)
+		c.topValue = c.top.Value()
+		c.hasWeights = cp.Islands[0].VarWeights != nil
+	}
+
+	for i := 0; i < cfg.Islands; i++ {
+		var restored *restoredIsland
+		if cp != nil {
+			restored = &islands[i]
+		}
+
+		c.parent.toChildren[i] = newIsland(i, inst, fromChildren, cfg, restored, deriveRand(master), events)
 	}
 
 	return c
@@ -70,13 +99,21 @@ func (c *controller) handleSolutionMessage(msg message) (shouldExit bool) {
 
 		log.Printf("Found new best solution: %s\n", c.topValue)
 
+		island := msg.source
+		if island < 0 {
+			island = -1
+		}
+		c.events.emit(Event{Kind: NewBestEvent, Island: island, Value: valueOf(c.topValue)})
+
 		if c.ideal && c.topValue.IsIdeal() {
 			log.Println("Found ideal solution. Stopping...")
+			c.stopReason = StopIdeal
 			c.stopChildren()
 
 			return true
 		} else if !c.ideal && c.topValue.IsValid() {
 			log.Println("Found valid solution. Stopping...")
+			c.stopReason = StopValid
 			c.stopChildren()
 
 			return true
@@ -94,65 +131,287 @@ func (c *controller) handleSolutionMessage(msg message) (shouldExit bool) {
 	return false
 }
 
-// Run the controller.
-func (c *controller) run(timeoutInterval int) (*tt.Solution, tt.Value) {
-	// Wait for islands to signal that their children have finished generating populations
-	c.wait()
+// Handle a message from the hill climbing operator.
+func (c *controller) handleHCMessage(msg message) (shouldExit bool) {
+	switch msg.messageType() {
+	case solutionMessageType:
+		return c.handleSolutionMessage(msg)
 
-	log.Println("Population generation finished")
+	case weightMessageType:
+		for child := range c.toChildren {
+			c.sendToChild(child, msg.content)
+		}
+
+		c.events.emit(Event{Kind: WeightEvent, Island: -1})
+	}
+
+	return false
+}
+
+// Request the state of every island for a checkpoint. The islands respond
+// after their next selection, so other messages are handled as usual in the
+// meantime. Nothing is done if a checkpoint is already pending.
+func (c *controller) requestCheckpoint() {
+	if c.states != nil {
+		return
+	}
+
+	c.states = make(map[int]islandState)
+
+	for child := range c.toChildren {
+		c.sendToChild(child, checkpointMessage{})
+	}
+}
+
+// Handle an island's response to a checkpoint request. Once every island has
+// responded, the checkpoint is written. If the controller should exit after
+// the checkpoint, shouldExit will be true.
+func (c *controller) handleCheckpointMessage(msg message) (shouldExit bool) {
+	if c.states == nil {
+		return false
+	}
+
+	c.states[msg.source] = *msg.content.(checkpointMessage).state
+	if len(c.states) != len(c.toChildren) {
+		return false
+	}
 
-	var timeout <-chan time.Time
-	if timeoutInterval == 0 {
-		// We make a channel so that we never have to worry about receiving on it.
-		timeout = make(chan time.Time)
+	states := make([]islandState, len(c.toChildren))
+	for island, state := range c.states {
+		states[island] = state
+	}
+	c.states = nil
+
+	// The resumed run cannot pick up the random number generators where they
+	// left off, so it gets a new seed drawn from the controller's.
+	cp := &checkpoint{
+		c.inst.NEvents(),
+		c.inst.Fingerprint(),
+		c.cfg.Islands,
+		c.cfg.Slaves,
+		c.cfg.MinPop,
+		c.cfg.MaxPop,
+		c.rng.Int63(),
+		c.top.Assignments(),
+		states,
+	}
+
+	if err := writeCheckpoint(c.cfg.Checkpoint, cp); err != nil {
+		log.Printf("Could not write checkpoint: %s\n", err)
 	} else {
-		timeout = time.After(time.Duration(timeoutInterval) * time.Minute)
+		log.Printf("Wrote checkpoint to %s\n", c.cfg.Checkpoint)
 	}
 
-	signals := make(chan os.Signal)
-	signal.Notify(signals, os.Interrupt)
+	if c.exitOnCheckpoint {
+		c.stopChildren()
+	}
+
+	return c.exitOnCheckpoint
+}
+
+// Determine the reason the HPGA stops when its context is done with the given
+// error.
+func stopReasonOf(err error) string {
+	if err == context.DeadlineExceeded {
+		return StopTimeout
+	}
+
+	return StopCancelled
+}
+
+// Handle the context being done. If checkpoints are enabled, the HPGA stops
+// once the final checkpoint is written. If the controller should exit now,
+// shouldExit will be true.
+func (c *controller) handleDone(err error) (shouldExit bool) {
+	log.Printf("Stopping: %s\n", err)
+	c.stopReason = stopReasonOf(err)
+
+	if c.cfg.Checkpoint == "" {
+		c.stopChildren()
+		return true
+	}
+
+	log.Println("Writing checkpoint before stopping...")
+	c.requestCheckpoint()
+	c.exitOnCheckpoint = true
+
+	return false
+}
+
+// Run the controller until a good enough solution is found or the context is
+// done. The islands, slaves, and hill climbing operator have all exited by the
+// time this returns.
+func (c *controller) run(ctx context.Context) (*tt.Solution, tt.Value) {
+	// Wait for islands to signal that their children have finished generating
+	// populations. They stop early if the context is done, in which case the
+	// populations may be incomplete and cannot be checkpointed.
+	c.wait(ctx.Done())
+
+	if err := ctx.Err(); err != nil {
+		log.Printf("Stopping during population generation: %s\n", err)
+		c.stopReason = stopReasonOf(err)
+		c.stopChildren()
+		c.events.emit(Event{Kind: StopEvent, Island: -1, Value: valueOf(c.topValue), Reason: c.stopReason})
+
+		return c.top, c.topValue
+	}
+
+	log.Println("Population generation finished")
+
+	// We never receive on a nil channel, so nothing happens if checkpoints are
+	// off.
+	var checkpoints <-chan time.Time
+	if c.cfg.Checkpoint != "" {
+		ticker := time.NewTicker(c.cfg.CheckpointInterval)
+		defer ticker.Stop()
+		checkpoints = ticker.C
+	}
 
 	hc := make(chan message)
+	hcCtx, cancelHC := context.WithCancel(ctx)
+	hcDone := make(chan struct{})
+
+	// A resumed run already has the weights from the hill climbing.
+	if c.hasWeights {
+		close(hcDone)
+	} else {
+		go func() {
+			runHillClimbing(hcCtx, c.inst, deriveRand(c.rng), hc, c.cfg.HCTries, c.cfg.HCCutOff)
+			close(hcDone)
+		}()
+	}
+
+	if c.cfg.Deterministic {
+		c.runDeterministic(ctx, hc, checkpoints)
+	} else {
+		c.runConcurrent(ctx, hc, checkpoints)
+	}
 
-	go runHillClimbing(c.inst, hc)
+	cancelHC()
+	<-hcDone
+
+	c.events.emit(Event{Kind: StopEvent, Island: -1, Value: valueOf(c.topValue), Reason: c.stopReason})
+
+	return c.top, c.topValue
+}
+
+// Handle a message from an island and return whether the HPGA should stop.
+func (c *controller) handleChildMessage(msg message) (shouldExit bool) {
+	switch msg.messageType() {
+	case solutionMessageType:
+		return c.handleSolutionMessage(msg)
+
+	case checkpointMessageType:
+		return c.handleCheckpointMessage(msg)
+	}
+
+	return false
+}
+
+// Handle messages from the islands and the hill climbing operator in whatever
+// order they arrive until the HPGA should stop.
+func (c *controller) runConcurrent(ctx context.Context, hc <-chan message, checkpoints <-chan time.Time) {
+	done := ctx.Done()
 
-msgLoop:
 	for {
+		// The messages received while sending to an island come first.
+		if c.hasPending() {
+			if shouldExit := c.handleChildMessage(c.receive()); shouldExit {
+				return
+			}
+
+			continue
+		}
+
 		select {
 		case msg := <-c.fromChildren:
-			switch msg.messageType() {
-			case solutionMessageType:
-				if shouldExit := c.handleSolutionMessage(msg); shouldExit {
-					break msgLoop
-				}
+			if shouldExit := c.handleChildMessage(msg); shouldExit {
+				return
 			}
 
 		case msg := <-hc:
-			switch msg.messageType() {
-			case solutionMessageType:
-				if shouldExit := c.handleSolutionMessage(msg); shouldExit {
-					break msgLoop
-				}
+			if shouldExit := c.handleHCMessage(msg); shouldExit {
+				return
+			}
 
-			case weightMessageType:
-				msg.source = parentID
-				for child := range c.toChildren {
-					c.toChildren[child] <- msg
-				}
+		case <-checkpoints:
+			c.requestCheckpoint()
+
+		case <-done:
+			if shouldExit := c.handleDone(ctx.Err()); shouldExit {
+				return
 			}
 
-		case <-timeout:
-			log.Println("Timeout: stopping...")
-			c.stopChildren()
-			break msgLoop
+			// The context stays done, so stop checking it while the final
+			// checkpoint is collected.
+			done = nil
+		}
+	}
+}
+
+// Run the HPGA in lockstep until it should stop. The hill climbing operator
+// finishes first, and then the islands are told to take a step one at a time,
+// in order. Since each island steps its slaves in order as well, the messages
+// that determine the search always arrive in the same order. The context and
+// checkpoints are only checked between steps.
+func (c *controller) runDeterministic(ctx context.Context, hc <-chan message, checkpoints <-chan time.Time) {
+	done := ctx.Done()
 
-		case <-signals:
-			log.Println("Caught interrupt")
-			break msgLoop
+	for waiting := !c.hasWeights; waiting && !c.exitOnCheckpoint; {
+		select {
+		case msg := <-hc:
+			waiting = msg.messageType() != weightMessageType
+			if shouldExit := c.handleHCMessage(msg); shouldExit {
+				return
+			}
+
+		case <-done:
+			if shouldExit := c.handleDone(ctx.Err()); shouldExit {
+				return
+			}
+
+			done = nil
 		}
 	}
 
-	return c.top, c.topValue
+	for {
+		for island := range c.toChildren {
+			select {
+			case <-checkpoints:
+				c.requestCheckpoint()
+
+			case <-done:
+				if shouldExit := c.handleDone(ctx.Err()); shouldExit {
+					return
+				}
+
+				done = nil
+
+			default:
+			}
+
+			c.sendToChild(island, stepMessage{})
+
+			for stepped := false; !stepped; {
+				msg := c.receive()
+
+				switch msg.messageType() {
+				case solutionMessageType:
+					if shouldExit := c.handleSolutionMessage(msg); shouldExit {
+						return
+					}
+
+				case checkpointMessageType:
+					if shouldExit := c.handleCheckpointMessage(msg); shouldExit {
+						return
+					}
+
+				case steppedMessageType:
+					stepped = true
+				}
+			}
+		}
+	}
 }
 
 // Send a stopMessageType message to all islands under the controller and wait for
@@ -167,7 +426,7 @@ func (c *controller) stopChildren() {
 	finished := make(map[int]bool)
 
 	for len(finished) != len(c.toChildren) {
-		msg := <-c.fromChildren
+		msg := c.receive()
 
 		switch msg.messageType() {
 		case finMessageType:
@@ -0,0 +1,164 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package hpga
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The kind of a progress event.
+type EventKind string
+
+const (
+	NewBestEvent   EventKind = "new-best"   // A new best solution was found.
+	SelectionEvent EventKind = "selection"  // An island performed a selection.
+	GMEvent        EventKind = "gm"         // An island used a batch of individuals from its GM operator.
+	WeightEvent    EventKind = "weights"    // The hill climbing operator sent its weights to the islands.
+	CrossoverEvent EventKind = "crossovers" // The operations an island's slaves performed before a selection.
+	StopEvent      EventKind = "stop"       // The HPGA stopped.
+)
+
+// The reason the HPGA stopped.
+const (
+	StopValid     = "valid"     // A valid solution was found.
+	StopIdeal     = "ideal"     // An ideal solution was found.
+	StopTimeout   = "timeout"   // The context's deadline passed.
+	StopCancelled = "cancelled" // The context was cancelled.
+)
+
+// A progress event. Which fields are set depends on the kind of the event.
+type Event struct {
+	Time   time.Time `json:"time"`   // When the event happened.
+	Kind   EventKind `json:"kind"`   // The kind of event.
+	Island int       `json:"island"` // The island the event happened on, or -1 if it did not happen on an island.
+
+	// The value of the new best solution, of the island's best solution after
+	// a selection, of the best individual in a GM batch, or of the final
+	// solution.
+	Value *tt.Value `json:"value,omitempty"`
+
+	Generation int `json:"generation,omitempty"` // The number of selections the island has performed.
+	Generated  int `json:"generated,omitempty"`  // The number of individuals in a GM batch.
+
+	Mutations         int `json:"mutations,omitempty"`          // The number of mutations since the last selection.
+	LocalCrossovers   int `json:"local_crossovers,omitempty"`   // The number of local crossovers since the last selection.
+	ForeignCrossovers int `json:"foreign_crossovers,omitempty"` // The number of foreign crossovers since the last selection.
+
+	Reason string `json:"reason,omitempty"` // Why the HPGA stopped.
+}
+
+// A function that receives progress events. A listener is only ever called
+// from one goroutine at a time, in the order the events were emitted.
+type Listener func(Event)
+
+// Create a listener that writes each event to the writer as a line of JSON.
+func NewEventWriter(w io.Writer) Listener {
+	encoder := json.NewEncoder(w)
+
+	return func(e Event) {
+		if err := encoder.Encode(e); err != nil {
+			log.Printf("Could not write event: %s\n", err)
+		}
+	}
+}
+
+// Get a pointer to a copy of the value for an event.
+func valueOf(value tt.Value) *tt.Value {
+	return &value
+}
+
+// A queue of events waiting to be delivered to a listener. The controller and
+// islands emit events from their own goroutines, so a single goroutine
+// delivers them in order. A nil queue discards every event.
+type eventQueue struct {
+	events chan Event    // The events waiting to be delivered.
+	quit   chan struct{} // Closed when no more events should be delivered.
+	done   chan struct{} // Closed once the remaining events have been delivered.
+}
+
+// Create a queue that delivers events to the listener. If the listener is
+// nil, the queue is nil.
+func newEventQueue(listener Listener) *eventQueue {
+	if listener == nil {
+		return nil
+	}
+
+	q := &eventQueue{
+		make(chan Event, 256),
+		make(chan struct{}),
+		make(chan struct{}),
+	}
+
+	go q.deliver(listener)
+
+	return q
+}
+
+// Deliver events to the listener until the queue is closed, and then deliver
+// whatever is left.
+func (q *eventQueue) deliver(listener Listener) {
+	defer close(q.done)
+
+	for {
+		select {
+		case e := <-q.events:
+			listener(e)
+
+		case <-q.quit:
+			for {
+				select {
+				case e := <-q.events:
+					listener(e)
+
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Emit an event, timestamping it. Events emitted after the queue is closed
+// are dropped.
+func (q *eventQueue) emit(e Event) {
+	if q == nil {
+		return
+	}
+
+	e.Time = time.Now()
+
+	select {
+	case q.events <- e:
+	case <-q.quit:
+	}
+}
+
+// Close the queue and wait for the remaining events to be delivered.
+func (q *eventQueue) close() {
+	if q == nil {
+		return
+	}
+
+	close(q.quit)
+	<-q.done
+}
@@ -0,0 +1,121 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package hpga
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+func TestEventWriterFormat(t *testing.T) {
+	var buf bytes.Buffer
+	write := NewEventWriter(&buf)
+
+	at := time.Date(2014, 3, 1, 12, 0, 0, 100000000, time.UTC)
+	write(Event{Time: at, Kind: NewBestEvent, Island: 0, Value: valueOf(tt.Value{Violations: 74, Fitness: 237})})
+	write(Event{Time: at, Kind: CrossoverEvent, Island: 1, Generation: 1, Mutations: 1, LocalCrossovers: 38, ForeignCrossovers: 11})
+	write(Event{Time: at, Kind: StopEvent, Island: -1, Value: valueOf(tt.Value{Violations: 0, Fitness: 202}), Reason: StopValid})
+
+	want := `{"time":"2014-03-01T12:00:00.1Z","kind":"new-best","island":0,"value":{"violations":74,"fitness":237}}
+{"time":"2014-03-01T12:00:00.1Z","kind":"crossovers","island":1,"generation":1,"mutations":1,"local_crossovers":38,"foreign_crossovers":11}
+{"time":"2014-03-01T12:00:00.1Z","kind":"stop","island":-1,"value":{"violations":0,"fitness":202},"reason":"valid"}
+`
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEventWriterRun(t *testing.T) {
+	inst := smallInstance(t)
+
+	var buf bytes.Buffer
+	cfg := smallConfig(1)
+	cfg.Ideal = true
+	cfg.Listener = NewEventWriter(&buf)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	soln, value, err := cfg.Solve(ctx, inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soln.Free()
+
+	// The fields each kind of event may have besides time, kind, and island.
+	fields := map[EventKind]map[string]bool{
+		NewBestEvent:   {"value": true},
+		WeightEvent:    {},
+		CrossoverEvent: {"generation": true, "mutations": true, "local_crossovers": true, "foreign_crossovers": true},
+		GMEvent:        {"value": true, "generated": true},
+		SelectionEvent: {"value": true, "generation": true},
+		StopEvent:      {"value": true, "reason": true},
+	}
+
+	var last Event
+	lines := 0
+	for scanner := bufio.NewScanner(&buf); scanner.Scan(); lines++ {
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			t.Fatalf("line %d: %s", lines+1, err)
+		}
+
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %d: %s", lines+1, err)
+		}
+
+		for _, field := range []string{"time", "kind", "island"} {
+			if _, ok := raw[field]; !ok {
+				t.Errorf("line %d has no %s: %s", lines+1, field, scanner.Text())
+			}
+		}
+
+		allowed, ok := fields[e.Kind]
+		if !ok {
+			t.Fatalf("line %d has the unknown kind %q", lines+1, e.Kind)
+		}
+
+		for field := range raw {
+			if field != "time" && field != "kind" && field != "island" && !allowed[field] {
+				t.Errorf("line %d has the field %s, which %s events do not have: %s", lines+1, field, e.Kind, scanner.Text())
+			}
+		}
+
+		if last.Kind == StopEvent {
+			t.Errorf("line %d comes after the stop event", lines+1)
+		} else if e.Time.Before(last.Time) {
+			t.Errorf("line %d happened at %s, before the line before it (%s)", lines+1, e.Time, last.Time)
+		}
+
+		last = e
+	}
+
+	if lines == 0 {
+		t.Fatal("no events were written")
+	} else if last.Kind != StopEvent || last.Island != -1 || last.Value == nil || *last.Value != value {
+		t.Errorf("the last event is %+v; want a stop event with the value %s", last, value)
+	}
+}
@@ -18,21 +18,20 @@
 package hpga
 
 import (
+	"context"
+	"math/rand"
 	"sort"
 
 	"github.com/brennie/spaghetti/solver/heuristics"
 	"github.com/brennie/spaghetti/tt"
 )
 
-const (
-	maxTries = 1000 // The maximum number of iterations
-	cutOff   = 50   // The cut off for a specific solution
-)
-
 // Run hill-climbing optimzation to build a static variable ordering and
 // generate weights for each variable's values. The higher the weight of a
-// value, the better that value has been determined to be.
-func runHillClimbing(inst *tt.Instance, report chan<- message) {
+// value, the better that value has been determined to be. At most maxTries
+// moves are made in total, and at most cutOff from each random solution. The
+// hill climbing is abandoned once the context is done.
+func runHillClimbing(ctx context.Context, inst *tt.Instance, rng *rand.Rand, report chan<- message, maxTries, cutOff int) {
 	valWeights := make([]map[tt.Rat]int, inst.NEvents())
 	varWeights := make(tt.WeightedValues, inst.NEvents())
 	varViolations := make([]int, inst.NEvents())
@@ -49,22 +48,27 @@ func runHillClimbing(inst *tt.Instance, report chan<- message) {
 	}
 
 	for global := 0; global < maxTries; {
-		soln := heuristics.RandomAssignment(inst.NewSolution())
+		if ctx.Err() != nil {
+			return
+		}
+
+		soln := heuristics.RandomAssignment(inst.NewSolution(), rng)
 		found := false
 		nSolutions++
 		for local := 0; local < cutOff && global < maxTries; local++ {
 			global++
 
-			if event, rat := soln.FindImprovement(); event != -1 {
-				soln.Assign(event, rat)
+			if move := soln.FindImprovement(rng); move != nil {
+				soln.Apply(move)
 			} else {
 				break // We've reached a local minimum
 			}
 
-			if violations := soln.Violations(); violations == 0 {
+			if value := soln.Value(); value.IsValid() {
 				found = true
-				fitness := soln.Fitness()
-				send(report, hcID, solutionMessage{soln.Assignments(), tt.Value{violations, fitness}})
+				if !sendContext(ctx, report, hcID, solutionMessage{soln.Assignments(), value}) {
+					return
+				}
 				break
 			}
 		}
@@ -100,14 +104,14 @@ func runHillClimbing(inst *tt.Instance, report chan<- message) {
 		}
 	}
 
-	send(report, hcID, weightMessage{varWeights, valWeights})
+	sendContext(ctx, report, hcID, weightMessage{varWeights, valWeights})
 }
 
 // Run the genetic modification operator for the island. The GM operator will
 // wait for requests to generate count individuals to be sent on the report
 // channel. The same slice will be used to send every report so it should not
 // be modified by the island.
-func (i *island) runGM(count int, requests <-chan bool, report chan<- bool) {
+func (i *island) runGM(count int, requests <-chan bool, report chan<- bool, rng *rand.Rand) {
 	for {
 		if <-requests == false {
 			break
@@ -116,7 +120,7 @@ func (i *island) runGM(count int, requests <-chan bool, report chan<- bool) {
 		valWeights := i.mh.valWeights
 		for individual := range i.generated {
 
-			i.generated[individual].Soln = heuristics.OrderedWeightedAssignment(i.inst.NewSolution(), varOrdering, valWeights)
+			i.generated[individual].Soln = heuristics.OrderedWeightedAssignment(i.inst.NewSolution(), varOrdering, valWeights, rng)
 			i.generated[individual].Value = i.generated[individual].Soln.Value()
 		}
 		report <- true
@@ -19,9 +19,14 @@
 package hpga
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
 	"sync"
+	"time"
 
-	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/solver/anneal"
 	"github.com/brennie/spaghetti/tt"
 )
 
@@ -30,6 +35,7 @@ import (
 type parent struct {
 	fromChildren <-chan message   // Receive channel from children
 	toChildren   []chan<- message // Send channels for children
+	pending      []message        // Messages from children received while sending to a child
 }
 
 // A child process, which has a parent that it communicates to through
@@ -46,18 +52,148 @@ func (c *child) fin() {
 	c.sendToParent(finMessage{})
 }
 
-// Run the HPGA.
-func Run(inst *tt.Instance, opts options.SolveOptions) (*tt.Solution, tt.Value) {
-	return newController(inst, opts).run(opts.Timeout)
+// The configuration of an HPGA run.
+type Config struct {
+	Islands       int   // The number of islands.
+	Slaves        int   // The number of slaves per island.
+	MinPop        int   // The minimum population of each slave.
+	MaxPop        int   // The maximum population of each slave.
+	Seed          int64 // The seed for the random number generators.
+	Ideal         bool  // Should the HPGA stop at an ideal solution (true) or merely a valid one (false)?
+	Deterministic bool  // Should the HPGA schedule its goroutines in a fixed order?
+
+	AnnealSteps     int    // The number of annealing moves slaves make on each new individual.
+	Schedule        string // The annealing cooling schedule.
+	ViolationWeight int    // The weight of a violation relative to fitness when annealing.
+
+	Checkpoint         string        // The file to write checkpoints to, if any.
+	CheckpointInterval time.Duration // The time between checkpoints.
+	Resume             string        // The checkpoint to resume from, if any.
+
+	PMutate   int     // The probability, in percent, that a slave's step is a mutation.
+	PLocal    int     // The probability, in percent, that a slave's step is a local crossover; the rest are foreign crossovers.
+	MaxMutate float64 // The largest share of an individual's events that a mutation changes.
+	HCTries   int     // The number of hill climbing moves made to weight the events and their values.
+	HCCutOff  int     // The number of hill climbing moves made from each random solution.
+	GMRatio   float64 // The share of each slave's minimum population that the GM operator generates for each selection.
+
+	Listener Listener // The listener for progress events, if any.
+}
+
+// The default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Islands:            2,
+		Slaves:             2,
+		MinPop:             50,
+		MaxPop:             75,
+		Schedule:           "geometric",
+		ViolationWeight:    100,
+		CheckpointInterval: 10 * time.Minute,
+		PMutate:            5,
+		PLocal:             75,
+		MaxMutate:          0.2,
+		HCTries:            1000,
+		HCCutOff:           50,
+		GMRatio:            0.05,
+	}
+}
+
+// Determine if the configuration is usable.
+func (cfg Config) validate() error {
+	switch {
+	case cfg.Islands < 2:
+		return fmt.Errorf("there must be at least 2 islands, not %d", cfg.Islands)
+
+	case cfg.Slaves < 2:
+		return fmt.Errorf("there must be at least 2 slaves per island, not %d", cfg.Slaves)
+
+	case cfg.MinPop < 2:
+		return fmt.Errorf("the minimum population must be at least 2, not %d", cfg.MinPop)
+
+	case cfg.MaxPop <= cfg.MinPop:
+		return fmt.Errorf("the maximum population (%d) must exceed the minimum population (%d)", cfg.MaxPop, cfg.MinPop)
+
+	case cfg.AnnealSteps < 0:
+		return fmt.Errorf("the number of annealing steps must be non-negative, not %d", cfg.AnnealSteps)
+
+	case cfg.ViolationWeight < 1 && cfg.AnnealSteps > 0:
+		return fmt.Errorf("the violation weight must be at least 1, not %d", cfg.ViolationWeight)
+
+	case cfg.Checkpoint != "" && cfg.CheckpointInterval <= 0:
+		return fmt.Errorf("the checkpoint interval must be positive, not %s", cfg.CheckpointInterval)
+
+	case cfg.PMutate < 0 || cfg.PLocal < 0 || cfg.PMutate+cfg.PLocal > 100:
+		return fmt.Errorf("the mutation (%d%%) and local crossover (%d%%) probabilities must be non-negative and total at most 100%%", cfg.PMutate, cfg.PLocal)
+
+	case cfg.MaxMutate <= 0 || cfg.MaxMutate > 1:
+		return fmt.Errorf("the largest share of an individual to mutate must be in (0, 1], not %g", cfg.MaxMutate)
+
+	case cfg.HCTries < 0:
+		return fmt.Errorf("the number of hill climbing moves must be non-negative, not %d", cfg.HCTries)
+
+	case cfg.HCCutOff < 1:
+		return fmt.Errorf("the number of hill climbing moves from each solution must be at least 1, not %d", cfg.HCCutOff)
+
+	case cfg.GMRatio < 0 || cfg.GMRatio > 1:
+		return fmt.Errorf("the share of the population the GM operator generates must be in [0, 1], not %g", cfg.GMRatio)
+	}
+
+	if cfg.AnnealSteps > 0 {
+		return anneal.CheckSchedule(cfg.Schedule)
+	}
+
+	return nil
+}
+
+// Run the HPGA on the instance until a good enough solution is found or the
+// context is done, and return the best solution found. If the configuration
+// names a checkpoint to resume from, the run continues from the state stored
+// in it. Every goroutine the HPGA starts has exited by the time this returns.
+func (cfg Config) Solve(ctx context.Context, inst *tt.Instance) (*tt.Solution, tt.Value, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, tt.WorstValue(), err
+	}
+
+	var cp *checkpoint
+	var islands []restoredIsland
+
+	if cfg.Resume != "" {
+		var err error
+
+		if cp, err = readCheckpoint(cfg.Resume); err != nil {
+			return nil, tt.WorstValue(), fmt.Errorf("could not read checkpoint %s: %s", cfg.Resume, err)
+		} else if islands, err = cp.restore(inst, cfg); err != nil {
+			return nil, tt.WorstValue(), fmt.Errorf("could not resume from %s: %s", cfg.Resume, err)
+		}
+
+		log.Printf("Resuming from %s (seed %d)\n", cfg.Resume, cp.Seed)
+		cfg.Seed = cp.Seed
+	}
+
+	events := newEventQueue(cfg.Listener)
+	defer events.close()
+
+	soln, value := newController(inst, cfg, cp, islands, events).run(ctx)
+
+	return soln, value, nil
+}
+
+// Create a random number generator whose seed is drawn from the given one.
+// Every goroutine owns its own generator, and they are all created in a fixed
+// order, so that they are all determined by the seed of the run.
+func deriveRand(rng *rand.Rand) *rand.Rand {
+	return rand.New(rand.NewSource(rng.Int63()))
 }
 
-// Wait for children
-func (p *parent) wait() {
+// Wait for children to generate their populations. They stop early if done is
+// closed.
+func (p *parent) wait(done <-chan struct{}) {
 	wg := &sync.WaitGroup{}
 
 	for child := range p.toChildren {
 		wg.Add(1)
-		p.sendToChild(child, waitMessage{wg})
+		p.sendToChild(child, waitMessage{wg, done})
 	}
 
 	wg.Wait()
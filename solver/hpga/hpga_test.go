@@ -0,0 +1,361 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package hpga
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The longest a run may take to stop once its context is done.
+const stopTimeout = 5 * time.Second
+
+// Read the small instance used by the tests.
+func smallInstance(tb testing.TB) *tt.Instance {
+	f, err := os.Open("testdata/small.tim")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	inst, err := tt.Parse(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return inst
+}
+
+// Create a configuration for a short run on the small instance.
+func smallConfig(seed int64) Config {
+	cfg := DefaultConfig()
+	cfg.MinPop = 10
+	cfg.MaxPop = 15
+	cfg.Seed = seed
+
+	return cfg
+}
+
+// Run the HPGA and fail if it does not stop in time once the context is done.
+// The stop event is returned.
+func solveAndStop(t *testing.T, ctx context.Context, inst *tt.Instance, cfg Config) (stop Event) {
+	var stops []Event
+	cfg.Listener = func(e Event) {
+		if e.Kind == StopEvent {
+			stops = append(stops, e)
+		}
+	}
+
+	type result struct {
+		soln  *tt.Solution
+		value tt.Value
+		err   error
+	}
+
+	done := make(chan result)
+	go func() {
+		soln, value, err := cfg.Solve(ctx, inst)
+		done <- result{soln, value, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+
+	case <-time.After(stopTimeout):
+		<-ctx.Done()
+
+		select {
+		case r = <-done:
+
+		case <-time.After(stopTimeout):
+			buf := make([]byte, 1<<20)
+			t.Fatalf("the HPGA did not stop within %s of its context being done:\n%s", stopTimeout, buf[:runtime.Stack(buf, true)])
+		}
+	}
+
+	// A run that is stopped before any island reports a solution returns an
+	// empty solution with the worst value.
+	if r.err != nil {
+		t.Fatal(r.err)
+	} else if r.soln == nil {
+		t.Fatal("the HPGA did not return a solution")
+	} else if r.value != tt.WorstValue() && r.soln.Value() != r.value {
+		t.Errorf("the HPGA returned the value %s for a solution with value %s", r.value, r.soln.Value())
+	}
+
+	if len(stops) != 1 {
+		t.Fatalf("got %d stop events; want 1", len(stops))
+	}
+
+	return stops[0]
+}
+
+// Wait for the number of goroutines to drop back to the given number, and fail
+// if it does not.
+func checkGoroutines(t *testing.T, want int) {
+	deadline := time.Now().Add(stopTimeout)
+
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("%d goroutines are still running; want %d:\n%s", runtime.NumGoroutine(), want, buf[:runtime.Stack(buf, true)])
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSolveValid(t *testing.T) {
+	inst := smallInstance(t)
+	before := runtime.NumGoroutine()
+
+	for _, deterministic := range []bool{false, true} {
+		cfg := smallConfig(1)
+		cfg.Deterministic = deterministic
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		stop := solveAndStop(t, ctx, inst, cfg)
+		cancel()
+
+		if stop.Reason != StopValid {
+			t.Errorf("deterministic %t: stopped because %s; want %s", deterministic, stop.Reason, StopValid)
+		}
+
+		checkGoroutines(t, before)
+	}
+}
+
+func TestSolveStops(t *testing.T) {
+	inst := smallInstance(t)
+	before := runtime.NumGoroutine()
+
+	// An ideal solution is unlikely, so these runs only stop when their
+	// contexts are done.
+	for _, deterministic := range []bool{false, true} {
+		for _, delay := range []time.Duration{0, 10 * time.Millisecond, 500 * time.Millisecond} {
+			cfg := smallConfig(2)
+			cfg.Deterministic = deterministic
+			cfg.Ideal = true
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if delay == 0 {
+				cancel()
+			} else {
+				time.AfterFunc(delay, cancel)
+			}
+
+			stop := solveAndStop(t, ctx, inst, cfg)
+			cancel()
+
+			if stop.Reason != StopCancelled && stop.Reason != StopIdeal {
+				t.Errorf("deterministic %t, delay %s: stopped because %s; want %s", deterministic, delay, stop.Reason, StopCancelled)
+			}
+
+			checkGoroutines(t, before)
+		}
+	}
+}
+
+func TestSolveTimeout(t *testing.T) {
+	inst := smallInstance(t)
+	cfg := smallConfig(3)
+	cfg.Ideal = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if stop := solveAndStop(t, ctx, inst, cfg); stop.Reason != StopTimeout && stop.Reason != StopIdeal {
+		t.Errorf("stopped because %s; want %s", stop.Reason, StopTimeout)
+	}
+}
+
+func TestSolveStopsDuringGeneration(t *testing.T) {
+	inst := smallInstance(t)
+	before := runtime.NumGoroutine()
+
+	// Generating populations this large takes far longer than the timeout.
+	for _, deterministic := range []bool{false, true} {
+		cfg := smallConfig(4)
+		cfg.Deterministic = deterministic
+		cfg.MinPop = 1000000
+		cfg.MaxPop = 1000001
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		stop := solveAndStop(t, ctx, inst, cfg)
+		cancel()
+
+		if stop.Reason != StopTimeout {
+			t.Errorf("deterministic %t: stopped because %s; want %s", deterministic, stop.Reason, StopTimeout)
+		}
+
+		checkGoroutines(t, before)
+	}
+}
+
+func TestSolveCancelledDuringGeneration(t *testing.T) {
+	inst := smallInstance(t)
+	before := runtime.NumGoroutine()
+
+	// Cancelling shortly after the run starts often catches some slaves
+	// still generating their populations while others have finished and are
+	// asking for crossovers.
+	for _, deterministic := range []bool{false, true} {
+		for delay := time.Duration(0); delay < 5*time.Millisecond; delay += 250 * time.Microsecond {
+			cfg := smallConfig(5)
+			cfg.Deterministic = deterministic
+			cfg.Slaves = 8
+			cfg.Ideal = true
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(delay, cancel)
+
+			stop := solveAndStop(t, ctx, inst, cfg)
+			cancel()
+
+			if stop.Reason != StopCancelled && stop.Reason != StopIdeal {
+				t.Errorf("deterministic %t, delay %s: stopped because %s; want %s", deterministic, delay, stop.Reason, StopCancelled)
+			}
+
+			checkGoroutines(t, before)
+		}
+	}
+}
+
+func TestSolveManySlaves(t *testing.T) {
+	inst := smallInstance(t)
+	before := runtime.NumGoroutine()
+
+	// With more slaves than an island's channel holds and populations that
+	// fill up quickly, islands and slaves are often waiting to send to each
+	// other at once.
+	for seed := int64(1); seed <= 3; seed++ {
+		cfg := smallConfig(seed)
+		cfg.Islands = 6
+		cfg.Slaves = 8
+		cfg.MinPop = 10
+		cfg.MaxPop = 11
+		cfg.Ideal = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		solveAndStop(t, ctx, inst, cfg)
+		cancel()
+
+		checkGoroutines(t, before)
+	}
+}
+
+func TestDeterministicEvents(t *testing.T) {
+	inst := smallInstance(t)
+
+	// The number of events compared between runs.
+	const nEvents = 100
+
+	// Run the HPGA in deterministic mode, searching for an ideal solution, until
+	// it has emitted nEvents events, and return them without their times.
+	run := func(seed int64) (events []Event) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		cfg := smallConfig(seed)
+		cfg.Deterministic = true
+		cfg.Ideal = true
+		cfg.Listener = func(e Event) {
+			if len(events) < nEvents {
+				e.Time = time.Time{}
+				events = append(events, e)
+			} else {
+				cancel()
+			}
+		}
+
+		soln, _, err := cfg.Solve(ctx, inst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		soln.Free()
+
+		return
+	}
+
+	for seed := int64(1); seed <= 2; seed++ {
+		first, second := run(seed), run(seed)
+
+		if len(first) != nEvents || len(second) != nEvents {
+			t.Errorf("seed %d: got %d events and then %d; want %d", seed, len(first), len(second), nEvents)
+			continue
+		}
+
+		for i := range first {
+			if !reflect.DeepEqual(first[i], second[i]) {
+				t.Errorf("seed %d: event %d was %+v and then %+v", seed, i, first[i], second[i])
+				break
+			}
+		}
+	}
+}
+
+func TestSolveInvalidConfig(t *testing.T) {
+	inst := smallInstance(t)
+
+	tests := []struct {
+		name string
+		edit func(cfg *Config)
+	}{
+		{"one island", func(cfg *Config) { cfg.Islands = 1 }},
+		{"one slave", func(cfg *Config) { cfg.Slaves = 1 }},
+		{"no population", func(cfg *Config) { cfg.MinPop = 0 }},
+		{"population of one", func(cfg *Config) { cfg.MinPop, cfg.MaxPop = 1, 2 }},
+		{"small maximum population", func(cfg *Config) { cfg.MaxPop = cfg.MinPop }},
+		{"maximum below minimum population", func(cfg *Config) { cfg.MaxPop = cfg.MinPop - 1 }},
+		{"negative annealing", func(cfg *Config) { cfg.AnnealSteps = -1 }},
+		{"unknown schedule", func(cfg *Config) { cfg.AnnealSteps, cfg.Schedule = 1, "unknown" }},
+		{"missing checkpoint", func(cfg *Config) { cfg.Resume = "testdata/missing.checkpoint" }},
+	}
+
+	for _, test := range tests {
+		cfg := smallConfig(1)
+		test.edit(&cfg)
+
+		if soln, _, err := cfg.Solve(context.Background(), inst); err == nil {
+			t.Errorf("%s: got no error", test.name)
+			soln.Free()
+		}
+	}
+}
+
+// Benchmark a short run: solving the small instance until a valid solution is
+// found.
+func BenchmarkSolve(b *testing.B) {
+	inst := smallInstance(b)
+
+	for i := 0; i < b.N; i++ {
+		soln, _, err := smallConfig(int64(i)).Solve(context.Background(), inst)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		soln.Free()
+	}
+}
@@ -19,15 +19,11 @@ package hpga
 
 import (
 	"math/rand"
-	"time"
 
-	"github.com/brennie/spaghetti/options"
 	"github.com/brennie/spaghetti/solver/hpga/population"
 	"github.com/brennie/spaghetti/tt"
 )
 
-const gmInterval = 5 * time.Minute
-
 // An island is both a parent (slaves run under it) and a child (it runs under
 // the controller).
 type island struct {
@@ -40,27 +36,38 @@ type island struct {
 	gmRecv    <-chan bool            // Determine when the GM operator has finished.
 	gmSend    chan<- bool            // Channel to send GM requests.
 	generated []tt.Pair              // The list of individuals generated by the GM operator.
+	rng       *rand.Rand             // The island's random number generator.
+
+	crossovers    map[int]int // Map crossover id -> child id
+	fullChildren  []bool      // The children which have filled their sub-populations.
+	nFullChildren int         // The number of children which have filled their sub-populations.
+
+	checkpointRequested bool // Has the controller requested the island's state?
 
-	fullChildren  []bool // The children which have filled their sub-populations.
-	nFullChildren int    // The number of children which have filled their sub-populations.
+	deterministic bool   // Does the island only take steps when the controller tells it to?
+	stepping      []bool // The children which have not finished their last step.
+
+	events     *eventQueue // The queue progress events are emitted on.
+	generation int         // The number of selections the island has performed.
 }
 
 // Create a new island with the given id and number of slaves. The given
 // channel is the channel the island should use to communicate with the
 // controller. The channel returned is the channel the controller should use to
-// communicate with the i.
-func newIsland(id int, inst *tt.Instance, toParent chan<- message, opts options.SolveOptions) chan<- message {
+// communicate with the i. If restored is not nil, the island resumes from it.
+func newIsland(id int, inst *tt.Instance, toParent chan<- message, cfg Config, restored *restoredIsland, rng *rand.Rand, events *eventQueue) chan<- message {
 	fromParent := make(chan message, 5)
 	fromChildren := make(chan message, 5)
 	gmRecv := make(chan bool)
 	gmSend := make(chan bool)
 
-	toGenerate := int(0.05*float64(opts.MinPop)) * opts.NSlaves
+	toGenerate := int(cfg.GMRatio*float64(cfg.MinPop)) * cfg.Slaves
 
 	i := &island{
 		parent{
 			fromChildren,
-			make([]chan<- message, opts.NSlaves),
+			make([]chan<- message, cfg.Slaves),
+			nil,
 		},
 		child{
 			id,
@@ -68,33 +75,68 @@ func newIsland(id int, inst *tt.Instance, toParent chan<- message, opts options.
 			toParent,
 		},
 		inst,
-		population.New(opts.MinPop, opts.MaxPop, opts.NSlaves),
+		population.New(cfg.MinPop, cfg.MaxPop, cfg.Slaves),
 		tt.WorstValue(),
 		nil,
 		gmRecv,
 		gmSend,
 		make([]tt.Pair, toGenerate),
-		make([]bool, opts.NSlaves),
+		rng,
+		make(map[int]int),
+		make([]bool, cfg.Slaves),
+		0,
+		false,
+		cfg.Deterministic,
+		make([]bool, cfg.Slaves),
+		events,
 		0,
 	}
 
-	for child := 0; child < opts.NSlaves; child++ {
-		i.toChildren[child] = newSlave(id, child, inst, i.pop.SubPopulation(child), fromChildren, opts)
+	if restored != nil {
+		i.pop = restored.pop
+		i.topValue = restored.topValue
+		i.mh = restored.mh
+	}
+
+	for child := 0; child < cfg.Slaves; child++ {
+		i.toChildren[child] = newSlave(id, child, inst, i.pop.SubPopulation(child), fromChildren, cfg, deriveRand(rng))
 	}
 
 	go i.run()
-	go i.runGM(toGenerate, gmSend, gmRecv)
+	go i.runGM(toGenerate, gmSend, gmRecv, deriveRand(rng))
 
 	return fromParent
 }
 
 // Perform selection and notify the children that they can continue.
 func (i *island) doSelection() {
+	i.generation++
+
+	counts := i.pop.TakeCounts()
+	i.events.emit(Event{
+		Kind:              CrossoverEvent,
+		Island:            i.id,
+		Generation:        i.generation,
+		Mutations:         counts.Mutations,
+		LocalCrossovers:   counts.LocalCrossovers,
+		ForeignCrossovers: counts.ForeignCrossovers,
+	})
+
 	if i.mh == nil {
 		i.pop.Select(nil)
+		i.sendCheckpoint()
 	} else {
 		// Wait for the GM to generate some individuals.
 		<-i.gmRecv
+
+		best := tt.WorstValue()
+		for j := range i.generated {
+			if i.generated[j].Value.Less(best) {
+				best = i.generated[j].Value
+			}
+		}
+		i.events.emit(Event{Kind: GMEvent, Island: i.id, Value: valueOf(best), Generation: i.generation, Generated: len(i.generated)})
+
 		i.mh.update(i.pop.Select(i.generated))
 		for j := range i.generated {
 			if i.generated[j].Value.Less(i.topValue) {
@@ -106,9 +148,15 @@ func (i *island) doSelection() {
 			}
 			i.generated[j].Soln = nil
 		}
+
+		// The metaheuristic must be captured before the GM starts using it
+		// again.
+		i.sendCheckpoint()
 		i.gmSend <- true
 	}
 
+	i.events.emit(Event{Kind: SelectionEvent, Island: i.id, Value: valueOf(i.topValue), Generation: i.generation})
+
 	for child := range i.toChildren {
 		i.sendToChild(child, continueMessage{})
 		i.fullChildren[child] = false
@@ -117,6 +165,16 @@ func (i *island) doSelection() {
 	i.nFullChildren = 0
 }
 
+// Send the island's state to the controller if it has requested it. This must
+// only be called right after a selection, when the slaves are all waiting for
+// a continueMessage.
+func (i *island) sendCheckpoint() {
+	if i.checkpointRequested {
+		i.sendToParent(checkpointMessage{i.state()})
+		i.checkpointRequested = false
+	}
+}
+
 // Handle a fullMessageType message and return whether or not we did selection.
 func (i *island) handleFullMessage(child int) bool {
 	// Do not increase in the event of a duplicate.
@@ -136,119 +194,228 @@ func (i *island) handleFullMessage(child int) bool {
 
 // Run the island.
 func (i *island) run() {
-	crossovers := make(map[int]int) // Map crossover id -> child id
+	msg := (<-i.fromParent).content.(waitMessage)
+	i.wait(msg.done)
+
+	// If the HPGA was stopped while the slaves were generating their
+	// populations, some of them may be incomplete or even empty, so the
+	// island cannot do crossovers with them. It only waits to be stopped. As
+	// with the slaves, this is checked before signalling the controller, and
+	// any slave that saw the HPGA was stopped signalled the island first.
+	select {
+	case <-msg.done:
+		msg.wg.Done()
+		for parentMsg := <-i.fromParent; parentMsg.messageType() != stopMessageType; parentMsg = <-i.fromParent {
+		}
+
+		i.stopChildren()
+		i.gmSend <- false
+		i.fin()
+		return
+
+	default:
+	}
+	msg.wg.Done()
 
-	i.wait()
-	(<-i.fromParent).content.(waitMessage).wg.Done()
+	// If the island was resumed with a metaheuristic, the GM can start
+	// generating individuals right away.
+	if i.mh != nil {
+		i.gmSend <- true
+	}
+
+	if i.deterministic {
+		for {
+			msg := <-i.fromParent
+			if shouldExit := i.handleParentMessage(msg); shouldExit {
+				return
+			}
+
+			if msg.messageType() == stepMessageType {
+				i.round()
+				i.sendToParent(steppedMessage{})
+			}
+		}
+	}
 
 	for {
+		// The messages received while sending to a slave come first.
+		if i.hasPending() {
+			i.handleChildMessage(i.receive())
+			continue
+		}
+
 		select {
 		case msg := <-i.fromParent:
-			switch msg.messageType() {
-			case stopMessageType:
-				i.stopChildren()
-				i.fin()
+			if shouldExit := i.handleParentMessage(msg); shouldExit {
 				return
+			}
 
-			case valueMessageType:
-				if value := msg.content.(valueMessage).value; value.Less(i.topValue) {
+		case msg := <-i.fromChildren:
+			i.handleChildMessage(msg)
+		}
+	}
+}
 
-					i.topValue = value
+// Step each slave that is not waiting for a selection in order, letting each
+// step finish before starting the next, so that the slaves do the same work
+// in the same order every time. When this returns, every slave is either idle
+// or waiting for a selection.
+func (i *island) round() {
+	for child := range i.toChildren {
+		if i.stepping[child] {
+			continue
+		}
 
-					for child := range i.toChildren {
-						i.sendToChild(child, valueMessage{i.topValue})
-					}
-				}
+		i.stepping[child] = true
+		i.sendToChild(child, stepMessage{})
 
-			case weightMessageType:
-				varWeights := msg.content.(weightMessage).varWeights
-				valWeights := msg.content.(weightMessage).valWeights
-				i.mh = newMH(varWeights, valWeights)
-				i.gmSend <- true
+		for i.isBusy() {
+			i.handleChildMessage(i.receive())
+		}
+	}
+}
+
+// Determine if any slave is in the middle of a step that it can finish
+// without a selection.
+func (i *island) isBusy() bool {
+	for child := range i.stepping {
+		if i.stepping[child] && !i.fullChildren[child] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handle a message from the controller. If the island should exit, shouldExit
+// will be true.
+func (i *island) handleParentMessage(msg message) (shouldExit bool) {
+	switch msg.messageType() {
+	case stopMessageType:
+		i.stopChildren()
+		i.stopGM()
+		i.fin()
+		return true
+
+	case valueMessageType:
+		if value := msg.content.(valueMessage).value; value.Less(i.topValue) {
+
+			i.topValue = value
+
+			for child := range i.toChildren {
+				i.sendToChild(child, valueMessage{i.topValue})
 			}
+		}
 
-		case msg := <-i.fromChildren:
-			switch msg.messageType() {
-			case solutionMessageType:
-				best, value := msg.content.(solutionMessage).soln, msg.content.(solutionMessage).value
+	case checkpointMessageType:
+		i.checkpointRequested = true
 
-				if value.Less(i.topValue) {
-					i.sendToParent(solutionMessage{best, value})
-					i.topValue = value
+	case weightMessageType:
+		varWeights := msg.content.(weightMessage).varWeights
+		valWeights := msg.content.(weightMessage).valWeights
+		i.mh = newMH(varWeights, valWeights)
+		i.gmSend <- true
+	}
 
-					for child := range i.toChildren {
-						if child != msg.source {
-							i.sendToChild(child, valueMessage{i.topValue})
-						}
-					}
+	return false
+}
+
+// Handle a message from a slave.
+func (i *island) handleChildMessage(msg message) {
+	switch msg.messageType() {
+	case solutionMessageType:
+		best, value := msg.content.(solutionMessage).soln, msg.content.(solutionMessage).value
+
+		if value.Less(i.topValue) {
+			i.sendToParent(solutionMessage{best, value})
+			i.topValue = value
+
+			for child := range i.toChildren {
+				if child != msg.source {
+					i.sendToChild(child, valueMessage{i.topValue})
 				}
+			}
+		}
 
-			case crossoverMessageType:
-				request := msg.content.(crossoverMessage)
+	case crossoverMessageType:
+		request := msg.content.(crossoverMessage)
 
-				if request.id == newRequest {
+		if request.id == newRequest {
 
-					// Generate a currently unused crossover id
-					id := rand.Int()
-					for _, used := crossovers[id]; used; {
-						id = rand.Int()
-					}
+			// Generate a currently unused crossover id
+			id := i.rng.Int()
+			for _, used := i.crossovers[id]; used; _, used = i.crossovers[id] {
+				id = i.rng.Int()
+			}
 
-					crossovers[id] = msg.source
+			i.crossovers[id] = msg.source
+
+			// We generate a random number in [0, N-1) as there are N-1 other
+			// slaves under the i. We can then map all n >= nSource to
+			// n+1 to get a uniform probability that any slave that is not the
+			// source is picked.
+			other := i.rng.Intn(len(i.toChildren) - 1)
+			if other >= msg.source {
+				other++
+			}
+
+			i.sendToChild(other, crossoverMessage{id})
+		} else {
+			if _, used := i.crossovers[request.id]; used {
+				origin := i.crossovers[request.id]
+				child, value := i.pop.Crossover(origin, msg.source, i.inst, i.rng)
+
+				if value.Less(i.topValue) {
+					i.topValue = value
 
-					// We generate a random number in [0, N-1) as there are N-1 other
-					// slaves under the i. We can then map all n >= nSource to
-					// n+1 to get a uniform probability that any slave that is not the
-					// source is picked.
-					other := rand.Intn(len(i.toChildren) - 1)
-					if other >= msg.source {
-						other++
+					for child := range i.toChildren {
+						i.sendToChild(child, valueMessage{value})
 					}
+					i.sendToParent(solutionMessage{child.Assignments(), value})
+				}
 
-					i.sendToChild(other, crossoverMessage{id})
+				// The origin needs to receive a continueMessage{} to
+				// continue operation, but only if the crossover did
+				// not fill its population. Otherwise it waits for the
+				// continueMessage{} sent by island.doSelection().
+				//
+				// The message source is waiting on a
+				// continueMessage{} for the crossover itself, so it
+				// always receives one. If a selection happens, the
+				// source was also full and waiting for the
+				// selection, so it needs that continueMessage{} as
+				// well.
+				if i.pop.IsSubPopulationFull(origin) {
+					i.handleFullMessage(origin)
 				} else {
-					if _, used := crossovers[request.id]; used {
-						origin := crossovers[request.id]
-						child, value := i.pop.Crossover(origin, msg.source, i.inst)
-
-						if value.Less(i.topValue) {
-							i.topValue = value
-
-							for child := range i.toChildren {
-								i.sendToChild(child, valueMessage{value})
-							}
-							i.sendToParent(solutionMessage{child.Assignments(), value})
-						}
-
-						// The message source needs to receieve a
-						// continueMessage{} to continue operation. However,
-						// it must receive only one to ensure that state stays
-						// in sync. We only send a message if we don't do a
-						// selection. Likewise, we only send a message to the
-						// origin if the crossover did not fill the
-						// population. The other cases are handled by
-						// island.handleFullMessage() and
-						// island.doSelection().
-						if i.pop.IsSubPopulationFull(origin) {
-							if didSelection := i.handleFullMessage(origin); !didSelection {
-								i.sendToChild(msg.source, continueMessage{})
-							}
-						} else {
-							i.sendToChild(origin, continueMessage{})
-							i.sendToChild(msg.source, continueMessage{})
-						}
-
-						delete(crossovers, request.id)
-					}
+					i.sendToChild(origin, continueMessage{})
 				}
 
-			case fullMessageType:
-				i.handleFullMessage(msg.source)
+				i.sendToChild(msg.source, continueMessage{})
+
+				delete(i.crossovers, request.id)
 			}
 		}
+
+	case fullMessageType:
+		i.handleFullMessage(msg.source)
+
+	case steppedMessageType:
+		i.stepping[msg.source] = false
 	}
 }
 
+// Stop the GM operator. Once the island has a metaheuristic, the GM is always
+// generating individuals for the next selection, so it has to deliver them
+// before it can be stopped.
+func (i *island) stopGM() {
+	if i.mh != nil {
+		<-i.gmRecv
+	}
+
+	i.gmSend <- false
+}
+
 // Send a stopMessageType message to all slaves under the island and wait for a
 // finMessageType message from each of them. If a solutionMessageType message arrives, it
 // will be processed as normal (i.e., forwarded to the controller if the
@@ -261,7 +428,7 @@ func (i *island) stopChildren() {
 	finished := make(map[int]bool)
 
 	for len(finished) != len(i.toChildren) {
-		msg := <-i.fromChildren
+		msg := i.receive()
 
 		switch msg.messageType() {
 		case finMessageType:
@@ -18,9 +18,9 @@
 package hpga
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"sync"
-	"time"
 
 	"github.com/brennie/spaghetti/tt"
 )
@@ -37,15 +37,18 @@ const (
 )
 
 const (
-	continueMessageType  messageType = iota // A message telling a child to continue.
-	crossoverMessageType                    // A message containing a crossover request from a slave.
-	finMessageType                          // The message saying the child has finished.
-	fullMessageType                         // The message saying the slave's population is full.
-	solutionMessageType                     // A message containing a solution.
-	stopMessageType                         // The message telling the children to stop.
-	valueMessageType                        // A message containing a valuation.
-	waitMessageType                         // A message containing a sync.WaitGroup
-	weightMessageType                       // A message containing variable and value weights.
+	checkpointMessageType messageType = iota // A message requesting or containing an island's state.
+	continueMessageType                      // A message telling a child to continue.
+	crossoverMessageType                     // A message containing a crossover request from a slave.
+	finMessageType                           // The message saying the child has finished.
+	fullMessageType                          // The message saying the slave's population is full.
+	solutionMessageType                      // A message containing a solution.
+	stepMessageType                          // A message telling a child to take a step.
+	steppedMessageType                       // A message saying the child has finished its step.
+	stopMessageType                          // The message telling the children to stop.
+	valueMessageType                         // A message containing a valuation.
+	waitMessageType                          // A message containing a sync.WaitGroup
+	weightMessageType                        // A message containing variable and value weights.
 )
 
 // A message
@@ -61,24 +64,56 @@ func (m *message) messageType() messageType {
 
 // Send a message on the given channel.
 func send(c chan<- message, s int, m messageContent) {
+	c <- message{s, m}
+}
+
+// Send a message on the given channel unless the context is done first.
+// Return whether or not the message was sent.
+func sendContext(ctx context.Context, c chan<- message, s int, m messageContent) bool {
 	select {
 	case c <- message{s, m}:
-		break
-
-	case <-time.After(10 * time.Second):
-		panic("Could not send on channel after 10s -- deadlock?")
+		return true
 
+	case <-ctx.Done():
+		return false
 	}
-
 }
 
-// Send a message to the given child.
+// Send a message to the given child. While the child's channel is full, the
+// messages from the children are received and kept for receive(), since the
+// child may itself be waiting to send to the parent.
 func (p *parent) sendToChild(child int, m messageContent) {
 	if child >= len(p.toChildren) {
-		log.Fatalf("invalid child: %d", child)
+		panic(fmt.Sprintf("parent.sendToChild: invalid child %d", child))
+	}
+
+	for {
+		select {
+		case p.toChildren[child] <- message{parentID, m}:
+			return
+
+		case msg := <-p.fromChildren:
+			p.pending = append(p.pending, msg)
+		}
+	}
+}
+
+// Determine if there are messages from the children that were received while
+// sending to a child.
+func (p *parent) hasPending() bool {
+	return len(p.pending) > 0
+}
+
+// Receive the next message from the children, in the order they were sent.
+func (p *parent) receive() message {
+	if len(p.pending) == 0 {
+		return <-p.fromChildren
 	}
 
-	send(p.toChildren[child], parentID, m)
+	msg := p.pending[0]
+	p.pending = p.pending[1:]
+
+	return msg
 }
 
 // Send a message to the child's parent.
@@ -92,6 +127,15 @@ type messageContent interface {
 	messageType() messageType
 }
 
+// A checkpoint request from the controller to an island, or an island's
+// response containing its state.
+type checkpointMessage struct {
+	state *islandState // The island's state. This is nil in a request.
+}
+
+// Get the messageType of a checkpointMessage.
+func (_ checkpointMessage) messageType() messageType { return checkpointMessageType }
+
 // A message telling a child to continue.
 type continueMessage struct{}
 
@@ -136,6 +180,19 @@ type weightMessage struct {
 // Get the messageType of an orderingMessage.
 func (_ weightMessage) messageType() messageType { return weightMessageType }
 
+// A message telling a child to take a single step. This is only used in
+// deterministic mode.
+type stepMessage struct{}
+
+// Get the messageType of a stepMessage.
+func (_ stepMessage) messageType() messageType { return stepMessageType }
+
+// A message indicating that a child has finished the step it was told to take.
+type steppedMessage struct{}
+
+// Get the messageType of a steppedMessage.
+func (_ steppedMessage) messageType() messageType { return steppedMessageType }
+
 // A message indicating that a child should stop.
 type stopMessage struct{}
 
@@ -153,7 +210,8 @@ func (_ valueMessage) messageType() messageType { return valueMessageType }
 
 // A message containing a sync.WaitGroup
 type waitMessage struct {
-	wg *sync.WaitGroup // the Wait Group
+	wg   *sync.WaitGroup // the Wait Group
+	done <-chan struct{} // Closed if population generation should stop early.
 }
 
 func (_ waitMessage) messageType() messageType { return waitMessageType }
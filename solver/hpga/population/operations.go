@@ -29,40 +29,42 @@ type parentMask bool
 const (
 	useMother parentMask = false // Mask value signalling to use the mother
 	useFather parentMask = true  // Mask value signalling to use the father
-	maxMutate float64    = 0.2   // The maximum percentage of an individual to mutate
 )
 
-func (p *Population) Crossover(motherPop, fatherPop int, inst *tt.Instance) (child *tt.Solution, value tt.Value) {
+func (p *Population) Crossover(motherPop, fatherPop int, inst *tt.Instance, rng *rand.Rand) (child *tt.Solution, value tt.Value) {
 	if motherPop > p.count || fatherPop > p.count {
 		panic("Population.Crossover: population out of bounds")
 	}
 
-	mother := p.subPops[motherPop].pop[rand.Intn(p.subPops[motherPop].length)]
-	father := p.subPops[fatherPop].pop[rand.Intn(p.subPops[fatherPop].length)]
+	mother := p.subPops[motherPop].pop[rng.Intn(p.subPops[motherPop].length)]
+	father := p.subPops[fatherPop].pop[rng.Intn(p.subPops[fatherPop].length)]
 
-	child, value = crossover(mother, father, inst)
+	child, value = crossover(mother, father, inst, rng)
 	p.subPops[motherPop].Insert(child, value)
+	p.subPops[motherPop].counts.ForeignCrossovers++
 
 	return
 }
 
-func (p *SubPopulation) Crossover(inst *tt.Instance) (*tt.Solution, tt.Value) {
-	mIndex := rand.Intn(p.length)
-	fIndex := rand.Intn(p.length - 1)
+func (p *SubPopulation) Crossover(inst *tt.Instance, rng *rand.Rand) (*tt.Solution, tt.Value) {
+	mIndex := rng.Intn(p.length)
+	fIndex := rng.Intn(p.length - 1)
 	if fIndex >= mIndex {
 		fIndex++
 	}
 
-	return crossover(p.pop[mIndex], p.pop[fIndex], inst)
+	p.counts.LocalCrossovers++
+
+	return crossover(p.pop[mIndex], p.pop[fIndex], inst, rng)
 }
 
-func crossover(mother, father *individual, inst *tt.Instance) (child *tt.Solution, value tt.Value) {
+func crossover(mother, father *individual, inst *tt.Instance, rng *rand.Rand) (child *tt.Solution, value tt.Value) {
 	pMother := float64(0.5 + (mother.success.ratio()-father.success.ratio())*0.5)
 
 	child = inst.NewSolution()
 	for event := 0; event < inst.NEvents(); event++ {
 		parent := mother
-		if mask(mother, father, event, pMother) == useFather {
+		if mask(mother, father, event, pMother, rng) == useFather {
 			parent = father
 		}
 
@@ -79,7 +81,7 @@ func crossover(mother, father *individual, inst *tt.Instance) (child *tt.Solutio
 }
 
 // Generate the crossover mask for the specific event in the two individuals.
-func mask(mother, father *individual, event int, pMother float64) parentMask {
+func mask(mother, father *individual, event int, pMother float64, rng *rand.Rand) parentMask {
 	mQual := mother.soln.AssignmentQuality(event)
 	fQual := father.soln.AssignmentQuality(event)
 
@@ -87,34 +89,49 @@ func mask(mother, father *individual, event int, pMother float64) parentMask {
 		return useMother
 	} else if fQual.Less(mQual) {
 		return useFather
-	} else if rand.Float64() < pMother {
+	} else if rng.Float64() < pMother {
 		return useMother
 	} else {
 		return useFather
 	}
 }
 
-// Mutate one member of a given population and return the solution and the value
-func (p *SubPopulation) MutateOne() (mutant *tt.Solution, value tt.Value) {
-	picked := rand.Intn(p.length)
+// Mutate one member of a given population and return the solution and the
+// value. At most the given share of its events, and at least one, are changed.
+func (p *SubPopulation) MutateOne(rng *rand.Rand, maxMutate float64) (mutant *tt.Solution, value tt.Value) {
+	picked := rng.Intn(p.length)
 	mutant = p.pop[picked].soln.Clone()
 
 	nEvents := mutant.NEvents()
 	max := int(maxMutate * float64(nEvents))
-	nMutations := rand.Intn(max) + 1 // nMutations is in the range [1, max]
+	if max < 1 {
+		max = 1
+	}
+	nMutations := rng.Intn(max) + 1 // nMutations is in the range [1, max]
 
-	toMutate := make(map[int]bool)
+	// The events are kept in the order they were picked so that the mutation
+	// only depends on the random number generator.
+	seen := make(map[int]bool)
+	toMutate := make([]int, 0, nMutations)
 	for len(toMutate) < nMutations {
-		chromosome := rand.Intn(nEvents)
-		toMutate[chromosome] = true
+		chromosome := rng.Intn(nEvents)
+		if !seen[chromosome] {
+			seen[chromosome] = true
+			toMutate = append(toMutate, chromosome)
+		}
 	}
 
-	for event := range toMutate {
-		rat := mutant.Domains[event][rand.Intn(len(mutant.Domains[event]))]
+	for _, event := range toMutate {
+		if len(mutant.Domains[event]) == 0 {
+			continue
+		}
+
+		rat := mutant.Domains[event][rng.Intn(len(mutant.Domains[event]))]
 		mutant.Assign(event, rat)
 	}
 
 	value = mutant.Value()
+	p.counts.Mutations++
 
 	return
 }
@@ -55,6 +55,7 @@ func New(minSize, maxSize, count int) *Population {
 			0,
 			minSize,
 			maxSize,
+			Counts{},
 		}
 
 		p.temp[i] = make([]*individual, minSize)
@@ -63,6 +64,27 @@ func New(minSize, maxSize, count int) *Population {
 	return p
 }
 
+// The number of genetic operations performed on a population.
+type Counts struct {
+	Mutations         int // The number of mutations.
+	LocalCrossovers   int // The number of crossovers within a sub-population.
+	ForeignCrossovers int // The number of crossovers between sub-populations.
+}
+
+// Determine the number of operations performed on the population since the
+// last call and reset the counts. The population must not be modified while
+// this is happening.
+func (p *Population) TakeCounts() (counts Counts) {
+	for _, subPop := range p.subPops {
+		counts.Mutations += subPop.counts.Mutations
+		counts.LocalCrossovers += subPop.counts.LocalCrossovers
+		counts.ForeignCrossovers += subPop.counts.ForeignCrossovers
+		subPop.counts = Counts{}
+	}
+
+	return
+}
+
 // Get the sub-population at the given index.
 func (p *Population) SubPopulation(index int) *SubPopulation {
 	if index > p.count {
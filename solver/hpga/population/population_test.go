@@ -0,0 +1,200 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package population
+
+import (
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/brennie/spaghetti/solver/heuristics"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Read the small instance shared with the hpga tests.
+func smallInstance(t *testing.T) *tt.Instance {
+	f, err := os.Open("../testdata/small.tim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	inst, err := tt.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return inst
+}
+
+// Create a population whose sub-populations are full of random solutions.
+func fullPopulation(inst *tt.Instance, minSize, maxSize, count int, rng *rand.Rand) *Population {
+	p := New(minSize, maxSize, count)
+
+	for i := 0; i < count; i++ {
+		for !p.IsSubPopulationFull(i) {
+			p.SubPopulation(i).Insert(heuristics.RandomAssignment(inst.NewSolution(), rng))
+		}
+	}
+
+	return p
+}
+
+// Determine the values of every individual in the population, in increasing
+// order.
+func sortedValues(p *Population) (values []tt.Value) {
+	for _, subPop := range p.subPops {
+		for _, ind := range subPop.pop[:subPop.length] {
+			values = append(values, ind.value)
+		}
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Less(values[j])
+	})
+
+	return
+}
+
+func TestNewPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("New did not panic when the maximum size was not larger than the minimum size")
+		}
+	}()
+
+	New(5, 5, 2)
+}
+
+func TestSelect(t *testing.T) {
+	inst := smallInstance(t)
+	rng := rand.New(rand.NewSource(1))
+
+	const (
+		minSize = 4
+		maxSize = 7
+		count   = 3
+	)
+
+	for _, nInsert := range []int{0, count, 2 * count} {
+		p := fullPopulation(inst, minSize, maxSize, count, rng)
+		before := sortedValues(p)
+
+		toInsert := make([]tt.Pair, nInsert)
+		for i := range toInsert {
+			soln := heuristics.RandomAssignment(inst.NewSolution(), rng)
+			toInsert[i] = tt.Pair{Soln: soln, Value: soln.Value()}
+		}
+
+		best := p.Select(toInsert)
+
+		if best.Value() != before[0] {
+			t.Errorf("inserting %d: Select returned a solution with value %s; want the best value %s", nInsert, best.Value(), before[0])
+		}
+
+		// Every sub-population is back to its minimum size and holds an even
+		// share of the inserted solutions.
+		inserted := make(map[*tt.Solution]bool)
+		for _, pair := range toInsert {
+			inserted[pair.Soln] = true
+		}
+
+		var survivors []tt.Value
+		for i, subPop := range p.subPops {
+			if subPop.Len() != minSize {
+				t.Errorf("inserting %d: sub-population %d has %d individuals; want %d", nInsert, i, subPop.Len(), minSize)
+			}
+
+			nInserted := 0
+			for _, ind := range subPop.pop[:subPop.Len()] {
+				if inserted[ind.soln] {
+					nInserted++
+					delete(inserted, ind.soln)
+				} else {
+					survivors = append(survivors, ind.value)
+				}
+
+				if ind.value != ind.soln.Value() {
+					t.Errorf("inserting %d: an individual has value %s but its solution has value %s", nInsert, ind.value, ind.soln.Value())
+				}
+			}
+
+			if nInserted != nInsert/count {
+				t.Errorf("inserting %d: sub-population %d got %d of the inserted solutions; want %d", nInsert, i, nInserted, nInsert/count)
+			}
+
+			for _, ind := range subPop.pop[subPop.Len():] {
+				if ind != nil {
+					t.Errorf("inserting %d: sub-population %d has an individual past its length", nInsert, i)
+					break
+				}
+			}
+		}
+
+		if len(inserted) > 0 {
+			t.Errorf("inserting %d: %d of the inserted solutions are missing", nInsert, len(inserted))
+		}
+
+		// The survivors are the best of the old population.
+		sort.Slice(survivors, func(i, j int) bool {
+			return survivors[i].Less(survivors[j])
+		})
+
+		if len(survivors) != count*minSize-nInsert {
+			t.Fatalf("inserting %d: %d individuals survived; want %d", nInsert, len(survivors), count*minSize-nInsert)
+		}
+
+		for i := range survivors {
+			if survivors[i] != before[i] {
+				t.Errorf("inserting %d: survivor %d has value %s; want %s", nInsert, i, survivors[i], before[i])
+			}
+		}
+
+		// The sub-populations can be filled again after a selection.
+		for i := 0; i < count; i++ {
+			for !p.IsSubPopulationFull(i) {
+				p.SubPopulation(i).Insert(heuristics.RandomAssignment(inst.NewSolution(), rng))
+			}
+		}
+	}
+}
+
+func TestSelectBalance(t *testing.T) {
+	inst := smallInstance(t)
+	p := fullPopulation(inst, 4, 6, 2, rand.New(rand.NewSource(2)))
+	before := sortedValues(p)
+
+	p.Select(nil)
+
+	// The survivors are picked in a snake order, so the first sub-population
+	// gets the best and fourth best, and the second gets the second and third
+	// best.
+	want := [][]tt.Value{
+		{before[0], before[3], before[4], before[7]},
+		{before[1], before[2], before[5], before[6]},
+	}
+
+	for i, subPop := range p.subPops {
+		for j, ind := range subPop.pop[:subPop.Len()] {
+			if ind.value != want[i][j] {
+				t.Errorf("individual %d of sub-population %d has value %s; want %s", j, i, ind.value, want[i][j])
+			}
+		}
+	}
+}
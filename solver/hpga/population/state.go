@@ -0,0 +1,111 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package population
+
+import (
+	"fmt"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The state of an individual, which can be used to recreate it. The value of
+// the individual is not part of its state, since it is determined by the
+// instance.
+type IndividualState struct {
+	Rats       []tt.Rat // The assignments of the individual's solution.
+	Crossovers int      // The number of crossovers the individual was involved in.
+	Successes  int      // The number of successful crossovers.
+}
+
+// The state of a population, which is the state of each individual in each
+// sub-population.
+type State [][]IndividualState
+
+// Capture the state of the population. The population must not be modified
+// while this is happening.
+func (p *Population) State() (state State) {
+	state = make(State, p.count)
+
+	for i, subPop := range p.subPops {
+		state[i] = make([]IndividualState, subPop.length)
+
+		for j := 0; j < subPop.length; j++ {
+			ind := subPop.pop[j]
+
+			ind.success.mutex.RLock()
+			state[i][j] = IndividualState{
+				ind.soln.Assignments(),
+				ind.success.crossovers,
+				ind.success.successes,
+			}
+			ind.success.mutex.RUnlock()
+		}
+	}
+
+	return
+}
+
+// Determine if the state can be restored into a population of count
+// sub-populations with the given sizes for the given instance.
+func (state State) Check(inst *tt.Instance, count, minSize, maxSize int) error {
+	if len(state) != count {
+		return fmt.Errorf("expected %d sub-populations; got %d", count, len(state))
+	}
+
+	for i, individuals := range state {
+		if len(individuals) < minSize || len(individuals) > maxSize {
+			return fmt.Errorf("sub-population %d has %d individuals; expected between %d and %d", i, len(individuals), minSize, maxSize)
+		}
+
+		for j, ind := range individuals {
+			if err := inst.CheckRats(ind.Rats); err != nil {
+				return fmt.Errorf("sub-population %d, individual %d: %s", i, j, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Replace the individuals of an empty population with the ones described by
+// the state, and return the best of their values. The values are computed
+// from the instance.
+func (p *Population) Restore(inst *tt.Instance, state State) (best tt.Value, err error) {
+	best = tt.WorstValue()
+
+	if err = state.Check(inst, p.count, p.minSize, p.maxSize); err != nil {
+		return
+	}
+
+	for i, individuals := range state {
+		subPop := p.subPops[i]
+
+		for _, ind := range individuals {
+			soln := inst.SolutionFromRats(ind.Rats)
+			if soln.Value().Less(best) {
+				best = soln.Value()
+			}
+
+			subPop.Insert(soln)
+			subPop.pop[subPop.length-1].success.crossovers = ind.Crossovers
+			subPop.pop[subPop.length-1].success.successes = ind.Successes
+		}
+	}
+
+	return
+}
@@ -18,6 +18,8 @@
 package population
 
 import (
+	"math/rand"
+
 	"github.com/brennie/spaghetti/solver/heuristics"
 	"github.com/brennie/spaghetti/tt"
 )
@@ -28,15 +30,24 @@ type SubPopulation struct {
 	length  int           // The length of the sub-population
 	minSize int           // The minimum size
 	maxSize int           // the maxium size
+	counts  Counts        // The operations performed since the counts were last taken.
 }
 
-// Generate minPop individuals randomly.
-func (p *SubPopulation) Generate(inst *tt.Instance) (bestSoln *tt.Solution, bestValue tt.Value) {
+// Generate minPop individuals randomly. Generation stops early if done is
+// closed.
+func (p *SubPopulation) Generate(inst *tt.Instance, rng *rand.Rand, done <-chan struct{}) (bestSoln *tt.Solution, bestValue tt.Value) {
 	bestSoln = nil
 	bestValue = tt.WorstValue()
 
 	for p.length < p.minSize {
-		soln := heuristics.RandomAssignment(inst.NewSolution())
+		select {
+		case <-done:
+			return
+
+		default:
+		}
+
+		soln := heuristics.RandomAssignment(inst.NewSolution(), rng)
 		value := soln.Value()
 
 		if value.Less(bestValue) {
@@ -20,7 +20,7 @@ package hpga
 import (
 	"math/rand"
 
-	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/solver/anneal"
 	"github.com/brennie/spaghetti/solver/hpga/population"
 	"github.com/brennie/spaghetti/tt"
 )
@@ -32,12 +32,23 @@ type slave struct {
 	inst     *tt.Instance              // The timetabling instance.
 	topValue tt.Value                  // The best seen value thus far.
 	pop      *population.SubPopulation // The slave's population of solutions.
+
+	rng           *rand.Rand // The slave's random number generator.
+	deterministic bool       // Does the slave only take steps when the island tells it to?
+
+	schedule    string // The annealing cooling schedule.
+	weight      int    // The annealing violation weight.
+	annealSteps int    // The number of annealing moves to make on each new individual.
+
+	pMutate   int     // The probability, in percent, that a step is a mutation.
+	pLocal    int     // The probability, in percent, that a step is a local crossover.
+	maxMutate float64 // The largest share of an individual that a mutation changes.
 }
 
 // Create a new slave with the given id. The given channel is the channel the
 // island should use to communicate with the controller. The channel returned
 // is the channel the controller should use to communicate with the island.
-func newSlave(island int, id int, inst *tt.Instance, pop *population.SubPopulation, toParent chan<- message, opts options.SolveOptions) chan<- message {
+func newSlave(island int, id int, inst *tt.Instance, pop *population.SubPopulation, toParent chan<- message, cfg Config, rng *rand.Rand) chan<- message {
 	fromParent := make(chan message, 5)
 	s := &slave{
 		child{
@@ -49,6 +60,14 @@ func newSlave(island int, id int, inst *tt.Instance, pop *population.SubPopulati
 		inst,
 		tt.WorstValue(),
 		pop,
+		rng,
+		cfg.Deterministic,
+		cfg.Schedule,
+		cfg.ViolationWeight,
+		cfg.AnnealSteps,
+		cfg.PMutate,
+		cfg.PLocal,
+		cfg.MaxMutate,
 	}
 
 	go s.run()
@@ -100,64 +119,105 @@ func (s *slave) handleMessage(msg messageContent) (shouldExit bool) {
 // Run the slave.
 func (s *slave) run() {
 	topValue := tt.WorstValue()
+	var generated *solutionMessage
 
 	// Generate the population and signal the island that population generation has finished.
-	if best, value := s.pop.Generate(s.inst); value.Less(topValue) {
-		(<-s.fromParent).content.(waitMessage).wg.Done()
+	msg := (<-s.fromParent).content.(waitMessage)
+	if best, value := s.pop.Generate(s.inst, s.rng, msg.done); value.Less(topValue) {
 		topValue = value
-		s.sendToParent(solutionMessage{best.Assignments(), topValue})
-	} else {
-		(<-s.fromParent).content.(waitMessage).wg.Done()
+		generated = &solutionMessage{best.Assignments(), topValue}
 	}
 
+	// If the HPGA was stopped during population generation, the population
+	// may be incomplete, so the slave only handles messages until it is
+	// stopped. This is checked before signalling the island so that the
+	// controller sees the HPGA was stopped as well.
+	select {
+	case <-msg.done:
+		msg.wg.Done()
+		for !s.handleMessage((<-s.fromParent).content) {
+		}
+		return
+
+	default:
+	}
+	msg.wg.Done()
+
 	for {
-		received := true
-		for received {
-			select {
-			case msg := <-s.fromParent:
-				if shouldExit := s.handleMessage(msg.content); shouldExit {
-					return
+		if s.deterministic {
+			if _, shouldExit := s.waitFor(stepMessageType); shouldExit {
+				return
+			}
+		} else {
+			received := true
+			for received {
+				select {
+				case msg := <-s.fromParent:
+					if shouldExit := s.handleMessage(msg.content); shouldExit {
+						return
+					}
+
+				default:
+					received = false
 				}
-
-			default:
-				received = false
 			}
 		}
 
-		prob := rand.Intn(99) + 1 // [1, 100]
+		// The best generated solution is reported with the first step so that
+		// in deterministic mode it reaches the island at a predictable time.
+		if generated != nil {
+			s.sendToParent(*generated)
+			generated = nil
+		}
 
-		if prob < pLocal+pMutate {
-			var individual *tt.Solution
-			var value tt.Value
+		if shouldExit := s.step(&topValue); shouldExit {
+			return
+		}
 
-			if prob < pMutate {
-				individual, value = s.pop.MutateOne()
-			} else {
-				individual, value = s.pop.Crossover(s.inst)
-			}
+		if s.deterministic {
+			s.sendToParent(steppedMessage{})
+		}
+	}
+}
 
-			s.pop.Insert(individual)
+// Perform a single mutation, local crossover, or foreign crossover. The step
+// is not over until the island has told the slave to continue, if it had to
+// wait. If the slave should exit, shouldExit will be true.
+func (s *slave) step(topValue *tt.Value) (shouldExit bool) {
+	prob := s.rng.Intn(99) + 1 // [1, 100]
 
-			if value.Less(topValue) {
-				topValue = value
-				s.sendToParent(solutionMessage{individual.Assignments(), topValue})
-			}
+	if prob < s.pLocal+s.pMutate {
+		var individual *tt.Solution
+		var value tt.Value
 
-			if s.pop.IsFull() {
-				s.sendToParent(fullMessage{})
+		if prob < s.pMutate {
+			individual, value = s.pop.MutateOne(s.rng, s.maxMutate)
+		} else {
+			individual, value = s.pop.Crossover(s.inst, s.rng)
+		}
 
-				if _, shouldExit := s.waitFor(continueMessageType); shouldExit {
-					return
-				}
-			}
+		if s.annealSteps > 0 {
+			value = anneal.Improve(individual, s.rng, s.schedule, s.weight, s.annealSteps)
+		}
 
-		} else {
-			s.sendToParent(crossoverMessage{newRequest})
-			// We wait for a continueMessage from the island to tell us that the crossover has completed.
-			if _, shouldExit := s.waitFor(continueMessageType); shouldExit {
-				return
-			}
+		s.pop.Insert(individual, value)
 
+		if value.Less(*topValue) {
+			*topValue = value
+			s.sendToParent(solutionMessage{individual.Assignments(), *topValue})
 		}
+
+		if s.pop.IsFull() {
+			s.sendToParent(fullMessage{})
+
+			_, shouldExit = s.waitFor(continueMessageType)
+		}
+
+	} else {
+		s.sendToParent(crossoverMessage{newRequest})
+		// We wait for a continueMessage from the island to tell us that the crossover has completed.
+		_, shouldExit = s.waitFor(continueMessageType)
 	}
+
+	return
 }
@@ -0,0 +1,221 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"math"
+	"sort"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+const (
+	firstTest = 5 // The number of steps of a race before candidates are eliminated.
+
+	zOneSided = 1.6448536269514722 // The 0.95 quantile of the standard normal distribution.
+	zTwoSided = 1.959963984540054  // The 0.975 quantile of the standard normal distribution.
+)
+
+// A configuration being raced.
+type candidate struct {
+	id      int        // The number of the candidate, in the order they were created.
+	values  []float64  // The value of each parameter being tuned.
+	results []tt.Value // The value of its run on each step of the races so far.
+}
+
+// A function that runs each of the candidates on a step of a race and returns
+// the value of each run, or an error if the runs could not all be finished.
+type raceRunner func(cands []*candidate, step int) ([]tt.Value, error)
+
+// Rank the values from 1 (the best) up, giving tied values the mean of the
+// ranks they span.
+func rankValues(values []tt.Value) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]].Less(values[order[j]]) })
+
+	ranks := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+
+		// The positions start through end-1 have the ranks start+1 through end.
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			ranks[i] = rank
+		}
+
+		start = end
+	}
+
+	return ranks
+}
+
+// Determine the sum of the ranks of each candidate over the first n steps,
+// and the sum of the squares of every rank.
+func rankSums(cands []*candidate, n int) (sums []float64, squares float64) {
+	sums = make([]float64, len(cands))
+	values := make([]tt.Value, len(cands))
+
+	for step := 0; step < n; step++ {
+		for j, c := range cands {
+			values[j] = c.results[step]
+		}
+
+		for j, rank := range rankValues(values) {
+			sums[j] += rank
+			squares += rank * rank
+		}
+	}
+
+	return
+}
+
+// Approximate the 0.95 quantile of the chi-squared distribution with df
+// degrees of freedom (Wilson and Hilferty). With one degree of freedom, where
+// the approximation is worst, it is the square of a normal quantile instead.
+func chiSquaredQuantile(df float64) float64 {
+	if df == 1 {
+		return zTwoSided * zTwoSided
+	}
+
+	a := 2 / (9 * df)
+	return df * math.Pow(1-a+zOneSided*math.Sqrt(a), 3)
+}
+
+// Approximate the 0.975 quantile of Student's t distribution with df degrees
+// of freedom (a Cornish-Fisher expansion).
+func tQuantile(df float64) float64 {
+	z := zTwoSided
+	z3, z5, z7 := z*z*z, math.Pow(z, 5), math.Pow(z, 7)
+
+	return z + (z3+z)/(4*df) + (5*z5+16*z3+3*z)/(96*df*df) + (3*z7+19*z5+17*z3-15*z)/(384*df*df*df)
+}
+
+// Eliminate the candidates that are significantly worse than the best over
+// the first n steps, at the 0.05 level: if the Friedman test finds that the
+// candidates differ, those whose rank sums differ from the best by more than
+// the critical difference of Conover's post-hoc test are eliminated. The
+// candidates that remain are returned in their order.
+func friedmanSurvivors(cands []*candidate, n int) []*candidate {
+	k := float64(len(cands))
+	b := float64(n)
+	if len(cands) < 2 || n < 2 {
+		return cands
+	}
+
+	sums, squares := rankSums(cands, n)
+
+	// Every step is a tie when the ranks have no variance.
+	c := b * k * (k + 1) * (k + 1) / 4
+	if squares-c <= 0 {
+		return cands
+	}
+
+	var spread, sumSquares float64
+	best := sums[0]
+	for _, sum := range sums {
+		d := sum - b*(k+1)/2
+		spread += d * d
+		sumSquares += sum * sum
+		best = math.Min(best, sum)
+	}
+
+	if statistic := (k - 1) * spread / (squares - c); statistic <= chiSquaredQuantile(k-1) {
+		return cands
+	}
+
+	df := (b - 1) * (k - 1)
+	critical := tQuantile(df) * math.Sqrt(2*(b*squares-sumSquares)/df)
+
+	var survivors []*candidate
+	for j, cand := range cands {
+		if sums[j]-best <= critical {
+			survivors = append(survivors, cand)
+		}
+	}
+
+	return survivors
+}
+
+// Race the candidates on the steps from the first: each step runs the
+// candidates that have no result for it yet, and from the firstTest-th step
+// on, the candidates that are significantly worse are eliminated. After the
+// first firstTest steps, the race stops when at most the given number of
+// candidates remain; it also stops when the next step would make more than
+// budget runs, or when the runner fails. The candidates that remain are
+// returned from the best to the worst by their rank sums, along with the
+// number of runs made.
+func race(cands []*candidate, survivors, budget int, run raceRunner) ([]*candidate, int) {
+	alive := cands
+	used := 0
+	steps := 0
+
+	for steps < firstTest || len(alive) > survivors {
+		var todo []*candidate
+		for _, c := range alive {
+			if len(c.results) <= steps {
+				todo = append(todo, c)
+			}
+		}
+
+		if used+len(todo) > budget {
+			break
+		}
+
+		if len(todo) > 0 {
+			values, err := run(todo, steps)
+			if err != nil {
+				break
+			}
+
+			for i, c := range todo {
+				c.results = append(c.results, values[i])
+			}
+
+			used += len(todo)
+		}
+
+		steps++
+
+		if steps >= firstTest {
+			alive = friedmanSurvivors(alive, steps)
+		}
+	}
+
+	// Every candidate that remains has a result for every step so far.
+	sums, _ := rankSums(alive, steps)
+	order := make([]int, len(alive))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return sums[order[i]] < sums[order[j]] })
+
+	ranked := make([]*candidate, len(alive))
+	for i, j := range order {
+		ranked[i] = alive[j]
+	}
+
+	return ranked, used
+}
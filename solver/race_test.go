@@ -0,0 +1,140 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+func TestRankValues(t *testing.T) {
+	values := []tt.Value{
+		{Violations: 0, Fitness: 30},
+		{Violations: 1, Fitness: 5},
+		{Violations: 0, Fitness: 10},
+		{Violations: 0, Fitness: 30},
+		{Violations: 0, Fitness: 30},
+	}
+
+	if got, want := rankValues(values), []float64{3, 5, 1, 3, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"chi-squared, 1 df", chiSquaredQuantile(1), 3.841},
+		{"chi-squared, 4 df", chiSquaredQuantile(4), 9.488},
+		{"chi-squared, 30 df", chiSquaredQuantile(30), 43.773},
+		{"t, 4 df", tQuantile(4), 2.776},
+		{"t, 20 df", tQuantile(20), 2.086},
+	}
+
+	// The approximations are good enough to the third significant digit.
+	for _, test := range tests {
+		if math.Abs(test.got-test.want)/test.want > 0.01 {
+			t.Errorf("%s: got %.3f; want %.3f", test.name, test.got, test.want)
+		}
+	}
+}
+
+// Create a runner in which each candidate's value is its first value plus a
+// noise that depends on the step, and which counts each candidate's runs.
+func fakeRunner(runs map[int]int) raceRunner {
+	return func(cands []*candidate, step int) ([]tt.Value, error) {
+		values := make([]tt.Value, len(cands))
+		for i, c := range cands {
+			runs[c.id]++
+			values[i] = tt.Value{Fitness: int(c.values[0]) + (step*7+c.id*3)%4}
+		}
+
+		return values, nil
+	}
+}
+
+func TestRace(t *testing.T) {
+	var cands []*candidate
+	for id := 0; id < 6; id++ {
+		cands = append(cands, &candidate{id, []float64{float64(id * 10)}, nil})
+	}
+
+	// The first candidate already has results for the first two steps, which
+	// it should not be run for again.
+	cands[0].results = []tt.Value{{Fitness: 1}, {Fitness: 2}}
+
+	runs := make(map[int]int)
+	ranked, used := race(cands, 2, 100, fakeRunner(runs))
+
+	if len(ranked) == 0 {
+		t.Fatal("every candidate was eliminated")
+	} else if len(ranked) > 2 || ranked[0].id != 0 {
+		t.Errorf("got %d candidates starting with %d; want at most 2 starting with 0", len(ranked), ranked[0].id)
+	}
+
+	total := 0
+	for _, n := range runs {
+		total += n
+	}
+
+	if total != used || runs[0] != len(ranked[0].results)-2 {
+		t.Errorf("made %d runs, %d of them of the first candidate; reported %d", total, runs[0], used)
+	}
+
+	for _, c := range ranked {
+		if len(c.results) != len(ranked[0].results) {
+			t.Errorf("candidate %d has %d results; want %d", c.id, len(c.results), len(ranked[0].results))
+		}
+	}
+}
+
+func TestRaceBudget(t *testing.T) {
+	var cands []*candidate
+	for id := 0; id < 4; id++ {
+		// The candidates differ only by the noise, so none is eliminated.
+		cands = append(cands, &candidate{id, []float64{10}, nil})
+	}
+
+	runs := make(map[int]int)
+	if _, used := race(cands, 1, 22, fakeRunner(runs)); used != 20 {
+		t.Errorf("made %d runs; want 20 from a budget of 22", used)
+	}
+
+	// A failed step is not counted, and ends the race.
+	fail := func(cands []*candidate, step int) ([]tt.Value, error) {
+		if step == 1 {
+			return nil, errors.New("interrupted")
+		}
+
+		return fakeRunner(runs)(cands, step)
+	}
+
+	for _, c := range cands {
+		c.results = nil
+	}
+
+	if ranked, used := race(cands, 1, 100, fail); used != 4 || len(ranked) != 4 {
+		t.Errorf("made %d runs with %d candidates left; want 4 and 4", used, len(ranked))
+	}
+}
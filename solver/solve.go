@@ -19,8 +19,10 @@
 package solver
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
 	"runtime/pprof"
 	"time"
 
@@ -29,7 +31,78 @@ import (
 	"github.com/brennie/spaghetti/tt"
 )
 
-// Solve a timetabling instance with an HPGA.
+// Read the soft constraint weights from the named file and give them to the
+// instance.
+func setSoftWeights(inst *tt.Instance, fileName string) {
+	weightsFile, err := os.Open(fileName)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+	defer weightsFile.Close()
+
+	weights, err := tt.ReadSoftWeights(weightsFile)
+	if err != nil {
+		log.Fatalf("Could not parse %s: %s\n", fileName, err)
+	}
+
+	if err = inst.SetSoftWeights(weights); err != nil {
+		log.Fatalf("Could not use %s: %s\n", fileName, err)
+	}
+}
+
+// Read the institutional data of the instance from the named file.
+func readExtension(inst *tt.Instance, fileName string) {
+	extensionFile, err := os.Open(fileName)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+	defer extensionFile.Close()
+
+	if err = inst.ReadExtension(extensionFile); err != nil {
+		log.Fatalf("Could not parse %s: %s\n", fileName, err)
+	}
+}
+
+// Reduce the domains of the instance. An instance that has no feasible solution
+// is not worth solving, so the reasons are listed instead.
+func preprocess(inst *tt.Instance, fileName string) {
+	removed, err := inst.Preprocess()
+	if err != nil {
+		if infeasible, ok := err.(*tt.InfeasibleError); ok {
+			for _, reason := range infeasible.Reasons {
+				log.Printf("Infeasible: %s\n", reason)
+			}
+		}
+
+		log.Fatalf("Could not solve %s: %s; use --no-preprocess to solve it anyway\n", fileName, err)
+	}
+
+	log.Printf("Preprocessing removed %d rooms and times from the events' domains\n", removed)
+}
+
+// Write the configuration of the run next to the solution, so that the run
+// can be repeated with --config. The configuration file of the run is never
+// overwritten.
+func writeConfig(opts options.SolveOptions) {
+	if opts.EffectiveConfig == "" {
+		log.Printf("Not writing the configuration of the run over %s\n", opts.ConfigFile)
+		return
+	}
+
+	configFile, err := os.Create(opts.EffectiveConfig)
+	if err != nil {
+		log.Fatalf("Could not %s\n", err)
+	}
+	defer configFile.Close()
+
+	if err = opts.Config().Write(configFile); err != nil {
+		log.Fatalf("Could not write %s: %s\n", opts.EffectiveConfig, err)
+	}
+
+	log.Printf("Wrote the configuration of the run to %s\n", opts.EffectiveConfig)
+}
+
+// Solve a timetabling instance with the algorithm given in the options.
 func Solve(opts options.SolveOptions) {
 	if opts.Profile != nil {
 		profileName := opts.Profile.(string)
@@ -56,17 +129,78 @@ func Solve(opts options.SolveOptions) {
 	}
 	defer solnFile.Close()
 
-	inst, err := tt.Parse(instFile)
+	inst, err := tt.ParseWithWeek(instFile, opts.Week)
 
 	if err != nil {
 		log.Fatalf("Could not parse %s: %s\n", opts.Instance, err)
 	}
 
+	if inst.Format() != tt.PostEnrolment && opts.Week != tt.StandardWeek {
+		log.Printf("Ignoring --week: the week only applies to post-enrolment instances\n")
+	}
+
+	if opts.Extension != "" {
+		readExtension(inst, opts.Extension)
+		log.Printf("Using institutional data from %s\n", opts.Extension)
+	}
+
+	if opts.Weights != "" {
+		setSoftWeights(inst, opts.Weights)
+		log.Printf("Using soft constraint weights %v from %s\n", inst.SoftWeights(), opts.Weights)
+	}
+
+	if !opts.NoPreprocess {
+		preprocess(inst, opts.Instance)
+	}
+
 	log.Printf("Using seed %d\n", opts.Seed)
 
-	log.Printf("Running solver on %s\n", opts.Instance)
+	if opts.ConfigFile != "" {
+		log.Printf("Using settings from %s\n", opts.ConfigFile)
+	}
+
+	writeConfig(opts)
+
+	log.Printf("Running %s solver on %s\n", opts.Algorithm, opts.Instance)
 	start := time.Now()
-	soln, value := hpga.Run(inst, opts)
+
+	var listener hpga.Listener
+
+	if opts.Events != "" {
+		eventsFile, err := os.Create(opts.Events)
+		if err != nil {
+			log.Fatalf("Could not %s\n", err)
+		}
+		defer eventsFile.Close()
+
+		log.Printf("Writing progress events to %s\n", opts.Events)
+		listener = hpga.NewEventWriter(eventsFile)
+	}
+
+	ctx := context.Background()
+
+	if opts.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(opts.Timeout)*time.Minute)
+		defer cancel()
+	}
+
+	// The solver stops when interrupted. Once it is stopping, the default
+	// behaviour is restored so that a second interrupt quits immediately (e.g.,
+	// if writing the final checkpoint takes too long).
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	soln, value, err := newSolver(opts, listener, nil).Solve(ctx, inst)
+	if err != nil {
+		log.Fatalf("Could not solve %s: %s\n", opts.Instance, err)
+	}
+
 	log.Printf("Solver finished after %.2f seconds", time.Since(start).Seconds())
 
 	log.Printf("Writing solution with value %s to file %s\n", value, opts.Solution)
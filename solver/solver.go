@@ -0,0 +1,110 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"context"
+	"time"
+
+	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/solver/anneal"
+	"github.com/brennie/spaghetti/solver/hpga"
+	"github.com/brennie/spaghetti/solver/tabu"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// A timetabling solver. The configurations of each algorithm (hpga.Config,
+// tabu.Config, and anneal.Config) are solvers.
+type Solver interface {
+	// Solve the instance until a good enough solution is found or the
+	// context is done, and return the best solution found and its value.
+	// Stopping because the context is done is not an error; an error is only
+	// returned if the solver could not run at all.
+	Solve(ctx context.Context, inst *tt.Instance) (*tt.Solution, tt.Value, error)
+}
+
+// Create the solver described by the commandline options. Progress events
+// from the HPGA are sent to the listener if it is not nil, and the value of
+// each new best solution is sent to improved, along with when it was found, if
+// improved is not nil.
+func newSolver(opts options.SolveOptions, listener hpga.Listener, improved func(tt.Value, time.Time)) Solver {
+	// Tabu search and simulated annealing report each new best solution as
+	// soon as they find it.
+	var improvedNow func(tt.Value)
+	if improved != nil {
+		improvedNow = func(value tt.Value) { improved(value, time.Now()) }
+	}
+
+	switch opts.Algorithm {
+	case "tabu":
+		return tabu.Config{Seed: opts.Seed, Ideal: opts.Ideal, Improved: improvedNow}
+
+	case "anneal":
+		return anneal.Config{
+			Seed:            opts.Seed,
+			Ideal:           opts.Ideal,
+			Schedule:        opts.Schedule,
+			ViolationWeight: opts.ViolationWeight,
+			Improved:        improvedNow,
+		}
+
+	default:
+		if improved != nil {
+			listener = improvedListener(listener, improved)
+		}
+
+		return hpga.Config{
+			Islands:            opts.NIslands,
+			Slaves:             opts.NSlaves,
+			MinPop:             opts.MinPop,
+			MaxPop:             opts.MaxPop,
+			Seed:               opts.Seed,
+			Ideal:              opts.Ideal,
+			Deterministic:      opts.Deterministic,
+			AnnealSteps:        opts.AnnealSteps,
+			Schedule:           opts.Schedule,
+			ViolationWeight:    opts.ViolationWeight,
+			Checkpoint:         opts.Checkpoint,
+			CheckpointInterval: time.Duration(opts.CheckpointInterval) * time.Minute,
+			Resume:             opts.Resume,
+			PMutate:            opts.PMutate,
+			PLocal:             opts.PLocal,
+			MaxMutate:          opts.MaxMutate,
+			HCTries:            opts.HCTries,
+			HCCutOff:           opts.HCCutOff,
+			GMRatio:            opts.GMRatio,
+			Listener:           listener,
+		}
+	}
+}
+
+// Create a listener that sends the value of each new best solution to
+// improved, and every event to the listener if it is not nil. Events are
+// delivered some time after they happen, so improved is given the time of the
+// event rather than the time it was delivered.
+func improvedListener(listener hpga.Listener, improved func(tt.Value, time.Time)) hpga.Listener {
+	return func(e hpga.Event) {
+		if e.Kind == hpga.NewBestEvent && e.Value != nil {
+			improved(*e.Value, e.Time)
+		}
+
+		if listener != nil {
+			listener(e)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brennie/spaghetti/solver/hpga"
+	"github.com/brennie/spaghetti/tt"
+)
+
+func TestImprovedListener(t *testing.T) {
+	start := time.Now()
+	value := tt.Value{Violations: 0, Fitness: 12}
+
+	type improvement struct {
+		value tt.Value
+		at    time.Time
+	}
+
+	var improvements []improvement
+	var events []hpga.Event
+	listener := improvedListener(
+		func(e hpga.Event) { events = append(events, e) },
+		func(value tt.Value, at time.Time) { improvements = append(improvements, improvement{value, at}) },
+	)
+
+	sent := []hpga.Event{
+		{Kind: hpga.WeightEvent, Time: start, Island: -1},
+		{Kind: hpga.NewBestEvent, Time: start.Add(time.Second), Island: 1, Value: &value},
+		{Kind: hpga.StopEvent, Time: start.Add(2 * time.Second), Island: -1, Value: &value},
+	}
+
+	// The events are delivered after they happen, but the improvement is
+	// reported at the time of the event.
+	for _, e := range sent {
+		listener(e)
+	}
+
+	if len(events) != len(sent) {
+		t.Errorf("the listener got %d events; want %d", len(events), len(sent))
+	}
+
+	if len(improvements) != 1 {
+		t.Fatalf("got %d improvements; want 1", len(improvements))
+	}
+
+	if got := improvements[0]; got.value != value || !got.at.Equal(start.Add(time.Second)) {
+		t.Errorf("got the improvement %s at %s; want %s at %s", got.value, got.at.Sub(start), value, time.Second)
+	}
+}
@@ -0,0 +1,187 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// The tabu search package.
+package tabu
+
+import (
+	"context"
+	"log"
+	"math/rand"
+
+	"github.com/brennie/spaghetti/tt"
+)
+
+const (
+	tenure       = 10  // The minimum number of iterations a move stays tabu.
+	tenureSpread = 10  // The maximum number of extra iterations a move stays tabu.
+	sampleSize   = 100 // The number of neighbours sampled at each iteration.
+)
+
+// The configuration of a tabu search.
+type Config struct {
+	Seed     int64          // The seed for the random number generator.
+	Ideal    bool           // Should the search stop at an ideal solution (true) or merely a valid one (false)?
+	Improved func(tt.Value) // Called with the value of each new best solution, if not nil.
+}
+
+// The state of a tabu search.
+type search struct {
+	inst      *tt.Instance     // The timetabling instance.
+	rng       *rand.Rand       // The random number generator.
+	current   *tt.Solution     // The current solution.
+	best      []tt.Rat         // The assignments of the best solution found.
+	bestValue tt.Value         // The value of the best solution found.
+	tabu      []map[tt.Rat]int // Map each event and Rat to the last iteration the assignment is tabu.
+	iteration int              // The current iteration.
+	ideal     bool             // Are we looking for an ideal solution?
+}
+
+// Create a new tabu search starting from a random assignment.
+func newSearch(inst *tt.Instance, cfg Config) *search {
+	s := &search{
+		inst,
+		rand.New(rand.NewSource(cfg.Seed)),
+		inst.NewSolution(),
+		nil,
+		tt.WorstValue(),
+		make([]map[tt.Rat]int, inst.NEvents()),
+		0,
+		cfg.Ideal,
+	}
+
+	for event := range s.tabu {
+		s.tabu[event] = make(map[tt.Rat]int)
+
+		if domain := inst.Domains[event]; len(domain) > 0 {
+			s.current.Assign(event, domain[s.rng.Intn(len(domain))])
+		}
+	}
+
+	s.best = s.current.Assignments()
+	s.bestValue = s.current.Value()
+
+	return s
+}
+
+// Determine if assigning the Rat to the event is tabu.
+func (s *search) isTabu(event int, rat tt.Rat) bool {
+	return s.tabu[event][rat] >= s.iteration
+}
+
+// Sample the neighbourhood of the current solution and return the best move
+// that is either not tabu or satisfies the aspiration criterion (i.e., it
+// results in a solution better than the best found so far). If no such move
+// is sampled, the returned event is -1.
+func (s *search) sample() (bestEvent int, bestRat tt.Rat, bestValue tt.Value) {
+	bestEvent = -1
+	bestValue = tt.WorstValue()
+
+	for i := 0; i < sampleSize; i++ {
+		event := s.rng.Intn(s.inst.NEvents())
+		domain := s.inst.Domains[event]
+		if len(domain) == 0 {
+			continue
+		}
+
+		rat := domain[s.rng.Intn(len(domain))]
+		if rat == s.current.RatAt(event) {
+			continue
+		}
+
+		value := s.current.AssignValue(event, rat)
+
+		if s.isTabu(event, rat) && !value.Less(s.bestValue) {
+			continue
+		}
+
+		if value.Less(bestValue) {
+			bestEvent = event
+			bestRat = rat
+			bestValue = value
+		}
+	}
+
+	return
+}
+
+// Perform a single iteration of the search and return whether or not a new
+// best solution was found.
+func (s *search) step() (improved bool) {
+	s.iteration++
+
+	event, rat, value := s.sample()
+	if event == -1 {
+		return false
+	}
+
+	// Moving the event back to where it was is tabu for the next few
+	// iterations.
+	s.tabu[event][s.current.RatAt(event)] = s.iteration + tenure + s.rng.Intn(tenureSpread+1)
+	s.current.Assign(event, rat)
+
+	if value.Less(s.bestValue) {
+		s.bestValue = value
+		s.best = s.current.Assignments()
+
+		return true
+	}
+
+	return false
+}
+
+// Determine if the search has found a good enough solution to stop.
+func (s *search) isDone() bool {
+	if s.ideal && s.bestValue.IsIdeal() {
+		log.Println("Found ideal solution. Stopping...")
+		return true
+	} else if !s.ideal && s.bestValue.IsValid() {
+		log.Println("Found valid solution. Stopping...")
+		return true
+	}
+
+	return false
+}
+
+// Run a tabu search on the instance until a good enough solution is found or
+// the context is done, and return the best solution found.
+func (cfg Config) Solve(ctx context.Context, inst *tt.Instance) (*tt.Solution, tt.Value, error) {
+	s := newSearch(inst, cfg)
+	defer s.current.Free()
+
+	log.Printf("Initial solution: %s\n", s.bestValue)
+
+searchLoop:
+	for !s.isDone() {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping: %s\n", ctx.Err())
+			break searchLoop
+
+		default:
+			if s.step() {
+				log.Printf("Found new best solution: %s\n", s.bestValue)
+
+				if cfg.Improved != nil {
+					cfg.Improved(s.bestValue)
+				}
+			}
+		}
+	}
+
+	return inst.SolutionFromRats(s.best), s.bestValue, nil
+}
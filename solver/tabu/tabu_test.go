@@ -0,0 +1,120 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tabu
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/brennie/spaghetti/internal/testutil"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// Solve the instance with the configuration, failing if the search takes more
+// than a minute, and return the assignments and value of the solution along
+// with every value given to cfg.Improved.
+func solve(t *testing.T, inst *tt.Instance, cfg Config) ([]tt.Rat, tt.Value, []tt.Value) {
+	var improvements []tt.Value
+	cfg.Improved = func(value tt.Value) { improvements = append(improvements, value) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	soln, value, err := cfg.Solve(ctx, inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer soln.Free()
+
+	if ctx.Err() != nil {
+		t.Fatal("the search did not find a valid solution in time")
+	}
+
+	if soln.Value() != value {
+		t.Errorf("got the value %s for a solution with value %s", value, soln.Value())
+	}
+
+	return soln.Assignments(), value, improvements
+}
+
+func TestSolveValid(t *testing.T) {
+	inst := testutil.PlantedInstance(t)
+	assignments, value, improvements := solve(t, inst, Config{Seed: 1})
+
+	if !value.IsValid() {
+		t.Fatalf("got the invalid value %s", value)
+	}
+
+	for i := 1; i < len(improvements); i++ {
+		if !improvements[i].Less(improvements[i-1]) {
+			t.Errorf("improvement %d (%s) is no better than the one before it (%s)", i, improvements[i], improvements[i-1])
+		}
+	}
+
+	if n := len(improvements); n == 0 || improvements[n-1] != value {
+		t.Errorf("the last improvement of %v is not the value %s", improvements, value)
+	}
+
+	// The same seed gives the same search.
+	if again, againValue, _ := solve(t, inst, Config{Seed: 1}); againValue != value || !reflect.DeepEqual(again, assignments) {
+		t.Errorf("the same seed gave the value %s and then %s", value, againValue)
+	}
+}
+
+func TestSolveCancelled(t *testing.T) {
+	inst := testutil.PlantedInstance(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A cancelled search returns its random starting solution.
+	soln, value, err := Config{Seed: 1, Ideal: true}.Solve(ctx, inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer soln.Free()
+
+	if soln.Value() != value {
+		t.Errorf("got the value %s for a solution with value %s", value, soln.Value())
+	}
+}
+
+func TestStepRespectsTabu(t *testing.T) {
+	inst := testutil.PlantedInstance(t)
+	s := newSearch(inst, Config{Seed: 2})
+	defer s.current.Free()
+
+	for i := 0; i < 200; i++ {
+		before := s.current.Assignments()
+		bestBefore := s.bestValue
+
+		if s.step() && !s.bestValue.Less(bestBefore) {
+			t.Fatalf("step %d reported an improvement from %s to %s", i, bestBefore, s.bestValue)
+		}
+
+		// The event that moved may not move back for at least tenure
+		// iterations.
+		for event, rat := range s.current.Assignments() {
+			if rat != before[event] && !s.isTabu(event, before[event]) {
+				t.Fatalf("step %d: moving event %d back is not tabu", i, event)
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brennie/spaghetti/options"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// A setting of the HPGA that tune chooses.
+type tuneParam struct {
+	name     string  // The name of the setting in configuration files.
+	min, max float64 // The range of its values.
+	integer  bool    // Whether its values are integers.
+}
+
+// The settings that tune chooses and their ranges.
+var tuneParams = []tuneParam{
+	{"islands", 2, 8, true},
+	{"slaves", 2, 8, true},
+	{"minpop", 10, 100, true},
+	{"maxpop", 15, 150, true},
+	{"mutation", 0, 30, true},
+	{"local-crossover", 0, 100, true},
+	{"max-mutate", 0.05, 0.5, false},
+	{"gm-ratio", 0, 0.2, false},
+}
+
+// The indices of the settings in tuneParams.
+const (
+	paramIslands = iota
+	paramSlaves
+	paramMinPop
+	paramMaxPop
+	paramMutation
+	paramLocalCrossover
+	paramMaxMutate
+	paramGMRatio
+)
+
+// Clamp a value of the parameter to its range, rounding it to an integer or
+// to three decimal places.
+func (p tuneParam) clamp(value float64) float64 {
+	if p.integer {
+		value = math.Round(value)
+	} else {
+		value = math.Round(value*1000) / 1000
+	}
+
+	return math.Max(p.min, math.Min(p.max, value))
+}
+
+// Make the values of the parameters a configuration that solve accepts: the
+// maximum population must exceed the minimum, and the probabilities of
+// mutation and local crossover must total at most 100.
+func repair(values []float64) {
+	for i, p := range tuneParams {
+		values[i] = p.clamp(values[i])
+	}
+
+	if values[paramMaxPop] <= values[paramMinPop] {
+		values[paramMaxPop] = values[paramMinPop] + 1
+	}
+
+	if values[paramMutation]+values[paramLocalCrossover] > 100 {
+		values[paramLocalCrossover] = 100 - values[paramMutation]
+	}
+}
+
+// Determine the values of the parameters in the solve options.
+func paramValues(opts options.SolveOptions) []float64 {
+	values := []float64{
+		float64(opts.NIslands),
+		float64(opts.NSlaves),
+		float64(opts.MinPop),
+		float64(opts.MaxPop),
+		float64(opts.PMutate),
+		float64(opts.PLocal),
+		opts.MaxMutate,
+		opts.GMRatio,
+	}
+	repair(values)
+
+	return values
+}
+
+// Set the parameters of the solve options to the values.
+func applyParams(opts options.SolveOptions, values []float64) options.SolveOptions {
+	opts.NIslands = int(values[paramIslands])
+	opts.NSlaves = int(values[paramSlaves])
+	opts.MinPop = int(values[paramMinPop])
+	opts.MaxPop = int(values[paramMaxPop])
+	opts.PMutate = int(values[paramMutation])
+	opts.PLocal = int(values[paramLocalCrossover])
+	opts.MaxMutate = values[paramMaxMutate]
+	opts.GMRatio = values[paramGMRatio]
+
+	return opts
+}
+
+// Describe the values of the parameters.
+func describeParams(values []float64) string {
+	fields := make([]string, len(values))
+	for i, p := range tuneParams {
+		fields[i] = fmt.Sprintf("%s %g", p.name, values[i])
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// Sample new candidates. Without elites, every value is uniformly random;
+// otherwise, each candidate is sampled around an elite, chosen with a weight
+// that falls with its rank, with a spread that narrows with each iteration.
+func sampleCandidates(rng *rand.Rand, elites []*candidate, n, iteration, firstID int) []*candidate {
+	cands := make([]*candidate, n)
+
+	for i := range cands {
+		values := make([]float64, len(tuneParams))
+
+		if len(elites) == 0 {
+			for j, p := range tuneParams {
+				if p.integer {
+					values[j] = math.Floor(p.min + rng.Float64()*(p.max-p.min+1))
+				} else {
+					values[j] = p.min + rng.Float64()*(p.max-p.min)
+				}
+			}
+		} else {
+			// The elite of rank r (from 0) has the weight len(elites) - r.
+			total := len(elites) * (len(elites) + 1) / 2
+			pick := rng.Intn(total)
+			parent := 0
+			for weight := len(elites); pick >= weight; weight-- {
+				pick -= weight
+				parent++
+			}
+
+			shrink := math.Pow(float64(n), -float64(iteration)/float64(len(tuneParams)))
+			for j, p := range tuneParams {
+				sd := (p.max - p.min) / 2 * shrink
+				values[j] = elites[parent].values[j] + rng.NormFloat64()*sd
+			}
+		}
+
+		repair(values)
+		cands[i] = &candidate{firstID + i, values, nil}
+	}
+
+	return cands
+}
+
+// Create a runner that runs the candidates on the instance and seed of a step,
+// making at most opts.Parallel runs at once, each for opts.RunTime seconds. If
+// the context is done first, the runs are not finished and its error is
+// returned.
+func tuneRunner(ctx context.Context, opts options.TuneOptions, instances []benchInstance, progress *log.Logger) raceRunner {
+	return func(cands []*candidate, step int) ([]tt.Value, error) {
+		bi := instances[step%len(instances)]
+		seed := opts.Seed + int64(step/len(instances))
+		values := make([]tt.Value, len(cands))
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, opts.Parallel)
+
+		for i, c := range cands {
+			wg.Add(1)
+			slots <- struct{}{}
+
+			go func(i int, c *candidate) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				run, err := benchOnce(ctx, applyParams(opts.SolveOptions, c.values), bi.inst, seed, time.Duration(opts.RunTime)*time.Second)
+				if err != nil {
+					// A run that cannot start is a problem with the settings
+					// the candidates share.
+					progress.Fatalf("Could not solve %s: %s\n", bi.name, err)
+				}
+
+				values[i] = run.Value
+			}(i, c)
+		}
+
+		wg.Wait()
+
+		return values, ctx.Err()
+	}
+}
+
+// Tune the HPGA's settings on the instances in the directory by iterated
+// racing, and write the best configuration found.
+func Tune(opts options.TuneOptions) {
+	weights := readSoftWeights(opts.Weights)
+
+	all, err := readBenchInstances(opts.Directory, opts.SolveOptions, weights)
+	if err != nil {
+		log.Fatalf("Could not read the instances in %s: %s\n", opts.Directory, err)
+	}
+
+	var instances []benchInstance
+	for _, bi := range all {
+		if bi.err != nil {
+			log.Printf("Skipping %s: %s\n", bi.name, bi.err)
+		} else {
+			instances = append(instances, bi)
+		}
+	}
+
+	if len(instances) == 0 {
+		log.Fatalf("Found no instances to tune on in %s\n", opts.Directory)
+	}
+
+	// Each run continues until its time is up, so that the quality of the
+	// solutions of different configurations can be compared.
+	ideal := opts.Ideal
+	opts.Ideal = true
+
+	// As many iterations and elites as irace uses.
+	nIterations := 2 + int(math.Log2(float64(len(tuneParams))))
+	nElites := nIterations
+
+	log.Printf("Tuning on %d instances with %d runs of %d seconds and seeds from %d\n", len(instances), opts.Budget, opts.RunTime, opts.Seed)
+
+	if opts.ConfigFile != "" {
+		log.Printf("Using settings from %s\n", opts.ConfigFile)
+	}
+
+	progress := log.New(log.Writer(), log.Prefix(), log.Flags())
+	log.SetOutput(io.Discard)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	run := tuneRunner(ctx, opts, instances, progress)
+
+	// The settings tune starts from race in the first iteration, so that the
+	// configuration found is no worse than them on the steps they share.
+	elites := []*candidate{{0, paramValues(opts.SolveOptions), nil}}
+	nextID := 1
+	used := 0
+
+	// The budget is shared by the first nIterations iterations, and the
+	// iterations after them use whatever is left.
+	for iteration := 1; ctx.Err() == nil; iteration++ {
+		budget := opts.Budget - used
+		if iteration < nIterations {
+			budget /= nIterations - iteration + 1
+		} else if budget < firstTest {
+			break
+		}
+
+		n := budget / (firstTest + int(math.Min(5, float64(iteration))))
+		if n <= len(elites) {
+			if iteration < nIterations {
+				continue
+			}
+
+			n = len(elites) + 1
+		}
+
+		// Until a race has been run, there are no elites to sample around.
+		sampleFrom := elites
+		if used == 0 {
+			sampleFrom = nil
+		}
+
+		cands := append(append([]*candidate{}, elites...), sampleCandidates(rng, sampleFrom, n-len(elites), iteration, nextID)...)
+		nextID += n - len(elites)
+
+		progress.Printf("Iteration %d: racing %d configurations (%d elites) with %d runs\n", iteration, len(cands), len(elites), budget)
+
+		survivors, runs := race(cands, nElites, budget, run)
+		if runs == 0 {
+			break
+		}
+
+		used += runs
+
+		if len(survivors[0].results) > 0 {
+			elites = survivors
+			if len(elites) > nElites {
+				elites = elites[:nElites]
+			}
+		}
+
+		progress.Printf("Iteration %d: %d configurations remain after %d runs; the best is %s\n", iteration, len(survivors), runs, describeParams(elites[0].values))
+	}
+
+	log.SetOutput(progress.Writer())
+
+	if ctx.Err() != nil {
+		log.Printf("Interrupted; writing the best configuration found so far\n")
+	}
+
+	best := applyParams(opts.SolveOptions, elites[0].values)
+	best.Ideal = ideal
+
+	writeOutputFile(opts.Output, func(w io.Writer) error { return best.Config().Write(w) })
+	log.Printf("Made %d runs; use the configuration with spaghetti solve --config %s\n", used, opts.Output)
+}
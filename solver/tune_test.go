@@ -0,0 +1,78 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package solver
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/brennie/spaghetti/options"
+)
+
+// Fail if the values are not a configuration that solve accepts.
+func checkParams(t *testing.T, values []float64) {
+	t.Helper()
+
+	for i, p := range tuneParams {
+		if v := values[i]; v < p.min || v > p.max || (p.integer && v != math.Trunc(v)) {
+			t.Errorf("%s is %g; want a value in [%g, %g]", p.name, v, p.min, p.max)
+		}
+	}
+
+	if values[paramMaxPop] <= values[paramMinPop] || values[paramMutation]+values[paramLocalCrossover] > 100 {
+		t.Errorf("got the invalid configuration %s", describeParams(values))
+	}
+}
+
+func TestRepair(t *testing.T) {
+	values := []float64{1, 9.6, 100, 40, 30, 95, 0.12345, -1}
+	repair(values)
+	checkParams(t, values)
+
+	want := []float64{2, 8, 100, 101, 30, 70, 0.123, 0}
+	for i, p := range tuneParams {
+		if values[i] != want[i] {
+			t.Errorf("%s is %g; want %g", p.name, values[i], want[i])
+		}
+	}
+}
+
+func TestSampleCandidates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, c := range sampleCandidates(rng, nil, 50, 1, 1) {
+		checkParams(t, c.values)
+	}
+
+	elite := &candidate{0, paramValues(options.SolveOptions{NIslands: 4, NSlaves: 4, MinPop: 50, MaxPop: 75, PMutate: 5, PLocal: 75, MaxMutate: 0.2, GMRatio: 0.05}), nil}
+	late := sampleCandidates(rng, []*candidate{elite}, 50, 8, 51)
+
+	for i, c := range late {
+		checkParams(t, c.values)
+
+		if c.id != 51+i {
+			t.Errorf("got the id %d; want %d", c.id, 51+i)
+		}
+
+		// By the eighth iteration, the spread is a fiftieth of the first.
+		if math.Abs(c.values[paramMinPop]-50) > 10 {
+			t.Errorf("sampled minpop %g from an elite with 50", c.values[paramMinPop])
+		}
+	}
+}
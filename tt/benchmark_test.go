@@ -0,0 +1,141 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// The benchmarks are an external test package so that they can use the
+// generator, which imports tt.
+package tt_test
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/brennie/spaghetti/generator"
+	"github.com/brennie/spaghetti/tt"
+)
+
+// The instance shared by the benchmarks, which is about the size of the
+// largest ITC2007 post-enrolment instances.
+var (
+	benchmarkOnce sync.Once
+	benchmarkInst *tt.Instance
+)
+
+// Get the instance shared by the benchmarks. A feasible solution is planted so
+// that every event has a non-empty domain.
+func benchmarkInstance(b *testing.B) *tt.Instance {
+	benchmarkOnce.Do(func() {
+		params := generator.DefaultParams()
+		params.NEvents = 400
+		params.NRooms = 10
+		params.NFeatures = 10
+		params.NStudents = 1000
+		params.MaxCapacity = 200
+		params.Plant = generator.PlantFeasible
+
+		gen, err := generator.New(params, rand.New(rand.NewSource(1)))
+		if err != nil {
+			panic(err)
+		}
+
+		var buf bytes.Buffer
+		if err = gen.WriteInstance(&buf); err != nil {
+			panic(err)
+		}
+
+		if benchmarkInst, err = tt.Parse(&buf); err != nil {
+			panic(err)
+		}
+	})
+
+	return benchmarkInst
+}
+
+// Create a solution with every event assigned to a random Rat in its domain.
+func randomSolution(inst *tt.Instance, rng *rand.Rand) *tt.Solution {
+	s := inst.NewSolution()
+
+	for event, domain := range inst.Domains {
+		s.Assign(event, domain[rng.Intn(len(domain))])
+	}
+
+	return s
+}
+
+// Benchmark evaluating the value of a solution after reassigning an event,
+// which is how the local searches judge their moves.
+func BenchmarkValue(b *testing.B) {
+	inst := benchmarkInstance(b)
+	rng := rand.New(rand.NewSource(2))
+	s := randomSolution(inst, rng)
+	defer s.Free()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event := rng.Intn(inst.NEvents())
+		domain := inst.Domains[event]
+		s.AssignValue(event, domain[rng.Intn(len(domain))])
+	}
+}
+
+// Benchmark reassigning events of a complete solution.
+func BenchmarkAssign(b *testing.B) {
+	inst := benchmarkInstance(b)
+	rng := rand.New(rand.NewSource(3))
+	s := randomSolution(inst, rng)
+	defer s.Free()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event := rng.Intn(inst.NEvents())
+		domain := inst.Domains[event]
+		s.Assign(event, domain[rng.Intn(len(domain))])
+	}
+}
+
+// Benchmark cloning a complete solution.
+func BenchmarkClone(b *testing.B) {
+	inst := benchmarkInstance(b)
+	s := randomSolution(inst, rand.New(rand.NewSource(4)))
+	defer s.Free()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clone := s.Clone()
+
+		b.StopTimer()
+		clone.Free()
+		b.StartTimer()
+	}
+}
+
+// Benchmark freeing a complete solution back to the pool.
+func BenchmarkFree(b *testing.B) {
+	inst := benchmarkInstance(b)
+	s := randomSolution(inst, rand.New(rand.NewSource(5)))
+	defer s.Free()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		clone := s.Clone()
+		b.StartTimer()
+
+		clone.Free()
+	}
+}
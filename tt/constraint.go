@@ -20,7 +20,8 @@ package tt
 // A constraint pair describes that there is a constraint between two events
 // in the instance.
 type ConstraintPair struct {
-	EventA, EventB int // The two variables with constraints.
+	EventA int `json:"event_a"` // The first variable with constraints.
+	EventB int `json:"event_b"` // The second variable with constraints.
 }
 
 // Generate a constraint pair such that EventA < EventB
@@ -43,11 +44,14 @@ func (s *Solution) ConstraintPairs() (pairs map[ConstraintPair]int) {
 		}
 	}
 
-	for student := range s.attendance {
-		for time := range s.attendance[student] {
-			if nEvents := len(s.attendance[student][time]); nEvents >= 2 {
-				for eventA := range s.attendance[student][time] {
-					for eventB := range s.attendance[student][time] {
+	// The students of a curriculum-based instance are its curricula, whose
+	// conflicts are counted with the rest of the conflicting courses below.
+	for student := 0; student < s.inst.nStudents && s.inst.ctt == nil; student++ {
+		for time := 0; time < s.inst.nTimes; time++ {
+			if nEvents := s.nEventsAt(student, time); nEvents >= 2 {
+				events := s.eventsAt(student, time)
+				for _, eventA := range events {
+					for _, eventB := range events {
 						if eventA < eventB {
 							pairs[pair(eventA, eventB)]++
 						}
@@ -57,10 +61,12 @@ func (s *Solution) ConstraintPairs() (pairs map[ConstraintPair]int) {
 		}
 	}
 
+	// Exams can share a room, so they only conflict if the room cannot hold
+	// them.
 	for ratIndex := range s.events {
-		if nEvents := len(s.events[ratIndex]); nEvents >= 2 {
-			for eventA := range s.events[ratIndex] {
-				for eventB := range s.events[ratIndex] {
+		if nEvents := len(s.events[ratIndex]); nEvents >= 2 && (s.inst.exam == nil || s.roomValue(ratIndex).Violations > 0) {
+			for _, eventA := range s.events[ratIndex] {
+				for _, eventB := range s.events[ratIndex] {
 					if eventA < eventB {
 						pairs[pair(eventA, eventB)]++
 					}
@@ -72,11 +78,31 @@ func (s *Solution) ConstraintPairs() (pairs map[ConstraintPair]int) {
 	for eventIndex := range s.rats {
 		event := &s.inst.events[eventIndex]
 		if rat := s.rats[eventIndex]; rat.Assigned() {
-			for otherIndex := range event.after {
+			for _, otherIndex := range event.after {
 				if other := s.rats[otherIndex]; other.Assigned() && !other.After(rat) {
 					pairs[pair(eventIndex, otherIndex)]++
 				}
 			}
+
+			for _, otherIndex := range event.coincident {
+				if other := s.rats[otherIndex]; eventIndex < otherIndex && other.Assigned() && other.Time != rat.Time {
+					pairs[pair(eventIndex, otherIndex)]++
+				}
+			}
+
+			for _, otherIndex := range event.separate {
+				if other := s.rats[otherIndex]; eventIndex < otherIndex && other.Assigned() && other.Time == rat.Time {
+					pairs[pair(eventIndex, otherIndex)]++
+				}
+			}
+
+			if s.inst.ctt != nil {
+				for _, otherIndex := range event.exclude {
+					if other := s.rats[otherIndex]; eventIndex < otherIndex && other.Assigned() && other.Time == rat.Time {
+						pairs[pair(eventIndex, otherIndex)]++
+					}
+				}
+			}
 		}
 	}
 
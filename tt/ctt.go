@@ -0,0 +1,55 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// The weights of the soft constraints of a curriculum-based instance, as
+// fixed by ITC2007.
+const (
+	roomCapacityWeight          = 1 // The penalty for each student without a seat.
+	minWorkingDaysWeight        = 5 // The penalty for each day a course is short of its minimum.
+	curriculumCompactnessWeight = 2 // The penalty for each isolated lecture of a curriculum.
+	roomStabilityWeight         = 1 // The penalty for each room a course uses beyond the first.
+)
+
+// A course of a curriculum-based instance.
+type course struct {
+	name     string // The name of the course.
+	teacher  string // The teacher of the course.
+	lectures []int  // The lectures (events) of the course.
+	minDays  int    // The minimum number of days the lectures should be spread over.
+	students int    // The number of students enrolled in the course.
+}
+
+// A curriculum of a curriculum-based instance, i.e., a group of courses that
+// share students.
+type curriculum struct {
+	name    string // The name of the curriculum.
+	courses []int  // The courses in the curriculum.
+}
+
+// The parts of a curriculum-based instance that post-enrolment instances do
+// not have. The events of a curriculum-based instance are the lectures of its
+// courses and the times are the days and periods of its week. The students are
+// its curricula, so that the attendance matrix records when each curriculum
+// has lectures.
+type ctt struct {
+	name      string       // The name of the instance.
+	courses   []course     // The courses.
+	curricula []curriculum // The curricula.
+	roomNames []string     // The name of each room.
+}
@@ -0,0 +1,158 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// The value of a solution to a curriculum-based instance is made up of the
+// following hard constraint violations:
+//  1. for each pair of lectures of conflicting courses (the same course,
+//     courses with the same teacher, or courses in the same curriculum) at the
+//     same time, one; and
+//  2. for each room and time, the number of pairs of lectures assigned to it.
+//
+// Lectures are never assigned to times at which their course is unavailable,
+// as the domains do not contain them. The fitness is made up of the following
+// soft constraint penalties:
+//  1. for each lecture, the number of its course's students beyond the
+//     capacity of its room (RoomCapacity);
+//  2. for each course, five for each day it has lectures on fewer than its
+//     minimum working days (MinimumWorkingDays);
+//  3. for each curriculum and time with lectures of the curriculum but none in
+//     the adjacent periods of the same day, two for each of those lectures
+//     (CurriculumCompactness); and
+//  4. for each course, one for each room its lectures use beyond the first
+//     (RoomStability).
+
+// Compute the value of the constraints involving the lecture when it is moved
+// from one room and time to another. This is the curriculum-based counterpart
+// of affected.
+func (s *Solution) cttAffected(event int, from, to Rat) (value Value) {
+	e := &s.inst.events[event]
+	week := s.inst.week
+
+	if rat := s.rats[event]; rat.Assigned() {
+		value.Violations += s.lectureConflicts(event, rat.Time)
+		value.Fitness += s.roomCapacityPenalty(event, rat)
+	}
+
+	if from.Assigned() {
+		if nEvents := len(s.events[s.inst.ratIndex(from)]); nEvents >= 2 {
+			value.Violations += (nEvents * (nEvents - 1)) / 2
+		}
+	}
+
+	if to.Assigned() && to != from {
+		if nEvents := len(s.events[s.inst.ratIndex(to)]); nEvents >= 2 {
+			value.Violations += (nEvents * (nEvents - 1)) / 2
+		}
+	}
+
+	// The students of a lecture are the curricula of its course.
+	for _, curr := range e.students {
+		if from.Assigned() {
+			value.Fitness += s.compactnessPenalty(curr, week.day(from.Time))
+		}
+
+		if to.Assigned() && (!from.Assigned() || week.day(to.Time) != week.day(from.Time)) {
+			value.Fitness += s.compactnessPenalty(curr, week.day(to.Time))
+		}
+	}
+
+	minDays, stability := s.coursePenalties(e.course)
+	value.Fitness += minDays + stability
+
+	return
+}
+
+// Determine the number of lectures of courses conflicting with the lecture's
+// that are at the given time.
+func (s *Solution) lectureConflicts(event, time int) (conflicts int) {
+	for _, other := range s.inst.events[event].exclude {
+		if s.rats[other].Time == time {
+			conflicts++
+		}
+	}
+
+	return
+}
+
+// Compute the RoomCapacity penalty of a lecture in the given room.
+func (s *Solution) roomCapacityPenalty(event int, rat Rat) int {
+	students := s.inst.ctt.courses[s.inst.events[event].course].students
+
+	if excess := students - s.inst.rooms[rat.Room].capacity; excess > 0 {
+		return roomCapacityWeight * excess
+	}
+
+	return 0
+}
+
+// Compute the CurriculumCompactness penalty of a curriculum on a single day.
+func (s *Solution) compactnessPenalty(curr, day int) (penalty int) {
+	periodsPerDay := s.inst.week.Periods
+	start := day * periodsPerDay
+
+	for period := 0; period < periodsPerDay; period++ {
+		nLectures := s.nEventsAt(curr, start+period)
+		if nLectures == 0 {
+			continue
+		}
+
+		if (period == 0 || !s.attends(curr, start+period-1)) && (period == periodsPerDay-1 || !s.attends(curr, start+period+1)) {
+			penalty += curriculumCompactnessWeight * nLectures
+		}
+	}
+
+	return
+}
+
+// Compute the MinimumWorkingDays and RoomStability penalties of a course.
+func (s *Solution) coursePenalties(c int) (minDays, stability int) {
+	course := &s.inst.ctt.courses[c]
+	var days, rooms []int
+
+	for _, lecture := range course.lectures {
+		rat := s.rats[lecture]
+		if !rat.Assigned() {
+			continue
+		}
+
+		days = appendDistinct(days, s.inst.week.day(rat.Time))
+		rooms = appendDistinct(rooms, rat.Room)
+	}
+
+	if short := course.minDays - len(days); short > 0 {
+		minDays = minWorkingDaysWeight * short
+	}
+
+	if len(rooms) > 1 {
+		stability = roomStabilityWeight * (len(rooms) - 1)
+	}
+
+	return
+}
+
+// Append the value to the slice if the slice does not already contain it.
+func appendDistinct(values []int, value int) []int {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
@@ -0,0 +1,216 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A tiny curriculum-based instance with a 2x3 week, small enough that the
+// values of its solutions can be worked out by hand:
+//  1. course c0 has two lectures (0 and 1) over at least two days for 30
+//     students, c1 has lecture 2 for 10 students and the same teacher as c0,
+//     and c2 has lecture 3 for 20 students;
+//  2. room rA seats 25 students and rB seats 40;
+//  3. curriculum q0 consists of c0 and c2; and
+//  4. c2 cannot be taught in the last period of the second day (time 5).
+const tinyCtt = `Name: Tiny
+Courses: 3
+Rooms: 2
+Days: 2
+Periods_per_day: 3
+Curricula: 1
+Constraints: 1
+
+COURSES:
+c0 t0 2 2 30
+c1 t0 1 1 10
+c2 t1 1 1 20
+
+ROOMS:
+rA 25
+rB 40
+
+CURRICULA:
+q0 2 c0 c2
+
+UNAVAILABILITY_CONSTRAINTS:
+c2 1 2
+
+END.
+`
+
+// Parse the tiny curriculum-based instance.
+func tinyCttInstance(t *testing.T) *Instance {
+	inst, err := Parse(strings.NewReader(tinyCtt))
+	if err != nil {
+		t.Fatalf("could not parse the tiny curriculum-based instance: %s", err)
+	}
+
+	return inst
+}
+
+func TestParseCtt(t *testing.T) {
+	inst := tinyCttInstance(t)
+
+	if inst.Format() != CurriculumBased || inst.NEvents() != 4 || inst.NTimes() != 6 || inst.Week() != (Week{2, 3}) {
+		t.Errorf("got a %s instance with %d lectures, %d times, and a %s week; want a curriculum-based instance with 4 lectures, 6 times, and a 2x3 week", inst.Format(), inst.NEvents(), inst.NTimes(), inst.Week())
+	}
+
+	for event, want := range []int{12, 12, 12, 10} {
+		if got := len(inst.Domains[event]); got != want {
+			t.Errorf("lecture %d has %d values in its domain; want %d", event, got, want)
+		}
+	}
+
+	// Lectures of the same course, of courses with the same teacher, and of
+	// courses in the same curriculum conflict.
+	for event, want := range [][]int{{1, 2, 3}, {0, 2, 3}, {0, 1}, {0, 1}} {
+		if got := inst.events[event].exclude; !reflect.DeepEqual(got, want) {
+			t.Errorf("lecture %d conflicts with %v; want %v", event, got, want)
+		}
+	}
+
+	// An empty timetable is penalized for every course's minimum working
+	// days.
+	empty := inst.NewSolution()
+	defer empty.Free()
+
+	if want := (Value{0, 2*5 + 5 + 5}); empty.Value() != want || empty.Distance() != 2*30+10+20 {
+		t.Errorf("an empty solution has the value %s and distance %d; want %s and distance %d", empty.Value(), empty.Distance(), want, 2*30+10+20)
+	}
+}
+
+func TestCttValues(t *testing.T) {
+	inst := tinyCttInstance(t)
+
+	// The only penalty is for lecture 1, the only lecture of q0 on the second
+	// day.
+	valid := inst.SolutionFromRats([]Rat{{1, 0}, {1, 3}, {0, 2}, {0, 1}})
+	defer valid.Free()
+
+	if want := (Value{0, 2}); valid.Value() != want || valid.Distance() != 0 {
+		t.Errorf("got the value %s and distance %d; want %s and distance 0", valid.Value(), valid.Distance(), want)
+	}
+
+	// Every lecture is in room rA at time 0: five pairs of lectures conflict
+	// and six pairs share the room. Both lectures of c0 have five students
+	// without a seat and are on one day, and each lecture of q0 is isolated.
+	clash := inst.SolutionFromRats([]Rat{{0, 0}, {0, 0}, {0, 0}, {0, 0}})
+	defer clash.Free()
+
+	if want := (Value{5 + 6, 2*5 + 5 + 3*2}); clash.Value() != want {
+		t.Errorf("got the value %s; want %s", clash.Value(), want)
+	}
+
+	r := clash.Report()
+	if want := []ConstraintPair{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}}; !reflect.DeepEqual(r.Curriculum.Conflicts, want) {
+		t.Errorf("got the conflicts %v; want %v", r.Curriculum.Conflicts, want)
+	}
+
+	if want := []CoursePenalty{{0, "c0", 10, 5, 0}}; !reflect.DeepEqual(r.Curriculum.Courses, want) {
+		t.Errorf("got the course penalties %v; want %v", r.Curriculum.Courses, want)
+	}
+
+	if want := []CurriculumPenalty{{0, "q0", 6}}; !reflect.DeepEqual(r.Curriculum.Curricula, want) {
+		t.Errorf("got the curriculum penalties %v; want %v", r.Curriculum.Curricula, want)
+	}
+}
+
+// Determine the value of a curriculum-based solution from its report.
+func cttReportValue(s *Solution) (value Value) {
+	r := s.Report()
+
+	value.Violations = len(r.Curriculum.Conflicts)
+	for _, clash := range r.RatClashes {
+		value.Violations += clash.Violations
+	}
+
+	for _, penalty := range r.Curriculum.Courses {
+		value.Fitness += penalty.Total()
+	}
+	for _, penalty := range r.Curriculum.Curricula {
+		value.Fitness += penalty.Compactness
+	}
+
+	return
+}
+
+func TestCttMoves(t *testing.T) {
+	inst := tinyCttInstance(t)
+	rng := rand.New(rand.NewSource(1))
+
+	s := inst.NewSolution()
+	defer s.Free()
+
+	for event, domain := range inst.Domains {
+		s.Assign(event, domain[rng.Intn(len(domain))])
+	}
+
+	for i := 0; i < 2000; i++ {
+		event := rng.Intn(inst.NEvents())
+
+		var move Move
+		switch rng.Intn(5) {
+		case 0:
+			move = s.AssignMove(event, inst.Domains[event][rng.Intn(len(inst.Domains[event]))])
+
+		case 1:
+			move = s.AssignMove(event, badRat)
+
+		case 2:
+			move = s.RoomMove(event, rng.Intn(inst.nRooms))
+
+		case 3:
+			move = s.SwapMove(event, rng.Intn(inst.NEvents()))
+
+		case 4:
+			move = s.KempeMove(event, rng.Intn(inst.NTimes()))
+		}
+
+		if !s.CanApply(move) {
+			continue
+		}
+
+		want := s.MoveValue(move)
+		s.Apply(move)
+
+		if s.Value() != want || cttReportValue(s) != want {
+			t.Fatalf("applying %v gave the value %s (%s from the report); want %s", move, s.Value(), cttReportValue(s), want)
+		}
+	}
+
+	// Solution files do not tell the lectures of a course apart, so parsing a
+	// written solution may reorder them, but it gives the same file again.
+	var written, rewritten bytes.Buffer
+	s.Write(&written)
+
+	parsed, err := inst.ParseSolution(bytes.NewReader(written.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parsed.Free()
+
+	if parsed.Write(&rewritten); rewritten.String() != written.String() || parsed.Value() != s.Value() {
+		t.Errorf("parsing the written solution:\n%s\ngave the value %s and the solution:\n%s", written.String(), parsed.Value(), rewritten.String())
+	}
+}
@@ -17,14 +17,47 @@
 
 package tt
 
-// A timetabling event, i.e. a class or an exam.
+// A timetabling event, i.e. a class or an exam. The lists of other events and
+// students are in increasing order.
 type event struct {
 	id       int          // The event's identifier.
-	times    [45]bool     // The times in which the event can be scheduled.
+	times    []bool       // The times in which the event can be scheduled.
 	features map[int]bool // The features that the event requires.
 	rooms    map[int]bool // The rooms in which the event can be scheduled.
-	before   map[int]bool // The events which happen before this event.
-	after    map[int]bool // The events which happen after this event.
-	students map[int]bool // The students which attend this event.
-	exclude  map[int]bool // The events that cannot occur at the same time as this event.
+	before   []int        // The events which happen before this event.
+	after    []int        // The events which happen after this event.
+	students []int        // The students which attend this event.
+	exclude  []int        // The events that cannot occur at the same time as this event.
+
+	// The following are only used by examination instances.
+	duration   int   // The length of the exam in minutes.
+	coincident []int // The exams that must be in the same period as this exam.
+	separate   []int // The exams that must not be in the same period as this exam.
+	exclusive  bool  // Must the exam have its room to itself?
+	large      bool  // Is the exam one of the largest, which should not be in the last periods?
+
+	// The following is only used by curriculum-based instances.
+	course int // The course the lecture belongs to.
+}
+
+// Sort the lists of each event and remove their duplicates, and build the list
+// of events that each student attends. The parsers build the lists in whatever
+// order is convenient and call this once they are done.
+func (inst *Instance) indexEvents() {
+	inst.studentEvents = make([][]int, inst.nStudents)
+
+	for eventIndex := range inst.events {
+		e := &inst.events[eventIndex]
+
+		e.before = distinct(e.before)
+		e.after = distinct(e.after)
+		e.students = distinct(e.students)
+		e.exclude = distinct(e.exclude)
+		e.coincident = distinct(e.coincident)
+		e.separate = distinct(e.separate)
+
+		for _, student := range e.students {
+			inst.studentEvents[student] = append(inst.studentEvents[student], eventIndex)
+		}
+	}
 }
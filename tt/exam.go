@@ -0,0 +1,82 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// A period of an examination instance. Periods are given in chronological
+// order and each day's periods are contiguous.
+type period struct {
+	date     string // The date of the period, as given in the instance.
+	start    string // The start time of the period, as given in the instance.
+	day      int    // The day of the period, counting from 0.
+	duration int    // The length of the period in minutes.
+	penalty  int    // The penalty for each exam in the period.
+}
+
+// The weights of the soft constraints of an examination instance.
+type examWeights struct {
+	twoInARow         int // The penalty for each pair of a student's exams in a row on the same day.
+	twoInADay         int // The penalty for each other pair of a student's exams on the same day.
+	periodSpread      int // The number of periods within which each pair of a student's exams is penalized by 1.
+	nonMixedDurations int // The penalty for each extra duration among the exams sharing a room.
+	frontLoadExams    int // The number of the largest exams that should not be in the last periods.
+	frontLoadPeriods  int // The number of last periods that the largest exams should avoid.
+	frontLoad         int // The penalty for each large exam in the last periods.
+}
+
+// The parts of an examination instance that post-enrolment instances do not
+// have. The events of an examination instance are its exams and the times are
+// its periods.
+type exam struct {
+	periods []period    // The periods.
+	weights examWeights // The soft constraint weights.
+}
+
+// Determine the penalty for a student having one exam at each of the two
+// periods, split into the two in a row, two in a day, and period spread
+// penalties.
+func (e *exam) pairPenalty(first, second int) (row, day, spread int) {
+	distance := second - first
+	if distance < 0 {
+		distance = -distance
+	}
+
+	if e.periods[first].day == e.periods[second].day {
+		if distance == 1 {
+			row = e.weights.twoInARow
+		} else {
+			day = e.weights.twoInADay
+		}
+	}
+
+	if distance <= e.weights.periodSpread {
+		spread = 1
+	}
+
+	return
+}
+
+// Determine if a pair of a student's exams at the two periods can be
+// penalized, i.e., they are on the same day or within the period spread.
+func (e *exam) near(first, second int) bool {
+	distance := second - first
+	if distance < 0 {
+		distance = -distance
+	}
+
+	return e.periods[first].day == e.periods[second].day || distance <= e.weights.periodSpread
+}
@@ -0,0 +1,237 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// The value of a solution to an examination instance is made up of the
+// following hard constraint violations:
+//  1. for each student and period, the number of exams the student sits in
+//     that period beyond the first;
+//  2. for each room and period, one if the exams in it have more students
+//     than the room seats, plus the number of room exclusive exams in it if it
+//     has more than one exam;
+//  3. the number of pairs of exams that are not ordered as required (AFTER);
+//  4. the number of pairs of exams that must share a period but do not
+//     (EXAM_COINCIDENCE); and
+//  5. the number of pairs of exams that must not share a period but do
+//     (EXCLUSION).
+//
+// Exams are never assigned to periods that are too short or rooms that are
+// too small for them alone, as the domains do not contain them. The fitness is
+// made up of the following soft constraint penalties:
+//  1. for each student and pair of the student's exams in consecutive periods
+//     on the same day, the two in a row weight;
+//  2. for each student and other pair of the student's exams on the same day,
+//     the two in a day weight;
+//  3. for each student and pair of the student's exams within the period
+//     spread of each other, one;
+//  4. for each room and period, the non-mixed durations weight for each
+//     distinct exam duration in it beyond the first;
+//  5. for each of the largest exams in the last periods, the front load
+//     weight; and
+//  6. for each exam, the penalties of its room and period.
+//
+// Pairs of a student's exams in the same period are violations, not penalties.
+
+// Compute the value of the constraints involving the exam when it is moved
+// from one room and time to another. This is the examination counterpart of
+// affected.
+func (s *Solution) examAffected(event int, from, to Rat) (value Value) {
+	e := &s.inst.events[event]
+
+	var timeArray [2]int
+	times := timeArray[:0]
+
+	if from.Assigned() {
+		times = append(times, from.Time)
+	}
+
+	if to.Assigned() && (!from.Assigned() || to.Time != from.Time) {
+		times = append(times, to.Time)
+	}
+
+	for _, student := range e.students {
+		for i, time := range times {
+			if nEvents := s.nEventsAt(student, time); nEvents >= 2 {
+				value.Violations += nEvents - 1
+			}
+
+			value.Fitness += s.studentPairPenalty(student, time, times[:i])
+		}
+	}
+
+	if from.Assigned() {
+		value.add(s.roomValue(s.inst.ratIndex(from)))
+	}
+
+	if to.Assigned() && to != from {
+		value.add(s.roomValue(s.inst.ratIndex(to)))
+	}
+
+	if rat := s.rats[event]; rat.Assigned() {
+		value.add(s.examValue(event, rat))
+	}
+
+	return
+}
+
+// Compute the penalty of the pairs of a student's exams made up of an exam in
+// the given period and an exam in any other period, except for the periods in
+// skip (whose pairs have already been counted).
+func (s *Solution) studentPairPenalty(student, time int, skip []int) (penalty int) {
+	count := s.nEventsAt(student, time)
+	if count == 0 {
+		return
+	}
+
+	e := s.inst.exam
+	pair := func(other int) {
+		for _, skipped := range skip {
+			if other == skipped {
+				return
+			}
+		}
+
+		if otherCount := s.nEventsAt(student, other); otherCount > 0 {
+			row, day, spread := e.pairPenalty(time, other)
+			penalty += count * otherCount * (row + day + spread)
+		}
+	}
+
+	for other := time - 1; other >= 0 && e.near(time, other); other-- {
+		pair(other)
+	}
+
+	for other := time + 1; other < s.inst.nTimes && e.near(time, other); other++ {
+		pair(other)
+	}
+
+	return
+}
+
+// Compute the value of the constraints on a single room and period: the
+// capacity of the room, room exclusive exams, and mixed durations.
+func (s *Solution) roomValue(ratIndex int) (value Value) {
+	exams := s.events[ratIndex]
+	if len(exams) == 0 {
+		return
+	}
+
+	room := &s.inst.rooms[s.inst.ratFromIndex(ratIndex).Room]
+	seats := 0
+	var durations []int
+
+	for _, exam := range exams {
+		e := &s.inst.events[exam]
+		seats += len(e.students)
+
+		if e.exclusive && len(exams) > 1 {
+			value.Violations++
+		}
+
+		durations = appendDistinct(durations, e.duration)
+	}
+
+	if seats > room.capacity {
+		value.Violations++
+	}
+
+	value.Fitness += (len(durations) - 1) * s.inst.exam.weights.nonMixedDurations
+
+	return
+}
+
+// Compute the value of the constraints on a single assigned exam: its ordering
+// and period constraints with other exams, the front load penalty, and the
+// penalties of its room and period.
+func (s *Solution) examValue(event int, rat Rat) (value Value) {
+	e := &s.inst.events[event]
+	w := &s.inst.exam.weights
+
+	for _, after := range e.after {
+		if other := s.rats[after]; other.Assigned() && !other.After(rat) {
+			value.Violations++
+		}
+	}
+
+	for _, before := range e.before {
+		if other := s.rats[before]; other.Assigned() && !rat.After(other) {
+			value.Violations++
+		}
+	}
+
+	for _, coincident := range e.coincident {
+		if other := s.rats[coincident]; other.Assigned() && other.Time != rat.Time {
+			value.Violations++
+		}
+	}
+
+	for _, separate := range e.separate {
+		if other := s.rats[separate]; other.Assigned() && other.Time == rat.Time {
+			value.Violations++
+		}
+	}
+
+	if e.large && rat.Time >= s.inst.nTimes-w.frontLoadPeriods {
+		value.Fitness += w.frontLoad
+	}
+
+	value.Fitness += s.inst.rooms[rat.Room].penalty + s.inst.exam.periods[rat.Time].penalty
+
+	return
+}
+
+// Remove the entries that conflict with assigning the exam to the room and
+// time from the domains of the other exams. Exams can share a room, so an exam
+// only loses the room and time if it would no longer fit or either exam is
+// room exclusive. Exams that must share the period lose every other period and
+// exams that must not share it lose the period.
+func (s *Solution) shrinkExamDomains(event int, rat Rat, domains []map[Rat]bool) {
+	e := &s.inst.events[event]
+	room := &s.inst.rooms[rat.Room]
+
+	seats := 0
+	exclusive := false
+	for _, exam := range s.events[s.inst.ratIndex(rat)] {
+		seats += len(s.inst.events[exam].students)
+		exclusive = exclusive || s.inst.events[exam].exclusive
+	}
+
+	for other := range domains {
+		if domains[other][rat] {
+			if exclusive || s.inst.events[other].exclusive || seats+len(s.inst.events[other].students) > room.capacity {
+				delete(domains[other], rat)
+			}
+		}
+	}
+
+	for _, coincident := range e.coincident {
+		for other := range domains[coincident] {
+			if other.Time != rat.Time {
+				delete(domains[coincident], other)
+			}
+		}
+	}
+
+	for _, separate := range e.separate {
+		for other := range domains[separate] {
+			if other.Time == rat.Time {
+				delete(domains[separate], other)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A tiny examination instance, small enough that the values of its solutions
+// can be worked out by hand:
+//  1. exam 0 is sat by students 0 and 1, exam 1 by students 0 and 2, exam 2
+//     (which needs its room to itself) by student 1, and exam 3 by student 3;
+//  2. periods 0 and 1 are on the first day and periods 2 and 3 on the second,
+//     and only period 1 is too short for exam 2;
+//  3. room 0 seats two students, and room 1 seats one at a penalty of 10; and
+//  4. exam 0 must be after exam 3, and exams 1 and 2 must be in different
+//     periods.
+//
+// The students are numbered in the order they first appear. Exams 0 and 1
+// have two students, so they can only be in room 0, and exam 0 is the largest.
+const tinyExam = `[Exams:4]
+60, 10, 11
+60, 10, 12
+120, 11
+30, 13
+[Periods:4]
+15:04:2005, 09:00:00, 120, 0
+15:04:2005, 13:00:00, 60, 5
+16:04:2005, 09:00:00, 120, 0
+16:04:2005, 13:00:00, 120, 0
+[Rooms:2]
+2, 0
+1, 10
+[PeriodHardConstraints]
+0, AFTER, 3
+1, EXCLUSION, 2
+[RoomHardConstraints]
+2, ROOM_EXCLUSIVE
+[InstitutionalWeightings]
+TWOINAROW, 7
+TWOINADAY, 5
+PERIODSPREAD, 3
+NONMIXEDDURATIONS, 10
+FRONTLOAD, 1, 1, 5
+`
+
+// Parse the tiny examination instance.
+func tinyExamInstance(t *testing.T) *Instance {
+	inst, err := Parse(strings.NewReader(tinyExam))
+	if err != nil {
+		t.Fatalf("could not parse the tiny examination instance: %s", err)
+	}
+
+	return inst
+}
+
+func TestParseExam(t *testing.T) {
+	inst := tinyExamInstance(t)
+
+	if inst.Format() != Examination || inst.NEvents() != 4 || inst.NTimes() != 4 || inst.Week() != (Week{}) {
+		t.Errorf("got a %s instance with %d exams, %d periods, and a %s week; want an examination instance with 4 exams, 4 periods, and no week", inst.Format(), inst.NEvents(), inst.NTimes(), inst.Week())
+	}
+
+	for event, want := range []int{4, 4, 6, 8} {
+		if got := len(inst.Domains[event]); got != want {
+			t.Errorf("exam %d has %d values in its domain; want %d", event, got, want)
+		}
+	}
+
+	if got, want := inst.FormatTime(1), "15:04:2005 13:00:00"; got != want {
+		t.Errorf("period 1 is %q; want %q", got, want)
+	}
+
+	if !inst.events[0].large || inst.events[1].large {
+		t.Error("exam 0 is not the only large exam")
+	}
+}
+
+func TestExamValues(t *testing.T) {
+	inst := tinyExamInstance(t)
+
+	// Student 0 has exams in periods 0 and 2, which are within the period
+	// spread; student 1 has exams in a row in periods 2 and 3; exams 2 and 3
+	// are in room 1; and exam 3 is in period 1.
+	valid := inst.SolutionFromRats([]Rat{{0, 2}, {0, 0}, {1, 3}, {1, 1}})
+	defer valid.Free()
+
+	if want := (Value{0, 1 + 7 + 1 + 2*10 + 5}); valid.Value() != want || valid.Distance() != 0 {
+		t.Errorf("got the value %s and distance %d; want %s and distance 0", valid.Value(), valid.Distance(), want)
+	}
+
+	r := valid.Report().Exam
+	if want := []ExamStudentPenalty{{0, 0, 0, 1}, {1, 7, 0, 1}}; !reflect.DeepEqual(r.Students, want) {
+		t.Errorf("got the student penalties %v; want %v", r.Students, want)
+	}
+
+	if r.RoomPenalty != 20 || r.PeriodPenalty != 5 || r.MixedDurations != 0 || r.FrontLoad != 0 {
+		t.Errorf("got room, period, mixed duration, and front load penalties of %d, %d, %d, and %d; want 20, 5, 0, and 0", r.RoomPenalty, r.PeriodPenalty, r.MixedDurations, r.FrontLoad)
+	}
+
+	// Every exam is in room 0 in period 3: students 0 and 1 each sit two
+	// exams at once, the room is over capacity and holds the exclusive exam 2
+	// with others, exam 0 is not after exam 3, and exams 1 and 2 share a
+	// period. There are three durations and the large exam is in the last
+	// period.
+	clash := inst.SolutionFromRats([]Rat{{0, 3}, {0, 3}, {0, 3}, {0, 3}})
+	defer clash.Free()
+
+	if want := (Value{2 + 2 + 1 + 1, 2*10 + 5}); clash.Value() != want {
+		t.Errorf("got the value %s; want %s", clash.Value(), want)
+	}
+
+	report := clash.Report()
+	if want := []RatClash{{Rat{0, 3}, []int{0, 1, 2, 3}, 2}}; !reflect.DeepEqual(report.RatClashes, want) {
+		t.Errorf("got the room clashes %v; want %v", report.RatClashes, want)
+	}
+
+	if want := []ConstraintPair{{1, 2}}; !reflect.DeepEqual(report.Exam.Exclusion, want) {
+		t.Errorf("got the broken exclusion constraints %v; want %v", report.Exam.Exclusion, want)
+	}
+
+	if want := []PrecedenceViolation{{3, 0}}; !reflect.DeepEqual(report.Precedence, want) {
+		t.Errorf("got the broken precedence constraints %v; want %v", report.Precedence, want)
+	}
+
+	// Unassigning exam 1 leaves students 0 and 2 without it.
+	clash.Unassign(1)
+	if clash.Distance() != 2 {
+		t.Errorf("got the distance %d; want 2", clash.Distance())
+	}
+}
+
+// Determine the value of an examination solution from its report.
+func examReportValue(s *Solution) (value Value) {
+	r := s.Report()
+
+	for _, clash := range r.StudentClashes {
+		value.Violations += len(clash.Events) - 1
+	}
+	for _, clash := range r.RatClashes {
+		value.Violations += clash.Violations
+	}
+	value.Violations += len(r.Precedence) + len(r.Exam.Coincidence) + len(r.Exam.Exclusion)
+
+	for _, penalty := range r.Exam.Students {
+		value.Fitness += penalty.Total()
+	}
+	value.Fitness += r.Exam.MixedDurations + r.Exam.FrontLoad + r.Exam.RoomPenalty + r.Exam.PeriodPenalty
+
+	return
+}
+
+func TestExamMoves(t *testing.T) {
+	inst := tinyExamInstance(t)
+	rng := rand.New(rand.NewSource(1))
+
+	s := inst.NewSolution()
+	defer s.Free()
+
+	for event, domain := range inst.Domains {
+		s.Assign(event, domain[rng.Intn(len(domain))])
+	}
+
+	for i := 0; i < 2000; i++ {
+		event := rng.Intn(inst.NEvents())
+
+		var move Move
+		switch rng.Intn(4) {
+		case 0:
+			move = s.AssignMove(event, inst.Domains[event][rng.Intn(len(inst.Domains[event]))])
+
+		case 1:
+			move = s.RoomMove(event, rng.Intn(inst.nRooms))
+
+		case 2:
+			move = s.SwapMove(event, rng.Intn(inst.NEvents()))
+
+		case 3:
+			move = s.KempeMove(event, rng.Intn(inst.NTimes()))
+		}
+
+		if !s.CanApply(move) {
+			continue
+		}
+
+		want := s.MoveValue(move)
+		s.Apply(move)
+
+		if s.Value() != want || examReportValue(s) != want {
+			t.Fatalf("applying %v gave the value %s (%s from the report); want %s", move, s.Value(), examReportValue(s), want)
+		}
+	}
+
+	// Writing the solution and parsing it again gives the same solution.
+	var buf bytes.Buffer
+	s.Write(&buf)
+
+	parsed, err := inst.ParseSolution(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parsed.Free()
+
+	if !reflect.DeepEqual(parsed.Assignments(), s.Assignments()) || parsed.Value() != s.Value() {
+		t.Errorf("parsing the written solution %v gave %v", s.Assignments(), parsed.Assignments())
+	}
+}
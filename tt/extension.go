@@ -0,0 +1,153 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The institutional data of a post-enrolment instance that the ITC2007 format
+// cannot express, which is used by the institutional soft constraints.
+type extension struct {
+	lunch     []int          // The periods of the day that make up the lunch break.
+	maxDaily  int            // The most classes a student should have in a day, or zero for no limit.
+	preferred []map[int]bool // The preferred times of each event, or nil if it has none.
+	courses   [][]int        // The events of each multi-session course.
+	inCourses [][]int        // The courses that each event is a session of.
+}
+
+// Read the institutional data of a post-enrolment instance from the reader.
+// Each non-blank line starts with a keyword, followed by numbers separated by
+// whitespace; everything after a '#' is a comment. The keywords are:
+//  1. lunch, followed by the periods of the day that make up lunch;
+//  2. max_daily_classes, followed by the most classes a student should have in
+//     a day;
+//  3. prefer, followed by an event and its preferred times; and
+//  4. course, followed by the events that are sessions of the same course.
+//
+// Periods count from zero within a day and times count from zero within the
+// week. This must be called before any solutions to the instance are created.
+func (inst *Instance) ReadExtension(r io.Reader) error {
+	if inst.Format() != PostEnrolment {
+		return fmt.Errorf("extensions only apply to post-enrolment instances")
+	}
+
+	ext := &extension{
+		nil,
+		0,
+		make([]map[int]bool, inst.nEvents),
+		nil,
+		make([][]int, inst.nEvents),
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if comment := strings.IndexByte(text, '#'); comment >= 0 {
+			text = text[:comment]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		numbers := make([]int, len(fields)-1)
+		for i, field := range fields[1:] {
+			var err error
+			if numbers[i], err = strconv.Atoi(field); err != nil {
+				return fmt.Errorf(formatError, line, err.Error())
+			}
+		}
+
+		// Check that every number, starting from the given one, is less than
+		// limit.
+		check := func(from, limit int, what string) error {
+			if len(numbers) <= from {
+				return fmt.Errorf(formatError, line, "expected at least one "+what)
+			}
+
+			for _, n := range numbers[from:] {
+				if n < 0 || n >= limit {
+					return fmt.Errorf(formatError, line, fmt.Sprintf("no such %s %d", what, n))
+				}
+			}
+
+			return nil
+		}
+
+		switch fields[0] {
+		case "lunch":
+			if err := check(0, inst.week.Periods, "period"); err != nil {
+				return err
+			}
+
+			ext.lunch = numbers
+
+		case "max_daily_classes":
+			if len(numbers) != 1 || numbers[0] < 1 {
+				return fmt.Errorf(formatError, line, "expected a positive number of classes")
+			}
+
+			ext.maxDaily = numbers[0]
+
+		case "prefer":
+			if len(numbers) == 0 {
+				return fmt.Errorf(formatError, line, "expected an event")
+			} else if event := numbers[0]; event < 0 || event >= inst.nEvents {
+				return fmt.Errorf(formatError, line, fmt.Sprintf("no such event %d", event))
+			} else if err := check(1, inst.nTimes, "time"); err != nil {
+				return err
+			}
+
+			event := numbers[0]
+			if ext.preferred[event] == nil {
+				ext.preferred[event] = make(map[int]bool)
+			}
+
+			for _, time := range numbers[1:] {
+				ext.preferred[event][time] = true
+			}
+
+		case "course":
+			if err := check(0, inst.nEvents, "event"); err != nil {
+				return err
+			}
+
+			for _, event := range numbers {
+				ext.inCourses[event] = append(ext.inCourses[event], len(ext.courses))
+			}
+
+			ext.courses = append(ext.courses, numbers)
+
+		default:
+			return fmt.Errorf(formatError, line, "unknown keyword "+fields[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	inst.ext = ext
+	return nil
+}
@@ -0,0 +1,51 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Determine a fingerprint of the instance: a hash of everything that decides
+// which solutions it has and what their values are. Instances read from
+// different files, or from the same file with different preprocessing, soft
+// constraint weights, or extensions, have different fingerprints.
+func (inst *Instance) Fingerprint() uint64 {
+	h := fnv.New64a()
+
+	fmt.Fprintln(h, inst.Format(), inst.nEvents, inst.nRooms, inst.nFeatures, inst.nStudents, inst.nTimes, inst.week)
+	fmt.Fprintln(h, inst.SoftWeights())
+	fmt.Fprintln(h, inst.Domains)
+	fmt.Fprintln(h, inst.rooms)
+	fmt.Fprintln(h, inst.events)
+
+	if inst.exam != nil {
+		fmt.Fprintln(h, *inst.exam)
+	}
+
+	if inst.ctt != nil {
+		fmt.Fprintln(h, *inst.ctt)
+	}
+
+	if inst.ext != nil {
+		fmt.Fprintln(h, *inst.ext)
+	}
+
+	return h.Sum64()
+}
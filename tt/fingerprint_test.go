@@ -0,0 +1,89 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	want := tinyInstance(t).Fingerprint()
+	if got := tinyInstance(t).Fingerprint(); got != want {
+		t.Fatalf("parsing the same instance twice gave the fingerprints %x and %x", want, got)
+	}
+
+	tests := []struct {
+		name string
+		edit func(inst *Instance) error
+	}{
+		{"reduced domain", func(inst *Instance) error {
+			inst.Domains[0] = inst.Domains[0][1:]
+			return nil
+		}},
+
+		{"soft constraint weights", func(inst *Instance) error {
+			return inst.SetSoftWeights(SoftWeights{"single_class_day": 2, "consecutive_classes": 1, "last_period": 1})
+		}},
+
+		{"extension", func(inst *Instance) error {
+			return inst.ReadExtension(strings.NewReader("lunch 1\n"))
+		}},
+
+		{"student", func(inst *Instance) error {
+			inst.events[2].students = append(inst.events[2].students, 2)
+			return nil
+		}},
+	}
+
+	for _, test := range tests {
+		inst := tinyInstance(t)
+		if err := test.edit(inst); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if inst.Fingerprint() == want {
+			t.Errorf("%s: the fingerprint did not change", test.name)
+		}
+	}
+}
+
+func TestCheckRats(t *testing.T) {
+	inst := tinyInstance(t)
+
+	tests := []struct {
+		rats []Rat
+		ok   bool
+	}{
+		{[]Rat{{0, 0}, {0, 1}, {1, 2}, {0, 2}}, true},
+		{[]Rat{{0, 0}, badRat, badRat, {1, 5}}, true},
+		{[]Rat{{0, 0}, {0, 1}, {1, 2}}, false},
+
+		// Event 1 only fits in room 0.
+		{[]Rat{{0, 0}, {1, 1}, {1, 2}, {0, 2}}, false},
+
+		// There are only six times.
+		{[]Rat{{0, 0}, {0, 1}, {1, 6}, {0, 2}}, false},
+	}
+
+	for _, test := range tests {
+		if err := inst.CheckRats(test.rats); (err == nil) != test.ok {
+			t.Errorf("%v: got the error %v; want an error: %t", test.rats, err, !test.ok)
+		}
+	}
+}
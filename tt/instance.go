@@ -18,46 +18,123 @@
 package tt
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 )
 
+// The format of an instance.
+type Format string
+
+const (
+	PostEnrolment   Format = "post-enrolment"   // The ITC2007 post-enrolment course timetabling track.
+	Examination     Format = "examination"      // The ITC2007 examination timetabling track.
+	CurriculumBased Format = "curriculum-based" // The ITC2007 curriculum-based course timetabling track.
+)
+
 // An instance of a timetabling problem.
 type Instance struct {
-	nEvents   int       // The number of events in the instance.
-	nRooms    int       // The number of rooms in the instance.
-	nFeatures int       // The number of features in the instance.
-	nStudents int       // The number of students in the instance.
-	rooms     []room    // The rooms in the instance.
-	events    []event   // The events in the instance.
-	solnPool  sync.Pool // A object pool for solutions.
-	Domains   [][]Rat   // The master copy of the domains.
+	nEvents       int                  // The number of events in the instance.
+	nRooms        int                  // The number of rooms in the instance.
+	nFeatures     int                  // The number of features in the instance.
+	nStudents     int                  // The number of students in the instance.
+	nTimes        int                  // The number of times in the instance.
+	exam          *exam                // The periods and weights of an examination instance, or nil.
+	ctt           *ctt                 // The courses and curricula of a curriculum-based instance, or nil.
+	week          Week                 // The days and periods of the week (unused by examination instances).
+	soft          []weightedConstraint // The enabled soft constraints of a post-enrolment instance.
+	ext           *extension           // The institutional data of a post-enrolment instance, or nil.
+	empty         Value                // The value of a solution with no events assigned.
+	rooms         []room               // The rooms in the instance.
+	events        []event              // The events in the instance.
+	studentEvents [][]int              // The events that each student attends, in increasing order.
+	solnPool      sync.Pool            // A object pool for solutions.
+	Domains       [][]Rat              // The master copy of the domains, each in order of room and then time.
 }
 
 // Allocate the memory for a solution.
 func (inst *Instance) allocSolution() (s *Solution) {
 	s = &Solution{
 		inst,
-		make([][45]map[int]bool, inst.nStudents),
-		make([]map[int]bool, inst.nRooms*NTimes),
+		make([]uint16, inst.nStudents*inst.nTimes),
+		make([]uint64, inst.nStudents*inst.busyWords()),
+		make([][]int, inst.nRooms*inst.nTimes),
 		make([]Rat, inst.nEvents),
 		inst.Domains,
+		inst.empty,
 	}
 
 	for event := range s.rats {
 		s.rats[event] = badRat
 	}
 
-	for index := range s.events {
-		s.events[index] = make(map[int]bool)
+	return
+}
+
+// Determine the number of words of the bitset of the times at which a single
+// student is busy.
+func (inst *Instance) busyWords() int {
+	return (inst.nTimes + 63) / 64
+}
+
+// Determine the index of the room and time in the array of all rooms and
+// times.
+func (inst *Instance) ratIndex(rat Rat) int {
+	return rat.Room*inst.nTimes + rat.Time
+}
+
+// Build a Rat from an index.
+func (inst *Instance) ratFromIndex(index int) Rat {
+	return Rat{index / inst.nTimes, index % inst.nTimes}
+}
+
+// Determine the format of each line of a solution file, which holds the time
+// and room of an event.
+func (inst *Instance) solutionFormat() string {
+	if inst.exam != nil {
+		return "%d, %d\n"
 	}
 
-	for student := range s.attendance {
-		for time := range s.attendance[student] {
-			s.attendance[student][time] = make(map[int]bool)
-		}
+	return "%d %d\n"
+}
+
+// Determine the format of the instance.
+func (inst *Instance) Format() Format {
+	switch {
+	case inst.exam != nil:
+		return Examination
+
+	case inst.ctt != nil:
+		return CurriculumBased
+
+	default:
+		return PostEnrolment
 	}
+}
 
-	return
+// Describe a time of the instance for people, e.g., "day 2, period 4" for a
+// post-enrolment or curriculum-based instance or the date and start time of an
+// exam period.
+func (inst *Instance) FormatTime(time int) string {
+	switch {
+	case inst.exam != nil:
+		p := &inst.exam.periods[time]
+		return fmt.Sprintf("%s %s", p.date, p.start)
+
+	default:
+		return fmt.Sprintf("day %d, period %d", inst.week.day(time)+1, time%inst.week.Periods+1)
+	}
+}
+
+// Get the shape of the instance's week. This is the zero Week for examination
+// instances, whose days are given by the dates of their periods.
+func (inst *Instance) Week() Week {
+	return inst.week
+}
+
+// Get the number of times in the instance.
+func (inst *Instance) NTimes() int {
+	return inst.nTimes
 }
 
 // Get the number of events in the instance.
@@ -85,3 +162,30 @@ func (inst *Instance) SolutionFromRats(rats []Rat) (s *Solution) {
 
 	return s
 }
+
+// Determine if rats can be the assignments of a solution to the instance, i.e.,
+// there is one for each event, and each is unassigned or in the domain of its
+// event.
+func (inst *Instance) CheckRats(rats []Rat) error {
+	if len(rats) != inst.nEvents {
+		return fmt.Errorf("expected assignments for %d events; got %d", inst.nEvents, len(rats))
+	}
+
+	for event, rat := range rats {
+		if rat.Assigned() && !inst.inDomain(event, rat) {
+			return fmt.Errorf("event %d cannot be assigned to room %d at time %d", event, rat.Room, rat.Time)
+		}
+	}
+
+	return nil
+}
+
+// Determine if the given room and time is in the master copy of the domain of
+// the event, which may have been reduced since the instance was parsed.
+func (inst *Instance) inDomain(event int, rat Rat) bool {
+	domain := inst.Domains[event]
+	index := inst.ratIndex(rat)
+	i := sort.Search(len(domain), func(i int) bool { return inst.ratIndex(domain[i]) >= index })
+
+	return i < len(domain) && domain[i] == rat
+}
@@ -0,0 +1,168 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// The institutional soft constraints are not part of ITC2007, so they have no
+// default weight and are only enabled by a weight file. Except for idle gaps,
+// they use the data of the instance's extension and penalize nothing without
+// it.
+func init() {
+	RegisterSoftConstraint(SoftConstraint{"idle_gaps", idleGaps, nil, nil, false})
+	RegisterSoftConstraint(SoftConstraint{"lunch_break", lunchBreak, nil, nil, false})
+	RegisterSoftConstraint(SoftConstraint{"max_daily_classes", maxDailyClasses, nil, nil, false})
+	RegisterSoftConstraint(SoftConstraint{"preferred_times", nil, preferredTimes, preferredTimesAffected, false})
+	RegisterSoftConstraint(SoftConstraint{"room_stability", nil, roomStability, roomStabilityAffected, false})
+}
+
+// Penalize a student once for each free period between the student's first
+// and last classes on a day.
+func idleGaps(s *Solution, student, day int) (penalty int) {
+	start := day * s.inst.week.Periods
+	first, last := -1, -1
+
+	for period := 0; period < s.inst.week.Periods; period++ {
+		if s.attends(student, start+period) {
+			if first < 0 {
+				first = period
+			}
+
+			last = period
+		}
+	}
+
+	for period := first + 1; period < last; period++ {
+		if !s.attends(student, start+period) {
+			penalty++
+		}
+	}
+
+	return
+}
+
+// Penalize a student who has classes in every period of the lunch break on a
+// day.
+func lunchBreak(s *Solution, student, day int) int {
+	if s.inst.ext == nil || len(s.inst.ext.lunch) == 0 {
+		return 0
+	}
+
+	start := day * s.inst.week.Periods
+	for _, period := range s.inst.ext.lunch {
+		if !s.attends(student, start+period) {
+			return 0
+		}
+	}
+
+	return 1
+}
+
+// Penalize a student once for each class past the maximum on a day.
+func maxDailyClasses(s *Solution, student, day int) int {
+	if s.inst.ext == nil || s.inst.ext.maxDaily == 0 {
+		return 0
+	}
+
+	start := day * s.inst.week.Periods
+	count := s.nBusy(student, start, start+s.inst.week.Periods)
+
+	if count > s.inst.ext.maxDaily {
+		return count - s.inst.ext.maxDaily
+	}
+
+	return 0
+}
+
+// Determine if the event is assigned to a time it does not prefer.
+func (s *Solution) unpreferred(event int) bool {
+	rat := s.rats[event]
+	preferred := s.inst.ext.preferred[event]
+
+	return rat.Assigned() && preferred != nil && !preferred[rat.Time]
+}
+
+// Penalize each event that is assigned to a time other than its preferred
+// times. Events without preferred times are never penalized.
+func preferredTimes(s *Solution) (penalty int) {
+	if s.inst.ext == nil {
+		return 0
+	}
+
+	for event := range s.rats {
+		if s.unpreferred(event) {
+			penalty++
+		}
+	}
+
+	return
+}
+
+// Compute the preferred times penalty of the moved event, which is the only
+// part of the penalty that a move can change.
+func preferredTimesAffected(s *Solution, event int, from, to Rat) int {
+	if s.inst.ext != nil && s.unpreferred(event) {
+		return 1
+	}
+
+	return 0
+}
+
+// Compute the room stability penalty of a single course, which is the number
+// of rooms its assigned sessions use beyond the first.
+func (s *Solution) coursePenalty(course int) int {
+	var rooms []int
+
+	for _, event := range s.inst.ext.courses[course] {
+		if rat := s.rats[event]; rat.Assigned() {
+			rooms = appendDistinct(rooms, rat.Room)
+		}
+	}
+
+	if len(rooms) > 1 {
+		return len(rooms) - 1
+	}
+
+	return 0
+}
+
+// Penalize each multi-session course once for each room its sessions use
+// beyond the first.
+func roomStability(s *Solution) (penalty int) {
+	if s.inst.ext == nil {
+		return 0
+	}
+
+	for course := range s.inst.ext.courses {
+		penalty += s.coursePenalty(course)
+	}
+
+	return
+}
+
+// Compute the room stability penalty of the courses the moved event is a
+// session of.
+func roomStabilityAffected(s *Solution, event int, from, to Rat) (penalty int) {
+	if s.inst.ext == nil {
+		return 0
+	}
+
+	for _, course := range s.inst.ext.inCourses[event] {
+		penalty += s.coursePenalty(course)
+	}
+
+	return
+}
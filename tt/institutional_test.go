@@ -0,0 +1,239 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// The extension of the tiny instance: the second period of each day is lunch,
+// a student should have at most two classes a day, event 3 prefers the last
+// period of the second day, and events 2 and 3 are sessions of a course.
+const tinyExtension = `# The institutional data of the tiny instance.
+lunch 1
+max_daily_classes 2
+prefer 3 5
+course 2 3
+`
+
+// The weights that enable only the institutional soft constraints.
+var institutionalWeights = SoftWeights{
+	"idle_gaps":         1,
+	"lunch_break":       1,
+	"max_daily_classes": 1,
+	"preferred_times":   1,
+	"room_stability":    1,
+}
+
+// Create the tiny instance with its extension and the given weights.
+func tinyExtendedInstance(t *testing.T, weights SoftWeights) *Instance {
+	inst := tinyInstance(t)
+
+	if err := inst.ReadExtension(strings.NewReader(tinyExtension)); err != nil {
+		t.Fatal(err)
+	} else if err = inst.SetSoftWeights(weights); err != nil {
+		t.Fatal(err)
+	}
+
+	return inst
+}
+
+func TestReadExtension(t *testing.T) {
+	inst := tinyExtendedInstance(t, institutionalWeights)
+
+	if got := inst.ext.lunch; len(got) != 1 || got[0] != 1 {
+		t.Errorf("got the lunch periods %v; want [1]", got)
+	}
+
+	if got := inst.ext.maxDaily; got != 2 {
+		t.Errorf("got at most %d classes a day; want 2", got)
+	}
+
+	if got := inst.ext.preferred[3]; len(got) != 1 || !got[5] || inst.ext.preferred[2] != nil {
+		t.Errorf("got the preferred times %v; want only time 5 for event 3", inst.ext.preferred)
+	}
+
+	if got := inst.ext.inCourses[3]; len(inst.ext.courses) != 1 || len(got) != 1 || got[0] != 0 || inst.ext.inCourses[0] != nil {
+		t.Errorf("got the courses %v; want one course of events 2 and 3", inst.ext.courses)
+	}
+}
+
+func TestReadExtensionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string // A substring of the error.
+	}{
+		{"unknown keyword", "lunch 1\nbreakfast 0\n", "line 2: unknown keyword breakfast"},
+		{"bad number", "lunch one\n", "line 1"},
+		{"no lunch periods", "lunch\n", "expected at least one period"},
+		{"bad lunch period", "lunch 3\n", "no such period 3"},
+		{"no maximum", "max_daily_classes\n", "expected a positive number of classes"},
+		{"zero maximum", "max_daily_classes 0\n", "expected a positive number of classes"},
+		{"no event", "prefer\n", "expected an event"},
+		{"bad preferring event", "prefer 4 0\n", "no such event 4"},
+		{"no preferred times", "prefer 0\n", "expected at least one time"},
+		{"bad preferred time", "prefer 0 6\n", "no such time 6"},
+		{"bad session", "course 0 -1\n", "no such event -1"},
+	}
+
+	for _, test := range tests {
+		inst := tinyInstance(t)
+
+		if err := inst.ReadExtension(strings.NewReader(test.source)); err == nil {
+			t.Errorf("%s: read the extension; want an error containing %q", test.name, test.want)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %q; want one containing %q", test.name, err, test.want)
+		} else if inst.ext != nil {
+			t.Errorf("%s: the extension was kept along with the error", test.name)
+		}
+	}
+
+	if err := tinyExamInstance(t).ReadExtension(strings.NewReader(tinyExtension)); err == nil {
+		t.Error("read an extension for an examination instance")
+	}
+}
+
+func TestInstitutionalValues(t *testing.T) {
+	// The penalties of these solutions to the tiny instance are worked out by
+	// hand. Times 1 and 4 are the lunch periods.
+	tests := []struct {
+		name      string
+		rats      []Rat
+		penalties map[string]int
+	}{
+		// Students 0 and 1 have class at lunch on the first day, student 0
+		// has three classes on the first day, event 3 is not at time 5, and
+		// the sessions of the course are in rooms 1 and 0.
+		{"feasible", []Rat{{0, 0}, {0, 1}, {1, 2}, {0, 2}}, map[string]int{
+			"idle_gaps":         0,
+			"lunch_break":       2,
+			"max_daily_classes": 1,
+			"preferred_times":   1,
+			"room_stability":    1,
+		}},
+
+		// Student 0 has a gap at time 1, and students 0 and 1 have class at
+		// lunch on the second day.
+		{"gap", []Rat{{0, 0}, {0, 4}, {1, 5}, {0, 2}}, map[string]int{
+			"idle_gaps":         1,
+			"lunch_break":       2,
+			"max_daily_classes": 0,
+			"preferred_times":   1,
+			"room_stability":    1,
+		}},
+
+		// Event 3 is at its preferred time and the sessions of the course are
+		// both in room 0. Student 0 has a gap at time 4 and nobody has class
+		// at lunch.
+		{"preferred", []Rat{{0, 0}, {0, 3}, {0, 2}, {0, 5}}, map[string]int{
+			"idle_gaps":         1,
+			"lunch_break":       0,
+			"max_daily_classes": 0,
+			"preferred_times":   0,
+			"room_stability":    0,
+		}},
+
+		{"empty", []Rat{badRat, badRat, badRat, badRat}, map[string]int{
+			"idle_gaps":         0,
+			"lunch_break":       0,
+			"max_daily_classes": 0,
+			"preferred_times":   0,
+			"room_stability":    0,
+		}},
+	}
+
+	inst := tinyExtendedInstance(t, institutionalWeights)
+	plain := tinyInstance(t)
+	if err := plain.SetSoftWeights(institutionalWeights); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		s := inst.SolutionFromRats(test.rats)
+
+		fitness := 0
+		for _, c := range inst.soft {
+			want := test.penalties[c.Name]
+			if got := s.softPenalty(c.SoftConstraint); got != want {
+				t.Errorf("%s: got a %s penalty of %d; want %d", test.name, c.Name, got, want)
+			}
+
+			fitness += want
+		}
+
+		if got := s.Fitness(); got != fitness {
+			t.Errorf("%s: got fitness %d; want %d", test.name, got, fitness)
+		}
+
+		// Without an extension, only idle gaps are penalized.
+		p := plain.SolutionFromRats(test.rats)
+		if got, want := p.Fitness(), test.penalties["idle_gaps"]; got != want {
+			t.Errorf("%s: got fitness %d without the extension; want %d", test.name, got, want)
+		}
+
+		p.Free()
+		s.Free()
+	}
+}
+
+func TestInstitutionalMoveValues(t *testing.T) {
+	weights := SoftWeights{}
+	for name, weight := range DefaultSoftWeights {
+		weights[name] = weight
+	}
+
+	for name := range institutionalWeights {
+		weights[name] = 2
+	}
+
+	inst := tinyExtendedInstance(t, weights)
+	rng := rand.New(rand.NewSource(1))
+
+	s := inst.NewSolution()
+	defer s.Free()
+
+	for event, domain := range inst.Domains {
+		s.Assign(event, domain[rng.Intn(len(domain))])
+	}
+
+	// The institutional constraints that span more than one student-day are
+	// kept up to date by their Affected functions, while the report scans the
+	// whole solution.
+	for i := 0; i < 2000; i++ {
+		event := rng.Intn(inst.NEvents())
+
+		var move Move
+		if rng.Intn(2) == 0 {
+			move = s.AssignMove(event, inst.Domains[event][rng.Intn(len(inst.Domains[event]))])
+		} else {
+			move = s.SwapMove(event, rng.Intn(inst.NEvents()))
+		}
+
+		if !s.CanApply(move) {
+			continue
+		}
+
+		want := s.MoveValue(move)
+		if s.Apply(move); s.Value() != want || s.Report().Value != want {
+			t.Fatalf("applying %v gave the value %s (%s by scanning); want %s", move, s.Value(), s.Report().Value, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// A reassignment of a single event from one room and time to another.
+type Reassignment struct {
+	Event int // The event being reassigned.
+	From  Rat // The room and time of the event before the reassignment.
+	To    Rat // The room and time of the event after the reassignment.
+}
+
+// A move from a solution to a neighbouring solution. A move is a sequence of
+// reassignments of distinct events that are applied in order.
+type Move []Reassignment
+
+// Create the move that undoes the given move.
+func (m Move) Reverse() (reverse Move) {
+	reverse = make(Move, len(m))
+
+	for i, r := range m {
+		reverse[len(m)-1-i] = Reassignment{r.Event, r.To, r.From}
+	}
+
+	return
+}
+
+// Create a move that assigns the event to the given room and time.
+func (s *Solution) AssignMove(event int, rat Rat) Move {
+	return Move{{event, s.rats[event], rat}}
+}
+
+// Create a move that moves the event to another room at the same time. If the
+// event is unassigned, the move is nil.
+func (s *Solution) RoomMove(event, room int) Move {
+	rat := s.rats[event]
+	if !rat.Assigned() {
+		return nil
+	}
+
+	return Move{{event, rat, Rat{room, rat.Time}}}
+}
+
+// Create a move that swaps the rooms and times of two events. If either event
+// is unassigned, the move is nil.
+func (s *Solution) SwapMove(first, second int) Move {
+	firstRat, secondRat := s.rats[first], s.rats[second]
+	if !firstRat.Assigned() || !secondRat.Assigned() || first == second {
+		return nil
+	}
+
+	return Move{{first, firstRat, secondRat}, {second, secondRat, firstRat}}
+}
+
+// Create a Kempe chain interchange between the event's time and the given
+// time. The chain is the set of events at either time that are connected to
+// the event by students in common; every event in the chain keeps its room and
+// moves to the other time. If the event is unassigned or already at the given
+// time, the move is nil.
+func (s *Solution) KempeMove(event, time int) (move Move) {
+	rat := s.rats[event]
+	if !rat.Assigned() || rat.Time == time {
+		return nil
+	}
+
+	visited := map[int]bool{event: true}
+	queue := []int{event}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		from := s.rats[current]
+		to := Rat{from.Room, rat.Time}
+		if from.Time == rat.Time {
+			to.Time = time
+		}
+
+		move = append(move, Reassignment{current, from, to})
+
+		for _, other := range s.inst.events[current].exclude {
+			if otherRat := s.rats[other]; !visited[other] && otherRat.Assigned() {
+				if otherRat.Time == rat.Time || otherRat.Time == time {
+					visited[other] = true
+					queue = append(queue, other)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// Determine if a move can be applied to the solution, i.e., it is non-empty,
+// every event it reassigns is currently where the move expects it to be, and
+// every new room and time is in the domain of its event (or unassigned). The
+// domains are those of the instance, so moves respect any reduction of them.
+func (s *Solution) CanApply(m Move) bool {
+	if len(m) == 0 {
+		return false
+	}
+
+	for _, r := range m {
+		if s.rats[r.Event] != r.From {
+			return false
+		} else if r.To.Assigned() && !s.inst.inDomain(r.Event, r.To) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply a move to the solution.
+func (s *Solution) Apply(m Move) {
+	for _, r := range m {
+		if r.To.Assigned() {
+			s.Assign(r.Event, r.To)
+		} else {
+			s.Unassign(r.Event)
+		}
+	}
+}
+
+// Determine the value the solution would have if the move were applied. The
+// solution is left as it was.
+func (s *Solution) MoveValue(m Move) (value Value) {
+	if len(m) == 1 && m[0].To.Assigned() {
+		return s.AssignValue(m[0].Event, m[0].To)
+	}
+
+	s.Apply(m)
+	value = s.value
+	s.Apply(m.Reverse())
+
+	return
+}
@@ -0,0 +1,201 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMoves(t *testing.T) {
+	inst := tinyInstance(t)
+
+	// The feasible solution of TestSolutionValues.
+	s := inst.SolutionFromRats([]Rat{{0, 0}, {0, 1}, {1, 2}, {0, 2}})
+	defer s.Free()
+
+	tests := []struct {
+		name string
+		move Move
+		want Move
+	}{
+		{"assign", s.AssignMove(2, Rat{1, 4}), Move{{2, Rat{1, 2}, Rat{1, 4}}}},
+		{"room", s.RoomMove(3, 1), Move{{3, Rat{0, 2}, Rat{1, 2}}}},
+		{"swap", s.SwapMove(2, 3), Move{{2, Rat{1, 2}, Rat{0, 2}}, {3, Rat{0, 2}, Rat{1, 2}}}},
+
+		// Events 0 and 1 share student 0, so moving event 0 to time 1 moves
+		// event 1 to time 0. Events 2 and 3 are at time 2, which is not in
+		// the chain.
+		{"kempe", s.KempeMove(0, 1), Move{{0, Rat{0, 0}, Rat{0, 1}}, {1, Rat{0, 1}, Rat{0, 0}}}},
+
+		// Event 1 shares students with events 0, 2, and 3, and events 0 and
+		// 3 share student 0, so the chain between times 1 and 2 takes in
+		// every event but event 0.
+		{"long kempe", s.KempeMove(1, 2), Move{{1, Rat{0, 1}, Rat{0, 2}}, {2, Rat{1, 2}, Rat{1, 1}}, {3, Rat{0, 2}, Rat{0, 1}}}},
+
+		{"swap with itself", s.SwapMove(1, 1), nil},
+		{"kempe to the same time", s.KempeMove(2, 2), nil},
+	}
+
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.move, test.want) {
+			t.Errorf("%s: got %v; want %v", test.name, test.move, test.want)
+		}
+	}
+
+	// Event 0 only fits in room 0, and moving an event that is somewhere else
+	// cannot be applied.
+	if s.CanApply(s.RoomMove(0, 1)) || s.CanApply(Move{{2, Rat{0, 0}, Rat{1, 3}}}) || s.CanApply(nil) {
+		t.Error("a move that cannot be applied can be")
+	}
+
+	// Moving event 3 into room 1 clashes with event 2.
+	if got := s.MoveValue(s.RoomMove(3, 1)); got.Violations != 1 {
+		t.Errorf("moving event 3 to room 1 gives the value %s; want 1 violation", got)
+	}
+}
+
+func TestMovesRespectDomains(t *testing.T) {
+	inst := tinyInstance(t)
+
+	// Remove the given room and time from the domain of the event, as
+	// preprocessing would.
+	remove := func(event int, rat Rat) {
+		var domain []Rat
+		for _, other := range inst.Domains[event] {
+			if other != rat {
+				domain = append(domain, other)
+			}
+		}
+
+		inst.Domains[event] = domain
+	}
+
+	remove(2, Rat{1, 4})
+	remove(3, Rat{1, 2})
+
+	s := inst.SolutionFromRats([]Rat{{0, 0}, {0, 1}, {1, 2}, {0, 2}})
+	defer s.Free()
+
+	tests := []struct {
+		name string
+		move Move
+	}{
+		{"assign", s.AssignMove(2, Rat{1, 4})},
+		{"room", s.RoomMove(3, 1)},
+		{"swap", s.SwapMove(2, 3)},
+		{"kempe", s.KempeMove(2, 4)},
+	}
+
+	for _, test := range tests {
+		if s.CanApply(test.move) {
+			t.Errorf("%s: %v can be applied, but leaves the reduced domains", test.name, test.move)
+		}
+	}
+
+	if move := s.AssignMove(2, Rat{1, 5}); !s.CanApply(move) {
+		t.Errorf("%v cannot be applied", move)
+	}
+}
+
+func TestMoveValues(t *testing.T) {
+	inst := tinyInstance(t)
+	rng := rand.New(rand.NewSource(1))
+
+	s := inst.NewSolution()
+	defer s.Free()
+
+	for event, domain := range inst.Domains {
+		s.Assign(event, domain[rng.Intn(len(domain))])
+	}
+
+	applied := 0
+	for i := 0; i < 2000; i++ {
+		event := rng.Intn(inst.NEvents())
+
+		var move Move
+		switch rng.Intn(4) {
+		case 0:
+			move = s.AssignMove(event, inst.Domains[event][rng.Intn(len(inst.Domains[event]))])
+
+		case 1:
+			move = s.RoomMove(event, rng.Intn(inst.nRooms))
+
+		case 2:
+			move = s.SwapMove(event, rng.Intn(inst.NEvents()))
+
+		case 3:
+			move = s.KempeMove(event, rng.Intn(inst.NTimes()))
+		}
+
+		if !s.CanApply(move) {
+			continue
+		}
+
+		before := s.Assignments()
+		beforeValue := s.Value()
+
+		// Determining the value of a move leaves the solution alone.
+		want := s.MoveValue(move)
+		if !reflect.DeepEqual(s.Assignments(), before) || s.Value() != beforeValue {
+			t.Fatalf("determining the value of %v changed the solution", move)
+		}
+
+		s.Apply(move)
+		applied++
+
+		// The report scans the whole solution.
+		if s.Value() != want || s.Report().Value != want {
+			t.Fatalf("applying %v gave the value %s (%s by scanning); want %s", move, s.Value(), s.Report().Value, want)
+		}
+
+		// Reversing a move restores the solution.
+		if rng.Intn(2) == 0 {
+			s.Apply(move.Reverse())
+
+			if !reflect.DeepEqual(s.Assignments(), before) || s.Value() != beforeValue {
+				t.Fatalf("reversing %v did not restore the solution", move)
+			}
+		}
+	}
+
+	if applied < 500 {
+		t.Errorf("only %d moves could be applied", applied)
+	}
+}
+
+func TestFindImprovement(t *testing.T) {
+	inst := tinyInstance(t)
+
+	// Events 0 and 1 clash in room 0 at time 2.
+	s := inst.SolutionFromRats([]Rat{{0, 2}, {0, 2}, {1, 3}, {1, 5}})
+	defer s.Free()
+
+	for i := 0; s.Violations() > 0; i++ {
+		move := s.FindImprovement(rand.New(rand.NewSource(int64(i))))
+		if move == nil {
+			t.Fatalf("found no improvement of %s", s.Value())
+		}
+
+		value := s.Value()
+		if s.Apply(move); !s.Value().Less(value) {
+			t.Fatalf("applying %v changed %s to %s", move, value, s.Value())
+		}
+	}
+}
@@ -17,6 +17,7 @@
 package tt
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"sync"
@@ -49,10 +50,39 @@ func readBool(r io.Reader) (b bool, err error) {
 	return
 }
 
-// Read a timetabling instance from the reader.
-func Parse(r io.Reader) (newInst *Instance, err error) {
+// Read a timetabling instance from the reader. The format of the instance,
+// which is one of the ITC2007 post-enrolment course, examination, or
+// curriculum-based course formats, is determined from its first line.
+// Post-enrolment instances have the standard week.
+func Parse(r io.Reader) (*Instance, error) {
+	return ParseWithWeek(r, StandardWeek)
+}
+
+// Read a timetabling instance from the reader as Parse does, except that a
+// post-enrolment instance has the given week. The week of a curriculum-based
+// instance is given in the instance and examination instances do not have
+// one, so the week is ignored for them.
+func ParseWithWeek(r io.Reader, week Week) (*Instance, error) {
+	br := bufio.NewReader(r)
+
+	if start, _ := br.Peek(len(examHeader)); string(start) == examHeader {
+		return parseExam(br)
+	} else if start, _ := br.Peek(len(cttHeader)); string(start) == cttHeader {
+		return parseCtt(br)
+	}
+
+	if week.Days < 1 || week.Periods < 1 {
+		return nil, fmt.Errorf("invalid week %s: expected at least one day and period", week)
+	}
+
+	return parsePostEnrolment(br, week)
+}
+
+// Read a post-enrolment course timetabling instance with the given week from
+// the reader.
+func parsePostEnrolment(r io.Reader, week Week) (newInst *Instance, err error) {
 	line := 1 // Line number for error reporting.
-	inst := &Instance{}
+	inst := &Instance{week: week}
 
 	inst.solnPool = sync.Pool{
 		New: func() interface{} {
@@ -72,6 +102,7 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 
 	line++
 
+	inst.nTimes = week.NTimes()
 	inst.rooms = make([]room, inst.nRooms)
 	inst.events = make([]event, inst.nEvents)
 
@@ -81,12 +112,9 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 
 	for event := range inst.events {
 		inst.events[event].id = event
+		inst.events[event].times = make([]bool, inst.nTimes)
 		inst.events[event].rooms = make(map[int]bool)
 		inst.events[event].features = make(map[int]bool)
-		inst.events[event].before = make(map[int]bool)
-		inst.events[event].after = make(map[int]bool)
-		inst.events[event].students = make(map[int]bool)
-		inst.events[event].exclude = make(map[int]bool)
 	}
 
 	for student := range events {
@@ -120,7 +148,7 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 
 			if attends {
 				events[student][event] = true
-				inst.events[event].students[student] = true
+				inst.events[event].students = append(inst.events[event].students, student)
 			}
 
 			line++
@@ -168,7 +196,7 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 	// There is one line for each event and time to determine if the event
 	// can be scheduled at that time.
 	for event := range inst.events {
-		for time := 0; time < NTimes; time++ {
+		for time := 0; time < inst.nTimes; time++ {
 			if inst.events[event].times[time], err = readBool(r); err != nil {
 				err = fmt.Errorf(formatError, line, err.Error())
 				return
@@ -189,24 +217,37 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 				return
 			}
 
+			// The relation is recorded on both events so that either one can
+			// find every ordering constraint it is involved in.
 			switch val {
 			case 1:
-				inst.events[second].before[first] = true
+				inst.events[second].before = append(inst.events[second].before, first)
+				inst.events[first].after = append(inst.events[first].after, second)
 
 			case 0:
 				break
 
 			case -1:
-				inst.events[second].after[first] = true
+				inst.events[second].after = append(inst.events[second].after, first)
+				inst.events[first].before = append(inst.events[first].before, second)
 
 			default:
 				err = fmt.Errorf(formatError, line, "expected 1, 0, or -1")
+				return
 			}
 
 			line++
 		}
 	}
 
+	// The size of the availability matrix depends on the week, so data left
+	// over means the week does not match the instance.
+	var extra string
+	if n, _ := fmt.Fscan(r, &extra); n > 0 {
+		err = fmt.Errorf(formatError, line, "unexpected data after the instance; does it have a "+week.String()+" week?")
+		return
+	}
+
 	// Process the room-event pairs to determine which inst.rooms can hold which
 	// events.
 	for event := range inst.events {
@@ -220,17 +261,19 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 	// Process the attends matrix to build exclusion lists (as two events that
 	// share a student cannot occur at the same time).
 	for event := range inst.events {
-		for student := range inst.events[event].students {
+		for _, student := range inst.events[event].students {
 			for other := range events[student] {
 				if event == other {
 					continue
 				}
 
-				inst.events[event].exclude[other] = true
+				inst.events[event].exclude = append(inst.events[event].exclude, other)
 			}
 		}
 	}
 
+	inst.indexEvents()
+
 	// Process the attends matrix to build exclusion lists (as two events that
 	inst.Domains = make([][]Rat, inst.nEvents)
 	for eventIndex := range inst.events {
@@ -243,8 +286,14 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 			}
 		}
 
+		// The rooms are visited in order (rather than by ranging over the map)
+		// so that the domains are the same every time the instance is parsed.
 		inst.Domains[eventIndex] = make([]Rat, 0, len(event.rooms)*nTimes)
-		for room := range event.rooms {
+		for room := 0; room < inst.nRooms; room++ {
+			if !event.rooms[room] {
+				continue
+			}
+
 			for time, ok := range event.times {
 				if ok {
 					inst.Domains[eventIndex] = append(inst.Domains[eventIndex], Rat{room, time})
@@ -253,20 +302,36 @@ func Parse(r io.Reader) (newInst *Instance, err error) {
 		}
 	}
 
+	if err = inst.SetSoftWeights(DefaultSoftWeights); err != nil {
+		return
+	}
+
 	newInst = inst
 	return
 }
 
-// Parse a solution from the given reader.
+// Parse a solution from the given reader. Each line holds the time and room of
+// an event, separated by a space for post-enrolment instances or a comma for
+// examination instances. Curriculum-based instances have their own format; see
+// parseCttSolution.
 func (inst *Instance) ParseSolution(r io.Reader) (s *Solution, err error) {
+	if inst.ctt != nil {
+		return inst.parseCttSolution(r)
+	}
+
 	s = nil
 	rats := make([]Rat, inst.NEvents())
 
 	for event := range rats {
-		if _, err = fmt.Fscanf(r, "%d %d\n", &rats[event].Time, &rats[event].Room); err != nil {
+		if _, err = fmt.Fscanf(r, inst.solutionFormat(), &rats[event].Time, &rats[event].Room); err != nil {
 			err = fmt.Errorf(formatError, event+1, err.Error())
 			return
 		}
+
+		if rat := rats[event]; rat.Assigned() && (rat.Time < 0 || rat.Time >= inst.nTimes || rat.Room < 0 || rat.Room >= inst.nRooms) {
+			err = fmt.Errorf(formatError, event+1, fmt.Sprintf("no such time %d and room %d", rat.Time, rat.Room))
+			return
+		}
 	}
 
 	s = inst.SolutionFromRats(rats)
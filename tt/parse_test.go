@@ -0,0 +1,192 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"strings"
+	"testing"
+)
+
+// The week of the tiny instance.
+var tinyWeek = Week{2, 3}
+
+// Create the lines of a tiny post-enrolment instance with a 2x3 week, which is
+// small enough that the values of its solutions can be worked out by hand:
+//  1. room 0 seats three students and has feature 0, and room 1 seats one
+//     student and has no features;
+//  2. event 0 is attended by students 0 and 2, event 1 by students 0 and 1,
+//     event 2 by student 1, and event 3 by student 0;
+//  3. no event requires a feature and every event can be at any time; and
+//  4. event 0 must be before event 2.
+//
+// Events 0 and 1 have two students, so they can only be in room 0.
+func tinyLines() []string {
+	lines := []string{
+		"4 2 1 3",
+
+		// The capacity of each room.
+		"3", "1",
+
+		// The events each student attends.
+		"1", "1", "0", "1",
+		"0", "1", "1", "0",
+		"1", "0", "0", "0",
+
+		// The features of each room.
+		"1", "0",
+
+		// The features each event requires.
+		"0", "0", "0", "0",
+	}
+
+	// The availability of each event at each time.
+	for i := 0; i < 4*tinyWeek.NTimes(); i++ {
+		lines = append(lines, "1")
+	}
+
+	// The precedence matrix.
+	for first := 0; first < 4; first++ {
+		for second := 0; second < 4; second++ {
+			switch {
+			case first == 0 && second == 2:
+				lines = append(lines, "1")
+
+			case first == 2 && second == 0:
+				lines = append(lines, "-1")
+
+			default:
+				lines = append(lines, "0")
+			}
+		}
+	}
+
+	return lines
+}
+
+// The index of the first line of the precedence matrix in tinyLines.
+const tinyPrecedence = 1 + 2 + 12 + 2 + 4 + 4*6
+
+// Join the lines of an instance.
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Parse the tiny instance.
+func tinyInstance(t testing.TB) *Instance {
+	inst, err := ParseWithWeek(strings.NewReader(joinLines(tinyLines())), tinyWeek)
+	if err != nil {
+		t.Fatalf("could not parse the tiny instance: %s", err)
+	}
+
+	return inst
+}
+
+func TestParseTiny(t *testing.T) {
+	inst := tinyInstance(t)
+
+	if inst.Format() != PostEnrolment {
+		t.Errorf("got format %s; want %s", inst.Format(), PostEnrolment)
+	}
+
+	if inst.NEvents() != 4 || inst.NTimes() != 6 || inst.Week() != tinyWeek {
+		t.Errorf("got %d events, %d times, and a %s week; want 4 events, 6 times, and a %s week", inst.NEvents(), inst.NTimes(), inst.Week(), tinyWeek)
+	}
+
+	// Events 0 and 1 only fit in room 0, and events 2 and 3 fit in either.
+	for event, want := range []int{6, 6, 12, 12} {
+		if got := len(inst.Domains[event]); got != want {
+			t.Errorf("event %d has %d values in its domain; want %d", event, got, want)
+		}
+	}
+
+	if got := inst.events[0].exclude; len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("event 0 excludes %v; want [1 3]", got)
+	}
+
+	if got := inst.events[0].after; len(got) != 1 || got[0] != 2 {
+		t.Errorf("event 0 is before %v; want [2]", got)
+	}
+
+	if got := inst.events[2].before; len(got) != 1 || got[0] != 0 {
+		t.Errorf("event 2 is after %v; want [0]", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	// Replace the line at the given index of the tiny instance.
+	replace := func(index int, line string) string {
+		lines := tinyLines()
+		lines[index] = line
+		return joinLines(lines)
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		week   Week
+		want   string // A substring of the error.
+	}{
+		{"empty", "", tinyWeek, "line 1"},
+		{"short header", replace(0, "4 2 1"), tinyWeek, "line 1"},
+		{"bad capacity", replace(1, "x"), tinyWeek, "line 2"},
+		{"bad attendance", replace(3, "2"), tinyWeek, "expected either 0 or 1"},
+		{"bad availability", replace(21, "-1"), tinyWeek, "line 22"},
+		{"bad precedence", replace(tinyPrecedence, "2"), tinyWeek, "expected 1, 0, or -1"},
+		{"truncated", joinLines(tinyLines()[:30]), tinyWeek, "line 31"},
+		{"trailing data", joinLines(append(tinyLines(), "0")), tinyWeek, "does it have a 2x3 week?"},
+		{"wrong week", joinLines(tinyLines()), StandardWeek, "invalid format"},
+		{"invalid week", joinLines(tinyLines()), Week{0, 3}, "invalid week 0x3"},
+		{"exam without periods", "[Exams:0]\n[Periods:0]\n", tinyWeek, "expected at least one period"},
+		{"curriculum-based without courses", "Name: x\n", tinyWeek, "unexpected end of instance"},
+	}
+
+	for _, test := range tests {
+		inst, err := ParseWithWeek(strings.NewReader(test.source), test.week)
+
+		if err == nil {
+			t.Errorf("%s: parsed the instance; want an error containing %q", test.name, test.want)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %q; want one containing %q", test.name, err, test.want)
+		} else if inst != nil {
+			t.Errorf("%s: got an instance along with the error", test.name)
+		}
+	}
+}
+
+func TestParseSolutionErrors(t *testing.T) {
+	inst := tinyInstance(t)
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"truncated", "0 0\n1 0\n", "line 3"},
+		{"no such time", "0 0\n6 0\n2 1\n3 1\n", "no such time 6 and room 0"},
+		{"no such room", "0 0\n1 0\n2 2\n3 1\n", "no such time 2 and room 2"},
+		{"not a number", "0 0\nx 0\n2 1\n3 1\n", "line 2"},
+	}
+
+	for _, test := range tests {
+		if _, err := inst.ParseSolution(strings.NewReader(test.source)); err == nil {
+			t.Errorf("%s: parsed the solution; want an error containing %q", test.name, test.want)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %q; want one containing %q", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The first line of a curriculum-based instance starts with this.
+const cttHeader = "Name:"
+
+// A non-blank line of a curriculum-based instance, split into its
+// whitespace-separated fields.
+type cttLine struct {
+	number int      // The line number, for error reporting.
+	fields []string // The fields.
+}
+
+// Read the field at the given index as a non-negative integer.
+func (l cttLine) int(index int) (n int, err error) {
+	if n, err = strconv.Atoi(l.fields[index]); err != nil {
+		err = fmt.Errorf(formatError, l.number, err.Error())
+	} else if n < 0 {
+		err = fmt.Errorf(formatError, l.number, fmt.Sprintf("expected a non-negative number; got %d", n))
+	}
+
+	return
+}
+
+// Read the non-blank lines of a curriculum-based instance.
+func readCttLines(r io.Reader) (lines []cttLine, err error) {
+	scanner := bufio.NewScanner(r)
+
+	for number := 1; scanner.Scan(); number++ {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			lines = append(lines, cttLine{number, fields})
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// Read a curriculum-based course timetabling instance (in the format of the
+// ITC2007 curriculum-based track) from the reader.
+func parseCtt(r io.Reader) (newInst *Instance, err error) {
+	lines, err := readCttLines(r)
+	if err != nil {
+		return
+	}
+
+	inst := &Instance{ctt: &ctt{}}
+	inst.solnPool = sync.Pool{
+		New: func() interface{} {
+			return inst.allocSolution()
+		},
+	}
+
+	// Expect a line with the given number of fields, starting with the given
+	// field if it is not empty.
+	expect := func(first string, nFields int) (line cttLine, err error) {
+		if len(lines) == 0 {
+			return line, fmt.Errorf("unexpected end of instance; expected %s", first)
+		}
+
+		line = lines[0]
+		lines = lines[1:]
+
+		if first != "" && line.fields[0] != first {
+			err = fmt.Errorf(formatError, line.number, "expected "+first)
+		} else if len(line.fields) != nFields {
+			err = fmt.Errorf(formatError, line.number, fmt.Sprintf("expected %d fields; got %d", nFields, len(line.fields)))
+		}
+
+		return
+	}
+
+	// The header.
+	var line cttLine
+	if line, err = expect("Name:", 2); err != nil {
+		return
+	}
+
+	inst.ctt.name = line.fields[1]
+
+	var nCourses, nCurricula, nConstraints int
+	for _, header := range []struct {
+		name  string
+		value *int
+	}{
+		{"Courses:", &nCourses},
+		{"Rooms:", &inst.nRooms},
+		{"Days:", &inst.week.Days},
+		{"Periods_per_day:", &inst.week.Periods},
+		{"Curricula:", &nCurricula},
+		{"Constraints:", &nConstraints},
+	} {
+		if line, err = expect(header.name, 2); err != nil {
+			return
+		} else if *header.value, err = line.int(1); err != nil {
+			return
+		}
+	}
+
+	if inst.nTimes = inst.week.NTimes(); inst.nTimes == 0 {
+		return nil, fmt.Errorf(formatError, line.number, "expected at least one day and period")
+	}
+
+	// The courses. Each course has a line of the form
+	//   <course> <teacher> <lectures> <minimum working days> <students>
+	if _, err = expect("COURSES:", 1); err != nil {
+		return
+	}
+
+	courseIndex := make(map[string]int)
+	inst.ctt.courses = make([]course, nCourses)
+
+	for c := range inst.ctt.courses {
+		course := &inst.ctt.courses[c]
+		var nLectures int
+
+		if line, err = expect("", 5); err != nil {
+			return
+		} else if nLectures, err = line.int(2); err != nil {
+			return
+		} else if course.minDays, err = line.int(3); err != nil {
+			return
+		} else if course.students, err = line.int(4); err != nil {
+			return
+		}
+
+		course.name = line.fields[0]
+		course.teacher = line.fields[1]
+
+		if _, ok := courseIndex[course.name]; ok {
+			return nil, fmt.Errorf(formatError, line.number, "duplicate course "+course.name)
+		}
+		courseIndex[course.name] = c
+
+		for i := 0; i < nLectures; i++ {
+			course.lectures = append(course.lectures, inst.nEvents)
+			inst.nEvents++
+		}
+
+		// A course without any lectures assigned is short of all of its
+		// minimum working days.
+		inst.empty.Fitness += minWorkingDaysWeight * course.minDays
+	}
+
+	// The rooms. Each room has a line of the form
+	//   <room> <capacity>
+	if _, err = expect("ROOMS:", 1); err != nil {
+		return
+	}
+
+	inst.rooms = make([]room, inst.nRooms)
+	inst.ctt.roomNames = make([]string, inst.nRooms)
+
+	for roomIndex := range inst.rooms {
+		if line, err = expect("", 2); err != nil {
+			return
+		} else if inst.rooms[roomIndex].capacity, err = line.int(1); err != nil {
+			return
+		}
+
+		inst.rooms[roomIndex].features = make(map[int]bool)
+		inst.ctt.roomNames[roomIndex] = line.fields[0]
+	}
+
+	// Find the course with the given name.
+	findCourse := func(line cttLine, name string) (c int, err error) {
+		c, ok := courseIndex[name]
+		if !ok {
+			err = fmt.Errorf(formatError, line.number, "no such course "+name)
+		}
+
+		return
+	}
+
+	// The curricula. Each curriculum has a line of the form
+	//   <curriculum> <number of courses> <course>...
+	if _, err = expect("CURRICULA:", 1); err != nil {
+		return
+	}
+
+	inst.nStudents = nCurricula
+	inst.ctt.curricula = make([]curriculum, nCurricula)
+
+	for curr := range inst.ctt.curricula {
+		var n int
+
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("unexpected end of instance; expected a curriculum")
+		} else if line = lines[0]; len(line.fields) < 2 {
+			return nil, fmt.Errorf(formatError, line.number, "expected a curriculum")
+		} else if n, err = line.int(1); err != nil {
+			return
+		} else if line, err = expect("", 2+n); err != nil {
+			return
+		}
+
+		inst.ctt.curricula[curr].name = line.fields[0]
+
+		for _, name := range line.fields[2:] {
+			var c int
+			if c, err = findCourse(line, name); err != nil {
+				return
+			}
+
+			inst.ctt.curricula[curr].courses = append(inst.ctt.curricula[curr].courses, c)
+		}
+	}
+
+	inst.events = make([]event, inst.nEvents)
+	for c := range inst.ctt.courses {
+		for _, lecture := range inst.ctt.courses[c].lectures {
+			e := &inst.events[lecture]
+
+			e.id = lecture
+			e.course = c
+			e.times = make([]bool, inst.nTimes)
+			e.features = make(map[int]bool)
+			e.rooms = make(map[int]bool)
+
+			for time := range e.times {
+				e.times[time] = true
+			}
+
+			// The capacity of a room is a soft constraint, so every room can
+			// hold every lecture.
+			for room := range inst.rooms {
+				e.rooms[room] = true
+			}
+		}
+	}
+
+	// The unavailability constraints. Each constraint has a line of the form
+	//   <course> <day> <period>
+	if _, err = expect("UNAVAILABILITY_CONSTRAINTS:", 1); err != nil {
+		return
+	}
+
+	for i := 0; i < nConstraints; i++ {
+		var c, day, period int
+
+		if line, err = expect("", 3); err != nil {
+			return
+		} else if c, err = findCourse(line, line.fields[0]); err != nil {
+			return
+		} else if day, err = line.int(1); err != nil {
+			return
+		} else if period, err = line.int(2); err != nil {
+			return
+		} else if day >= inst.week.Days || period >= inst.week.Periods {
+			return nil, fmt.Errorf(formatError, line.number, "no such day and period")
+		}
+
+		for _, lecture := range inst.ctt.courses[c].lectures {
+			inst.events[lecture].times[day*inst.week.Periods+period] = false
+		}
+	}
+
+	if _, err = expect("END.", 1); err != nil {
+		return
+	}
+
+	// Courses conflict if they are the same course, have the same teacher, or
+	// are in the same curriculum. The lectures of conflicting courses cannot be
+	// at the same time.
+	conflicts := make([]map[int]bool, nCourses)
+	for c := range conflicts {
+		conflicts[c] = map[int]bool{c: true}
+
+		for other := range inst.ctt.courses {
+			if inst.ctt.courses[other].teacher == inst.ctt.courses[c].teacher {
+				conflicts[c][other] = true
+			}
+		}
+	}
+
+	for curr, curriculum := range inst.ctt.curricula {
+		for _, c := range curriculum.courses {
+			for _, other := range curriculum.courses {
+				conflicts[c][other] = true
+			}
+
+			for _, lecture := range inst.ctt.courses[c].lectures {
+				inst.events[lecture].students = append(inst.events[lecture].students, curr)
+			}
+		}
+	}
+
+	for c := range conflicts {
+		for other := range conflicts[c] {
+			for _, lecture := range inst.ctt.courses[c].lectures {
+				for _, otherLecture := range inst.ctt.courses[other].lectures {
+					if lecture != otherLecture {
+						inst.events[lecture].exclude = append(inst.events[lecture].exclude, otherLecture)
+					}
+				}
+			}
+		}
+	}
+
+	inst.indexEvents()
+
+	inst.Domains = make([][]Rat, inst.nEvents)
+	for lecture := range inst.events {
+		for room := 0; room < inst.nRooms; room++ {
+			for time, ok := range inst.events[lecture].times {
+				if ok {
+					inst.Domains[lecture] = append(inst.Domains[lecture], Rat{room, time})
+				}
+			}
+		}
+	}
+
+	newInst = inst
+	return
+}
+
+// Parse a solution to a curriculum-based instance from the given reader. Each
+// line assigns one of a course's lectures with its course, room, day, and
+// period, separated by spaces. Lectures without a line are left unassigned.
+func (inst *Instance) parseCttSolution(r io.Reader) (s *Solution, err error) {
+	lines, err := readCttLines(r)
+	if err != nil {
+		return
+	}
+
+	courseIndex := make(map[string]int)
+	for c, course := range inst.ctt.courses {
+		courseIndex[course.name] = c
+	}
+
+	roomIndex := make(map[string]int)
+	for room, name := range inst.ctt.roomNames {
+		roomIndex[name] = room
+	}
+
+	rats := make([]Rat, inst.nEvents)
+	for lecture := range rats {
+		rats[lecture] = badRat
+	}
+
+	// The number of each course's lectures that have been assigned.
+	assigned := make([]int, len(inst.ctt.courses))
+
+	for _, line := range lines {
+		var day, period int
+
+		if len(line.fields) != 4 {
+			return nil, fmt.Errorf(formatError, line.number, "expected course, room, day, and period")
+		}
+
+		c, ok := courseIndex[line.fields[0]]
+		if !ok {
+			return nil, fmt.Errorf(formatError, line.number, "no such course "+line.fields[0])
+		}
+
+		room, ok := roomIndex[line.fields[1]]
+		if !ok {
+			return nil, fmt.Errorf(formatError, line.number, "no such room "+line.fields[1])
+		}
+
+		if day, err = line.int(2); err != nil {
+			return
+		} else if period, err = line.int(3); err != nil {
+			return
+		} else if day >= inst.week.Days || period >= inst.week.Periods {
+			return nil, fmt.Errorf(formatError, line.number, "no such day and period")
+		} else if assigned[c] == len(inst.ctt.courses[c].lectures) {
+			return nil, fmt.Errorf(formatError, line.number, "too many lectures of "+line.fields[0])
+		}
+
+		rats[inst.ctt.courses[c].lectures[assigned[c]]] = Rat{room, day*inst.week.Periods + period}
+		assigned[c]++
+	}
+
+	return inst.SolutionFromRats(rats), nil
+}
+
+// Write a solution to a curriculum-based instance to the given writer in the
+// format read by parseCttSolution.
+func (s *Solution) writeCtt(w io.Writer) {
+	c := s.inst.ctt
+
+	for lecture, rat := range s.rats {
+		if rat.Assigned() {
+			fmt.Fprintf(w, "%s %s %d %d\n", c.courses[s.inst.events[lecture].course].name, c.roomNames[rat.Room], s.inst.week.day(rat.Time), rat.Time%s.inst.week.Periods)
+		}
+	}
+}
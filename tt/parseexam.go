@@ -0,0 +1,389 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The first line of an examination instance starts with this.
+const examHeader = "[Exams:"
+
+// A non-blank line of an examination instance, split into its
+// comma-separated fields.
+type examLine struct {
+	number int      // The line number, for error reporting.
+	fields []string // The fields, with surrounding whitespace removed.
+}
+
+// Determine if the line is a section header, e.g., "[Rooms:7]".
+func (l examLine) isHeader() bool {
+	return strings.HasPrefix(l.fields[0], "[")
+}
+
+// Read the field at the given index as an integer.
+func (l examLine) int(index int) (n int, err error) {
+	if index >= len(l.fields) {
+		return 0, fmt.Errorf(formatError, l.number, fmt.Sprintf("expected at least %d fields", index+1))
+	}
+
+	if n, err = strconv.Atoi(l.fields[index]); err != nil {
+		err = fmt.Errorf(formatError, l.number, err.Error())
+	}
+
+	return
+}
+
+// Read the non-blank lines of an examination instance.
+func readExamLines(r io.Reader) (lines []examLine, err error) {
+	scanner := bufio.NewScanner(r)
+
+	// An exam's line lists every student who sits it, so lines can be long.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		fields := strings.Split(text, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		lines = append(lines, examLine{number, fields})
+	}
+
+	return lines, scanner.Err()
+}
+
+// Read the header of the named section from the start of lines. The count is
+// the number given in the header (e.g., 7 for "[Rooms:7]") or -1 if it has
+// none.
+func readExamSection(lines []examLine, name string) (count int, err error) {
+	if len(lines) == 0 {
+		return 0, fmt.Errorf("missing [%s] section", name)
+	}
+
+	header := strings.Join(lines[0].fields, ",")
+	if header == "["+name+"]" {
+		return -1, nil
+	}
+
+	if !strings.HasPrefix(header, "["+name+":") || !strings.HasSuffix(header, "]") {
+		return 0, fmt.Errorf(formatError, lines[0].number, "expected ["+name+"] section")
+	}
+
+	value := strings.TrimSpace(header[len(name)+2 : len(header)-1])
+	if count, err = strconv.Atoi(value); err != nil || count < 0 {
+		return 0, fmt.Errorf(formatError, lines[0].number, "invalid section size "+value)
+	}
+
+	return
+}
+
+// Read an examination timetabling instance (in the format of the ITC2007
+// examination track) from the reader.
+func parseExam(r io.Reader) (newInst *Instance, err error) {
+	lines, err := readExamLines(r)
+	if err != nil {
+		return
+	}
+
+	inst := &Instance{exam: &exam{}}
+	inst.solnPool = sync.Pool{
+		New: func() interface{} {
+			return inst.allocSolution()
+		},
+	}
+
+	// The exams.
+	if inst.nEvents, err = readExamSection(lines, "Exams"); err != nil {
+		return
+	} else if inst.nEvents < 0 || len(lines) < 1+inst.nEvents {
+		return nil, fmt.Errorf(formatError, lines[0].number, "expected the number of exams")
+	}
+
+	lines = lines[1:]
+	inst.events = make([]event, inst.nEvents)
+
+	// Students are identified by arbitrary numbers in the instance, so they
+	// are numbered in the order they first appear.
+	studentIndex := make(map[int]int)
+	events := make([]map[int]bool, 0)
+
+	for eventIndex := range inst.events {
+		e := &inst.events[eventIndex]
+		line := lines[eventIndex]
+
+		e.id = eventIndex
+		e.features = make(map[int]bool)
+		e.rooms = make(map[int]bool)
+
+		if e.duration, err = line.int(0); err != nil {
+			return
+		}
+
+		for field := 1; field < len(line.fields); field++ {
+			var id int
+			if id, err = line.int(field); err != nil {
+				return
+			}
+
+			student, ok := studentIndex[id]
+			if !ok {
+				student = len(studentIndex)
+				studentIndex[id] = student
+				events = append(events, make(map[int]bool))
+			}
+
+			// A student listed twice still only sits the exam once.
+			if !events[student][eventIndex] {
+				e.students = append(e.students, student)
+				events[student][eventIndex] = true
+			}
+		}
+	}
+
+	lines = lines[inst.nEvents:]
+	inst.nStudents = len(studentIndex)
+
+	// The periods.
+	if inst.nTimes, err = readExamSection(lines, "Periods"); err != nil {
+		return
+	} else if inst.nTimes < 1 || len(lines) < 1+inst.nTimes {
+		return nil, fmt.Errorf(formatError, lines[0].number, "expected at least one period")
+	}
+
+	lines = lines[1:]
+	inst.exam.periods = make([]period, inst.nTimes)
+
+	for time := range inst.exam.periods {
+		p := &inst.exam.periods[time]
+		line := lines[time]
+
+		if len(line.fields) != 4 {
+			return nil, fmt.Errorf(formatError, line.number, "expected date, time, duration, and penalty")
+		}
+
+		p.date = line.fields[0]
+		p.start = line.fields[1]
+
+		if p.duration, err = line.int(2); err != nil {
+			return
+		} else if p.penalty, err = line.int(3); err != nil {
+			return
+		}
+
+		if time > 0 {
+			p.day = inst.exam.periods[time-1].day
+			if p.date != inst.exam.periods[time-1].date {
+				p.day++
+			}
+		}
+	}
+
+	lines = lines[inst.nTimes:]
+
+	// The rooms.
+	if inst.nRooms, err = readExamSection(lines, "Rooms"); err != nil {
+		return
+	} else if inst.nRooms < 0 || len(lines) < 1+inst.nRooms {
+		return nil, fmt.Errorf(formatError, lines[0].number, "expected the number of rooms")
+	}
+
+	lines = lines[1:]
+	inst.rooms = make([]room, inst.nRooms)
+
+	for roomIndex := range inst.rooms {
+		line := lines[roomIndex]
+		inst.rooms[roomIndex].features = make(map[int]bool)
+
+		if inst.rooms[roomIndex].capacity, err = line.int(0); err != nil {
+			return
+		} else if inst.rooms[roomIndex].penalty, err = line.int(1); err != nil {
+			return
+		}
+	}
+
+	lines = lines[inst.nRooms:]
+
+	// An exam number read from a constraint.
+	readExam := func(line examLine, index int) (exam int, err error) {
+		if exam, err = line.int(index); err == nil && (exam < 0 || exam >= inst.nEvents) {
+			err = fmt.Errorf(formatError, line.number, fmt.Sprintf("no such exam %d", exam))
+		}
+
+		return
+	}
+
+	// The period hard constraints.
+	if _, err = readExamSection(lines, "PeriodHardConstraints"); err != nil {
+		return
+	}
+
+	for lines = lines[1:]; len(lines) > 0 && !lines[0].isHeader(); lines = lines[1:] {
+		line := lines[0]
+		var first, second int
+
+		if len(line.fields) != 3 {
+			return nil, fmt.Errorf(formatError, line.number, "expected exam, constraint, exam")
+		} else if first, err = readExam(line, 0); err != nil {
+			return
+		} else if second, err = readExam(line, 2); err != nil {
+			return
+		}
+
+		switch line.fields[1] {
+		case "AFTER":
+			inst.events[first].before = append(inst.events[first].before, second)
+			inst.events[second].after = append(inst.events[second].after, first)
+
+		case "EXAM_COINCIDENCE":
+			inst.events[first].coincident = append(inst.events[first].coincident, second)
+			inst.events[second].coincident = append(inst.events[second].coincident, first)
+
+		case "EXCLUSION":
+			inst.events[first].separate = append(inst.events[first].separate, second)
+			inst.events[second].separate = append(inst.events[second].separate, first)
+
+		default:
+			return nil, fmt.Errorf(formatError, line.number, "unknown period constraint "+line.fields[1])
+		}
+	}
+
+	// The room hard constraints.
+	if _, err = readExamSection(lines, "RoomHardConstraints"); err != nil {
+		return
+	}
+
+	for lines = lines[1:]; len(lines) > 0 && !lines[0].isHeader(); lines = lines[1:] {
+		line := lines[0]
+		var exam int
+
+		if len(line.fields) != 2 || line.fields[1] != "ROOM_EXCLUSIVE" {
+			return nil, fmt.Errorf(formatError, line.number, "expected exam, ROOM_EXCLUSIVE")
+		} else if exam, err = readExam(line, 0); err != nil {
+			return
+		}
+
+		inst.events[exam].exclusive = true
+	}
+
+	// The institutional weightings.
+	if _, err = readExamSection(lines, "InstitutionalWeightings"); err != nil {
+		return
+	}
+
+	weights := &inst.exam.weights
+	for lines = lines[1:]; len(lines) > 0; lines = lines[1:] {
+		line := lines[0]
+
+		switch line.fields[0] {
+		case "TWOINAROW":
+			weights.twoInARow, err = line.int(1)
+
+		case "TWOINADAY":
+			weights.twoInADay, err = line.int(1)
+
+		case "PERIODSPREAD":
+			weights.periodSpread, err = line.int(1)
+
+		case "NONMIXEDDURATIONS":
+			weights.nonMixedDurations, err = line.int(1)
+
+		case "FRONTLOAD":
+			if weights.frontLoadExams, err = line.int(1); err != nil {
+				return
+			} else if weights.frontLoadPeriods, err = line.int(2); err != nil {
+				return
+			}
+
+			weights.frontLoad, err = line.int(3)
+
+		default:
+			err = fmt.Errorf(formatError, line.number, "unknown weighting "+line.fields[0])
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	// The largest exams are the ones subject to the front load penalty. Ties
+	// are broken by the order of the exams.
+	largest := make([]int, inst.nEvents)
+	for exam := range largest {
+		largest[exam] = exam
+	}
+
+	sort.SliceStable(largest, func(i, j int) bool {
+		return len(inst.events[largest[i]].students) > len(inst.events[largest[j]].students)
+	})
+
+	for i := 0; i < weights.frontLoadExams && i < inst.nEvents; i++ {
+		inst.events[largest[i]].large = true
+	}
+
+	// Exams that share a student cannot be in the same period.
+	for exam := range inst.events {
+		for _, student := range inst.events[exam].students {
+			for other := range events[student] {
+				if other != exam {
+					inst.events[exam].exclude = append(inst.events[exam].exclude, other)
+				}
+			}
+		}
+	}
+
+	inst.indexEvents()
+
+	// An exam can be in any room that seats all of its students and any period
+	// that is long enough.
+	inst.Domains = make([][]Rat, inst.nEvents)
+	for exam := range inst.events {
+		e := &inst.events[exam]
+		e.times = make([]bool, inst.nTimes)
+
+		for time, p := range inst.exam.periods {
+			e.times[time] = p.duration >= e.duration
+		}
+
+		for room := range inst.rooms {
+			if inst.rooms[room].canHost(e) {
+				e.rooms[room] = true
+
+				for time := range inst.exam.periods {
+					if e.times[time] {
+						inst.Domains[exam] = append(inst.Domains[exam], Rat{room, time})
+					}
+				}
+			}
+		}
+	}
+
+	newInst = inst
+	return
+}
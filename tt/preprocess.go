@@ -0,0 +1,429 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"fmt"
+)
+
+// The kind of proof that an instance has no feasible solution.
+type InfeasibilityKind string
+
+const (
+	EmptyDomain     InfeasibilityKind = "empty_domain"     // An event has no room and time it can be given.
+	PrecedenceCycle InfeasibilityKind = "precedence_cycle" // Events must each happen before the next, in a cycle.
+	PrecedenceChain InfeasibilityKind = "precedence_chain" // A chain of ordered events is longer than the times they can be at.
+	LargeClique     InfeasibilityKind = "clique"           // More events must be at different times than there are times.
+	BusyStudent     InfeasibilityKind = "student"          // A student attends more events than there are times.
+	RoomOverload    InfeasibilityKind = "rooms"            // The events that can only be held in some rooms do not fit in them.
+)
+
+// A proof that an instance has no feasible solution.
+type Infeasibility struct {
+	Kind    InfeasibilityKind `json:"kind"`    // The kind of proof.
+	Events  []int             `json:"events"`  // The events involved, in the order given by the message.
+	Message string            `json:"message"` // A description of the proof.
+}
+
+func (inf Infeasibility) String() string {
+	return inf.Message
+}
+
+// The error for an instance that has no feasible solution.
+type InfeasibleError struct {
+	Reasons []Infeasibility // Every proof found.
+}
+
+func (err *InfeasibleError) Error() string {
+	msg := "the instance has no feasible solution: " + err.Reasons[0].Message
+
+	if len(err.Reasons) > 1 {
+		msg += fmt.Sprintf(" (and %d more reasons)", len(err.Reasons)-1)
+	}
+
+	return msg
+}
+
+// Reduce the domains of the instance and check it for infeasibility. The
+// number of rooms and times removed from the domains is returned, and if the
+// instance has no feasible solution, the error is an *InfeasibleError. This
+// must be done before any solutions are created.
+func (inst *Instance) Preprocess() (removed int, err error) {
+	if reasons := inst.Diagnose(); len(reasons) > 0 {
+		return 0, &InfeasibleError{reasons}
+	}
+
+	removed = inst.ReduceDomains()
+
+	// Removing values can empty domains, which proves infeasibility as well.
+	if reasons := inst.emptyDomains(); len(reasons) > 0 {
+		return removed, &InfeasibleError{reasons}
+	}
+
+	return removed, nil
+}
+
+// Find proofs that the instance has no feasible solution. Finding none does
+// not mean that the instance has a feasible solution.
+func (inst *Instance) Diagnose() (reasons []Infeasibility) {
+	reasons = append(reasons, inst.emptyDomains()...)
+
+	// The times each event can be at.
+	times := make([][]bool, inst.nEvents)
+	for event, domain := range inst.Domains {
+		times[event] = make([]bool, inst.nTimes)
+		for _, rat := range domain {
+			times[event][rat.Time] = true
+		}
+	}
+
+	// Count the times at least one of the events can be at.
+	countTimes := func(events []int) (n int) {
+		for time := 0; time < inst.nTimes; time++ {
+			for _, event := range events {
+				if times[event][time] {
+					n++
+					break
+				}
+			}
+		}
+
+		return
+	}
+
+	chains, cyclic := inst.precedenceChains()
+	if len(cyclic) > 0 {
+		cycle := inst.precedenceCycle(cyclic)
+		reasons = append(reasons, Infeasibility{
+			Kind:    PrecedenceCycle,
+			Events:  cycle,
+			Message: fmt.Sprintf("events %v must each happen before the next, and the last before the first", cycle),
+		})
+	} else if chain := longestChain(inst, chains); len(chain) > countTimes(chain) {
+		reasons = append(reasons, Infeasibility{
+			Kind:    PrecedenceChain,
+			Events:  chain,
+			Message: fmt.Sprintf("events %v must each happen before the next, but there are only %d times they can be at", chain, countTimes(chain)),
+		})
+	}
+
+	if clique := largestClique(inst.conflicts()); len(clique) > countTimes(clique) {
+		reasons = append(reasons, Infeasibility{
+			Kind:    LargeClique,
+			Events:  clique,
+			Message: fmt.Sprintf("the %d events %v must all be at different times, but there are only %d times they can be at", len(clique), clique, countTimes(clique)),
+		})
+	}
+
+	who := "student"
+	if inst.ctt != nil {
+		who = "curriculum"
+	}
+
+	for student, events := range inst.studentEvents {
+		if nTimes := countTimes(events); len(events) > nTimes {
+			reasons = append(reasons, Infeasibility{
+				Kind:    BusyStudent,
+				Events:  events,
+				Message: fmt.Sprintf("%s %d has %d events, but there are only %d times they can be at", who, student, len(events), nTimes),
+			})
+		}
+	}
+
+	if _, tightest := inst.roomLoads(); tightest.Load > tightest.Capacity {
+		unit := "events"
+		if inst.exam != nil {
+			unit = "students"
+		}
+
+		reasons = append(reasons, Infeasibility{
+			Kind:    RoomOverload,
+			Events:  inst.eventsOnlyIn(tightest.Rooms),
+			Message: fmt.Sprintf("%d events can only be held in rooms %v, which have room for %d %s over the timetable, but the events have %d", tightest.NEvents, tightest.Rooms, tightest.Capacity, unit, tightest.Load),
+		})
+	}
+
+	return
+}
+
+// Find the events with empty domains and explain why each one is empty.
+func (inst *Instance) emptyDomains() (reasons []Infeasibility) {
+	for event, domain := range inst.Domains {
+		if len(domain) > 0 {
+			continue
+		}
+
+		e := &inst.events[event]
+		msg := fmt.Sprintf("event %d has no room and time left that any feasible timetable could give it", event)
+
+		if len(e.rooms) == 0 {
+			msg = fmt.Sprintf("event %d fits in no room: it has %d students and requires %d features", event, len(e.students), len(e.features))
+		} else {
+			available := false
+			for _, ok := range e.times {
+				available = available || ok
+			}
+
+			if !available {
+				msg = fmt.Sprintf("event %d cannot be at any time", event)
+			}
+		}
+
+		reasons = append(reasons, Infeasibility{
+			Kind:    EmptyDomain,
+			Events:  []int{event},
+			Message: msg,
+		})
+	}
+
+	return
+}
+
+// Find a cycle of ordered events among the given events, which are those on or
+// after a cycle. The cycle is given in order, starting with its smallest event.
+func (inst *Instance) precedenceCycle(cyclic []int) []int {
+	onCycle := make(map[int]bool)
+	for _, event := range cyclic {
+		onCycle[event] = true
+	}
+
+	// Every event on or after a cycle has an earlier event that is too, so
+	// walking backwards from any of them must revisit an event.
+	position := make(map[int]int)
+	var path []int
+
+	event := cyclic[0]
+	for {
+		if start, ok := position[event]; ok {
+			path = path[start:]
+			break
+		}
+
+		position[event] = len(path)
+		path = append(path, event)
+
+		for _, before := range inst.events[event].before {
+			if onCycle[before] {
+				event = before
+				break
+			}
+		}
+	}
+
+	// The path was walked backwards, so reverse it, and rotate it to start
+	// with its smallest event.
+	cycle := make([]int, len(path))
+	smallest := 0
+	for i := range path {
+		cycle[i] = path[len(path)-1-i]
+		if cycle[i] < cycle[smallest] {
+			smallest = i
+		}
+	}
+
+	return append(cycle[smallest:], cycle[:smallest]...)
+}
+
+// Find the longest chain of ordered events, in order, given the length of the
+// longest chain ending with each event.
+func longestChain(inst *Instance, chains []int) (chain []int) {
+	last := -1
+	for event, length := range chains {
+		if last == -1 || length > chains[last] {
+			last = event
+		}
+	}
+
+	for event := last; event != -1; {
+		chain = append([]int{event}, chain...)
+
+		next := -1
+		for _, before := range inst.events[event].before {
+			if chains[before] == chains[event]-1 {
+				next = before
+				break
+			}
+		}
+		event = next
+	}
+
+	return
+}
+
+// Determine the events that can only be held in the given rooms, which are in
+// increasing order.
+func (inst *Instance) eventsOnlyIn(rooms []int) (events []int) {
+	in := make(map[int]bool)
+	for _, room := range rooms {
+		in[room] = true
+	}
+
+	for event := range inst.events {
+		if len(inst.events[event].rooms) == 0 {
+			continue
+		}
+
+		only := true
+		for room := range inst.events[event].rooms {
+			only = only && in[room]
+		}
+
+		if only {
+			events = append(events, event)
+		}
+	}
+
+	return
+}
+
+// Remove the rooms and times from the domains that no feasible solution can
+// give their events, and return the number removed. The domains are made arc
+// consistent with these constraints, repeating until none of them removes
+// anything more:
+//  1. an event must be later than the earliest time of each event before it,
+//     and earlier than the latest time of each event after it;
+//  2. an event cannot be at the time of an event it conflicts with, if that
+//     event can only be at that one time;
+//  3. coincident exams must share a time; and
+//  4. except in examination instances, an event cannot have the room and time
+//     of an event that has no other room and time.
+func (inst *Instance) ReduceDomains() (removed int) {
+	conflicts := inst.conflicts()
+
+	// The times each event can be at, and the number of them.
+	times := make([][]bool, inst.nEvents)
+	nTimes := make([]int, inst.nEvents)
+	for event, domain := range inst.Domains {
+		times[event] = make([]bool, inst.nTimes)
+		for _, rat := range domain {
+			if !times[event][rat.Time] {
+				times[event][rat.Time] = true
+				nTimes[event]++
+			}
+		}
+	}
+
+	// The earliest and latest times of an event, or the number of times and
+	// -1 if it has none.
+	earliest := func(event int) int {
+		for time, ok := range times[event] {
+			if ok {
+				return time
+			}
+		}
+
+		return inst.nTimes
+	}
+
+	latest := func(event int) int {
+		for time := inst.nTimes - 1; time >= 0; time-- {
+			if times[event][time] {
+				return time
+			}
+		}
+
+		return -1
+	}
+
+	// The rats that belong to an event because it has no other. If two events
+	// have the same single rat, the second loses it.
+	taken := make(map[Rat]int)
+	if inst.exam == nil {
+		for event, domain := range inst.Domains {
+			if len(domain) == 1 {
+				if _, ok := taken[domain[0]]; !ok {
+					taken[domain[0]] = event
+				}
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for event := range inst.events {
+			e := &inst.events[event]
+			allowed := make([]bool, inst.nTimes)
+			copy(allowed, times[event])
+
+			for _, before := range e.before {
+				for time := 0; time <= earliest(before) && time < inst.nTimes; time++ {
+					allowed[time] = false
+				}
+			}
+
+			for _, after := range e.after {
+				start := latest(after)
+				if start < 0 {
+					start = 0
+				}
+
+				for time := start; time < inst.nTimes; time++ {
+					allowed[time] = false
+				}
+			}
+
+			for _, other := range conflicts[event] {
+				if nTimes[other] == 1 {
+					allowed[earliest(other)] = false
+				}
+			}
+
+			for _, other := range e.coincident {
+				for time := range allowed {
+					allowed[time] = allowed[time] && times[other][time]
+				}
+			}
+
+			domain := inst.Domains[event][:0:0]
+			for _, rat := range inst.Domains[event] {
+				if owner, ok := taken[rat]; allowed[rat.Time] && (!ok || owner == event) {
+					domain = append(domain, rat)
+				}
+			}
+
+			if len(domain) == len(inst.Domains[event]) {
+				continue
+			}
+
+			removed += len(inst.Domains[event]) - len(domain)
+			inst.Domains[event] = domain
+			changed = true
+
+			nTimes[event] = 0
+			for time := range times[event] {
+				times[event][time] = false
+			}
+
+			for _, rat := range domain {
+				if !times[event][rat.Time] {
+					times[event][rat.Time] = true
+					nTimes[event]++
+				}
+			}
+
+			if len(domain) == 1 && inst.exam == nil {
+				if _, ok := taken[domain[0]]; !ok {
+					taken[domain[0]] = event
+				}
+			}
+		}
+	}
+
+	return
+}
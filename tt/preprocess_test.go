@@ -0,0 +1,155 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The index of the first line of the availability matrix in tinyLines.
+const tinyAvailability = 1 + 2 + 12 + 2 + 4
+
+// Parse the tiny instance after limiting the given events to the given times.
+func restrictedTinyInstance(t *testing.T, events []int, times []int) *Instance {
+	lines := tinyLines()
+
+	for _, event := range events {
+		for time := 0; time < tinyWeek.NTimes(); time++ {
+			lines[tinyAvailability+event*tinyWeek.NTimes()+time] = "0"
+		}
+
+		for _, time := range times {
+			lines[tinyAvailability+event*tinyWeek.NTimes()+time] = "1"
+		}
+	}
+
+	inst, err := ParseWithWeek(strings.NewReader(joinLines(lines)), tinyWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return inst
+}
+
+// Determine if the reasons include one of the given kind about the given
+// events.
+func hasReason(reasons []Infeasibility, kind InfeasibilityKind, events string) bool {
+	for _, reason := range reasons {
+		if reason.Kind == kind && fmt.Sprint(reason.Events) == events {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestDiagnose(t *testing.T) {
+	if reasons := tinyInstance(t).Diagnose(); len(reasons) != 0 {
+		t.Errorf("the tiny instance has the reasons %v; want none", reasons)
+	}
+
+	cycle := tinyLines()
+	cycle[tinyPrecedence+2*4] = "1" // Event 2 must also be before event 0.
+
+	cycleInst, err := ParseWithWeek(strings.NewReader(joinLines(cycle)), tinyWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three events in room 0 alone, which can only hold two.
+	crowded := joinLines([]string{"3 1 0 0", "1", "1", "1", "1", "1", "1", "1", "0", "0", "0", "0", "0", "0", "0", "0", "0"})
+	crowdedInst, err := ParseWithWeek(strings.NewReader(crowded), Week{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		inst   *Instance
+		kind   InfeasibilityKind
+		events string
+	}{
+		{"unavailable", restrictedTinyInstance(t, []int{3}, nil), EmptyDomain, "[3]"},
+		{"cycle", cycleInst, PrecedenceCycle, "[0 2]"},
+		{"chain", restrictedTinyInstance(t, []int{0, 2}, []int{4}), PrecedenceChain, "[0 2]"},
+		{"clique", restrictedTinyInstance(t, []int{0, 1, 3}, []int{0, 1}), LargeClique, "[0 1 3]"},
+		{"student", restrictedTinyInstance(t, []int{0, 1, 3}, []int{0, 1}), BusyStudent, "[0 1 3]"},
+		{"rooms", crowdedInst, RoomOverload, "[0 1 2]"},
+	}
+
+	for _, test := range tests {
+		reasons := test.inst.Diagnose()
+		if !hasReason(reasons, test.kind, test.events) {
+			t.Errorf("%s: got the reasons %v; want a %s reason about events %s", test.name, reasons, test.kind, test.events)
+		}
+
+		if _, err := test.inst.Preprocess(); err == nil {
+			t.Errorf("%s: Preprocess did not return an error", test.name)
+		} else if _, ok := err.(*InfeasibleError); !ok {
+			t.Errorf("%s: got the error %q; want an *InfeasibleError", test.name, err)
+		}
+	}
+}
+
+func TestReduceDomains(t *testing.T) {
+	// Event 3 can only be at time 1, so events 0 and 1, which share student 0
+	// with it, cannot be. Event 0 must be before event 2, so event 0 cannot be
+	// at the last time and event 2 cannot be at the first.
+	inst := restrictedTinyInstance(t, []int{3}, []int{1})
+
+	removed, err := inst.Preprocess()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]Rat{
+		{{0, 0}, {0, 2}, {0, 3}, {0, 4}},
+		{{0, 0}, {0, 2}, {0, 3}, {0, 4}, {0, 5}},
+		{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {0, 5}, {1, 1}, {1, 2}, {1, 3}, {1, 4}, {1, 5}},
+		{{0, 1}, {1, 1}},
+	}
+
+	if removed != 5 {
+		t.Errorf("removed %d rooms and times; want 5", removed)
+	}
+
+	for event := range want {
+		if got := fmt.Sprint(inst.Domains[event]); got != fmt.Sprint(want[event]) {
+			t.Errorf("event %d has the domain %s; want %v", event, got, want[event])
+		}
+	}
+}
+
+func TestPreprocessEmptiesDomain(t *testing.T) {
+	// Events 0 and 1 share student 0 and can only be at time 2, so whichever
+	// is reduced first loses its only time.
+	inst := restrictedTinyInstance(t, []int{0, 1}, []int{2})
+
+	if reasons := inst.Diagnose(); len(reasons) != 0 {
+		t.Fatalf("got the reasons %v before reducing the domains; want none", reasons)
+	}
+
+	_, err := inst.Preprocess()
+	if infeasible, ok := err.(*InfeasibleError); !ok {
+		t.Errorf("got the error %v; want an *InfeasibleError", err)
+	} else if !hasReason(infeasible.Reasons, EmptyDomain, "[0]") {
+		t.Errorf("got the reasons %v; want event 0 to have an empty domain", infeasible.Reasons)
+	}
+}
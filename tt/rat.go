@@ -19,8 +19,8 @@ package tt
 
 // An assignment of a room and time.
 type Rat struct {
-	Room int // The assigned room.
-	Time int // The assigned time.
+	Room int `json:"room"` // The assigned room.
+	Time int `json:"time"` // The assigned time.
 }
 
 // Determine if the given room and time is an assigned room and time.
@@ -28,17 +28,6 @@ func (r Rat) Assigned() bool {
 	return r.Room != badRat.Room && r.Time != badRat.Time
 }
 
-// Determine the index of the room and time in the array of all rooms and
-// times.
-func (r Rat) index() int {
-	return r.Room*NTimes + r.Time
-}
-
-// Build a Rat from an index.
-func ratFromIndex(index int) Rat {
-	return Rat{index / NTimes, index % NTimes}
-}
-
 func (r Rat) After(q Rat) bool {
 	return r.Time > q.Time
 }
@@ -0,0 +1,368 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+// A student who attends more than one event at the same time. The clash
+// accounts for len(Events) - 1 violations.
+type StudentClash struct {
+	Student int   `json:"student"` // The student.
+	Time    int   `json:"time"`    // The time at which the events occur.
+	Events  []int `json:"events"`  // The events the student attends at that time.
+}
+
+// A room and time that holds events it cannot. For post-enrolment instances,
+// this is any room and time with more than one event, which accounts for one
+// violation for each pair of events. For examination instances, this is any
+// room and period that does not seat all of its exams' students or that holds
+// a room exclusive exam and another exam.
+type RatClash struct {
+	Rat        Rat   `json:"rat"`        // The room and time.
+	Events     []int `json:"events"`     // The events assigned to it.
+	Violations int   `json:"violations"` // The number of violations the clash accounts for.
+}
+
+// A precedence constraint that is broken because the event that must happen
+// first does not. Each broken pair accounts for one violation.
+type PrecedenceViolation struct {
+	Before int `json:"before"` // The event that must happen first.
+	After  int `json:"after"`  // The event that must happen after Before.
+}
+
+// The soft constraint penalties of a single student of a post-enrolment
+// instance. Only the constraints that consider each day of a student's
+// timetable on its own are included, so the penalties sum to the student's
+// contribution to the fitness from those constraints.
+type StudentPenalty struct {
+	Student   int            `json:"student"`   // The student.
+	Penalties map[string]int `json:"penalties"` // The non-zero weighted penalties, by constraint.
+}
+
+// Determine the total penalty of the student.
+func (p StudentPenalty) Total() (total int) {
+	for _, penalty := range p.Penalties {
+		total += penalty
+	}
+
+	return
+}
+
+// The penalty of a single soft constraint of a post-enrolment instance.
+type SoftPenalty struct {
+	Name    string `json:"name"`    // The name of the constraint.
+	Weight  int    `json:"weight"`  // The weight of the constraint.
+	Penalty int    `json:"penalty"` // The weighted penalty, i.e., the contribution to the fitness.
+}
+
+// The soft constraint penalties of a single student of an examination
+// instance, which sum to the student's contribution to the fitness.
+type ExamStudentPenalty struct {
+	Student      int `json:"student"`       // The student.
+	TwoInARow    int `json:"two_in_a_row"`  // The penalty for pairs of exams in a row on the same day.
+	TwoInADay    int `json:"two_in_a_day"`  // The penalty for other pairs of exams on the same day.
+	PeriodSpread int `json:"period_spread"` // The penalty for pairs of exams within the period spread.
+}
+
+// Determine the total penalty of the student.
+func (p ExamStudentPenalty) Total() int {
+	return p.TwoInARow + p.TwoInADay + p.PeriodSpread
+}
+
+// The parts of a report that only apply to examination instances.
+type ExamReport struct {
+	Coincidence []ConstraintPair // The pairs of exams that must share a period but do not.
+	Exclusion   []ConstraintPair // The pairs of exams that must not share a period but do.
+
+	Students       []ExamStudentPenalty // The penalties of every student with a non-zero penalty, by student.
+	MixedDurations int                  // The penalty for rooms holding exams of different durations.
+	FrontLoad      int                  // The penalty for large exams in the last periods.
+	RoomPenalty    int                  // The penalties of the rooms of the exams.
+	PeriodPenalty  int                  // The penalties of the periods of the exams.
+}
+
+// The soft constraint penalties of a single course of a curriculum-based
+// instance.
+type CoursePenalty struct {
+	Course         int    `json:"course"`           // The course.
+	Name           string `json:"name"`             // The name of the course.
+	RoomCapacity   int    `json:"room_capacity"`    // The penalty for students without a seat.
+	MinWorkingDays int    `json:"min_working_days"` // The penalty for days short of the minimum working days.
+	RoomStability  int    `json:"room_stability"`   // The penalty for rooms used beyond the first.
+}
+
+// Determine the total penalty of the course.
+func (p CoursePenalty) Total() int {
+	return p.RoomCapacity + p.MinWorkingDays + p.RoomStability
+}
+
+// The soft constraint penalty of a single curriculum of a curriculum-based
+// instance.
+type CurriculumPenalty struct {
+	Curriculum  int    `json:"curriculum"`  // The curriculum.
+	Name        string `json:"name"`        // The name of the curriculum.
+	Compactness int    `json:"compactness"` // The penalty for isolated lectures.
+}
+
+// The parts of a report that only apply to curriculum-based instances.
+type CurriculumReport struct {
+	Conflicts []ConstraintPair    // The pairs of lectures of conflicting courses at the same time.
+	Courses   []CoursePenalty     // The penalties of every course with a non-zero penalty, by course.
+	Curricula []CurriculumPenalty // The penalties of every curriculum with a non-zero penalty, by curriculum.
+}
+
+// A detailed account of the constraints a solution violates.
+type Report struct {
+	Value    Value // The value of the solution.
+	Distance int   // The distance to feasibility.
+
+	StudentClashes []StudentClash        // The students attending more than one event at once, by student and then time.
+	RatClashes     []RatClash            // The rooms and times holding more than one event, by room and then time.
+	Precedence     []PrecedenceViolation // The broken precedence constraints, by Before and then After.
+	Unassigned     []int                 // The events without a room and time, in increasing order.
+	Students       []StudentPenalty      // The penalties of every student with a non-zero penalty, by student (post-enrolment instances only).
+	Soft           []SoftPenalty         // The penalty of each enabled soft constraint, in the order they were registered (post-enrolment instances only).
+
+	Exam       *ExamReport       // The violations and penalties specific to examination instances, or nil.
+	Curriculum *CurriculumReport // The violations and penalties specific to curriculum-based instances, or nil.
+}
+
+// Create a report of every constraint the solution violates.
+func (s *Solution) Report() *Report {
+	r := &Report{
+		s.Value(),
+		s.Distance(),
+		[]StudentClash{},
+		[]RatClash{},
+		[]PrecedenceViolation{},
+		[]int{},
+		[]StudentPenalty{},
+		[]SoftPenalty{},
+		nil,
+		nil,
+	}
+
+	// The students of a curriculum-based instance are its curricula, whose
+	// clashes are reported as conflicts.
+	for student := 0; student < s.inst.nStudents && s.inst.ctt == nil; student++ {
+		for time := 0; time < s.inst.nTimes; time++ {
+			if s.nEventsAt(student, time) >= 2 {
+				r.StudentClashes = append(r.StudentClashes, StudentClash{student, time, s.eventsAt(student, time)})
+			}
+		}
+
+		if s.inst.exam == nil {
+			if penalty := s.studentPenalty(student); penalty.Total() > 0 {
+				r.Students = append(r.Students, penalty)
+			}
+		}
+	}
+
+	for ratIndex := range s.events {
+		violations := 0
+		if s.inst.exam != nil {
+			violations = s.roomValue(ratIndex).Violations
+		} else if nEvents := len(s.events[ratIndex]); nEvents >= 2 {
+			violations = (nEvents * (nEvents - 1)) / 2
+		}
+
+		if violations > 0 {
+			r.RatClashes = append(r.RatClashes, RatClash{s.inst.ratFromIndex(ratIndex), sorted(s.events[ratIndex]), violations})
+		}
+	}
+
+	for event, rat := range s.rats {
+		if !rat.Assigned() {
+			r.Unassigned = append(r.Unassigned, event)
+			continue
+		}
+
+		for _, after := range s.inst.events[event].after {
+			if other := s.rats[after]; other.Assigned() && !other.After(rat) {
+				r.Precedence = append(r.Precedence, PrecedenceViolation{event, after})
+			}
+		}
+	}
+
+	switch {
+	case s.inst.exam != nil:
+		r.Exam = s.examReport()
+
+	case s.inst.ctt != nil:
+		r.Curriculum = s.curriculumReport()
+
+	default:
+		for _, c := range s.inst.soft {
+			r.Soft = append(r.Soft, SoftPenalty{c.Name, c.weight, c.weight * s.softPenalty(c.SoftConstraint)})
+		}
+	}
+
+	return r
+}
+
+// Create the parts of a report that only apply to examination instances.
+func (s *Solution) examReport() *ExamReport {
+	r := &ExamReport{
+		[]ConstraintPair{},
+		[]ConstraintPair{},
+		[]ExamStudentPenalty{},
+		0,
+		0,
+		0,
+		0,
+	}
+
+	w := &s.inst.exam.weights
+
+	for event, rat := range s.rats {
+		if !rat.Assigned() {
+			continue
+		}
+
+		e := &s.inst.events[event]
+
+		for _, other := range e.coincident {
+			if otherRat := s.rats[other]; event < other && otherRat.Assigned() && otherRat.Time != rat.Time {
+				r.Coincidence = append(r.Coincidence, ConstraintPair{event, other})
+			}
+		}
+
+		for _, other := range e.separate {
+			if otherRat := s.rats[other]; event < other && otherRat.Assigned() && otherRat.Time == rat.Time {
+				r.Exclusion = append(r.Exclusion, ConstraintPair{event, other})
+			}
+		}
+
+		if e.large && rat.Time >= s.inst.nTimes-w.frontLoadPeriods {
+			r.FrontLoad += w.frontLoad
+		}
+
+		r.RoomPenalty += s.inst.rooms[rat.Room].penalty
+		r.PeriodPenalty += s.inst.exam.periods[rat.Time].penalty
+	}
+
+	for ratIndex := range s.events {
+		r.MixedDurations += s.roomValue(ratIndex).Fitness
+	}
+
+	for student := 0; student < s.inst.nStudents; student++ {
+		if penalty := s.examStudentPenalty(student); penalty.Total() > 0 {
+			r.Students = append(r.Students, penalty)
+		}
+	}
+
+	return r
+}
+
+// Create the parts of a report that only apply to curriculum-based instances.
+func (s *Solution) curriculumReport() *CurriculumReport {
+	r := &CurriculumReport{
+		[]ConstraintPair{},
+		[]CoursePenalty{},
+		[]CurriculumPenalty{},
+	}
+
+	c := s.inst.ctt
+
+	for event, rat := range s.rats {
+		if !rat.Assigned() {
+			continue
+		}
+
+		for _, other := range s.inst.events[event].exclude {
+			if otherRat := s.rats[other]; event < other && otherRat.Assigned() && otherRat.Time == rat.Time {
+				r.Conflicts = append(r.Conflicts, ConstraintPair{event, other})
+			}
+		}
+	}
+
+	for course := range c.courses {
+		penalty := CoursePenalty{course, c.courses[course].name, 0, 0, 0}
+
+		for _, lecture := range c.courses[course].lectures {
+			if rat := s.rats[lecture]; rat.Assigned() {
+				penalty.RoomCapacity += s.roomCapacityPenalty(lecture, rat)
+			}
+		}
+
+		penalty.MinWorkingDays, penalty.RoomStability = s.coursePenalties(course)
+
+		if penalty.Total() > 0 {
+			r.Courses = append(r.Courses, penalty)
+		}
+	}
+
+	for curr := range c.curricula {
+		penalty := CurriculumPenalty{curr, c.curricula[curr].name, 0}
+
+		for day := 0; day < s.inst.week.Days; day++ {
+			penalty.Compactness += s.compactnessPenalty(curr, day)
+		}
+
+		if penalty.Compactness > 0 {
+			r.Curricula = append(r.Curricula, penalty)
+		}
+	}
+
+	return r
+}
+
+// Compute the soft constraint penalties of a single student of an examination
+// instance by considering every pair of the student's exams.
+func (s *Solution) examStudentPenalty(student int) (penalty ExamStudentPenalty) {
+	penalty.Student = student
+
+	for first := 0; first < s.inst.nTimes; first++ {
+		count := s.nEventsAt(student, first)
+		if count == 0 {
+			continue
+		}
+
+		for second := first + 1; second < s.inst.nTimes; second++ {
+			if otherCount := s.nEventsAt(student, second); otherCount > 0 {
+				row, day, spread := s.inst.exam.pairPenalty(first, second)
+				penalty.TwoInARow += count * otherCount * row
+				penalty.TwoInADay += count * otherCount * day
+				penalty.PeriodSpread += count * otherCount * spread
+			}
+		}
+	}
+
+	return
+}
+
+// Compute the weighted penalties of a single student for each soft constraint
+// that considers each day of a student's timetable on its own.
+func (s *Solution) studentPenalty(student int) (penalty StudentPenalty) {
+	penalty = StudentPenalty{student, make(map[string]int)}
+
+	for _, c := range s.inst.soft {
+		if c.Day == nil {
+			continue
+		}
+
+		total := 0
+		for day := 0; day < s.inst.week.Days; day++ {
+			total += c.weight * c.Day(s, student, day)
+		}
+
+		if total > 0 {
+			penalty.Penalties[c.Name] = total
+		}
+	}
+
+	return
+}
@@ -0,0 +1,112 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestReportTiny(t *testing.T) {
+	inst := tinyInstance(t)
+
+	// Events 0 and 1 share room 0 and student 0 at time 3, event 2 is before
+	// event 0, and event 3 is unassigned.
+	s := inst.SolutionFromRats([]Rat{{0, 3}, {0, 3}, {1, 0}, badRat})
+	defer s.Free()
+
+	r := s.Report()
+
+	if want := []StudentClash{{0, 3, []int{0, 1}}}; !reflect.DeepEqual(r.StudentClashes, want) {
+		t.Errorf("got the student clashes %v; want %v", r.StudentClashes, want)
+	}
+
+	if want := []RatClash{{Rat{0, 3}, []int{0, 1}, 1}}; !reflect.DeepEqual(r.RatClashes, want) {
+		t.Errorf("got the room clashes %v; want %v", r.RatClashes, want)
+	}
+
+	if want := []PrecedenceViolation{{0, 2}}; !reflect.DeepEqual(r.Precedence, want) {
+		t.Errorf("got the broken precedence constraints %v; want %v", r.Precedence, want)
+	}
+
+	if want := []int{3}; !reflect.DeepEqual(r.Unassigned, want) {
+		t.Errorf("got the unassigned events %v; want %v", r.Unassigned, want)
+	}
+
+	if r.Value != s.Value() || r.Value.Violations != 3 || r.Distance != s.Distance() {
+		t.Errorf("got the value %s and distance %d; want %s with 3 violations and distance %d", r.Value, r.Distance, s.Value(), s.Distance())
+	}
+
+	if r.Exam != nil || r.Curriculum != nil {
+		t.Error("a post-enrolment report has examination or curriculum-based parts")
+	}
+}
+
+func TestReportCounts(t *testing.T) {
+	inst := tinyInstance(t)
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 200; i++ {
+		s := inst.NewSolution()
+		for event, domain := range inst.Domains {
+			if rng.Intn(5) > 0 {
+				s.Assign(event, domain[rng.Intn(len(domain))])
+			}
+		}
+
+		r := s.Report()
+
+		// The violations in the report account for every violation.
+		violations := len(r.Precedence)
+		for _, clash := range r.StudentClashes {
+			violations += len(clash.Events) - 1
+		}
+		for _, clash := range r.RatClashes {
+			violations += clash.Violations
+		}
+
+		// Every enabled soft constraint considers each day of a student's
+		// timetable on its own, so the students' penalties account for the
+		// whole fitness.
+		soft, students := 0, 0
+		for _, penalty := range r.Soft {
+			soft += penalty.Penalty
+		}
+		for _, penalty := range r.Students {
+			students += penalty.Total()
+		}
+
+		if violations != s.Violations() || soft != s.Fitness() || students != s.Fitness() {
+			t.Fatalf("%v: the report accounts for %d violations, %d soft penalty, and %d student penalty; want %s", s.Assignments(), violations, soft, students, s.Value())
+		}
+
+		unassigned := []int{}
+		for event, rat := range s.Assignments() {
+			if !rat.Assigned() {
+				unassigned = append(unassigned, event)
+			}
+		}
+
+		if !reflect.DeepEqual(r.Unassigned, unassigned) {
+			t.Fatalf("%v: got the unassigned events %v; want %v", s.Assignments(), r.Unassigned, unassigned)
+		}
+
+		s.Free()
+	}
+}
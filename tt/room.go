@@ -21,6 +21,7 @@ package tt
 type room struct {
 	capacity int          // The capacity of the room.
 	features map[int]bool // The features that the room has.
+	penalty  int          // The penalty for each exam in the room (examination instances only).
 }
 
 // Determine if room can host a given event, determined by the number of
@@ -0,0 +1,316 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A soft constraint of a post-enrolment instance. The fitness of a solution is
+// the sum of the penalties of the instance's soft constraints, each multiplied
+// by its weight.
+//
+// Most constraints penalize each day of each student's timetable on its own,
+// and only need to provide Day. Constraints whose penalties span more than one
+// student-day instead provide both Evaluate and Affected.
+type SoftConstraint struct {
+	Name string // The name of the constraint, as used in weight files.
+
+	// Compute the penalty of a single day of a student's timetable.
+	Day func(s *Solution, student, day int) int
+
+	// Compute the penalty of the whole solution.
+	Evaluate func(s *Solution) int
+
+	// Compute the part of the penalty that can change when the event is moved
+	// from one room and time to another (either of which may be unassigned).
+	// It is called both before and after the move, and the difference is the
+	// change in the penalty.
+	Affected func(s *Solution, event int, from, to Rat) int
+
+	itc bool // Is this one of the ITC2007 constraints, which AssignmentQuality estimates on its own?
+}
+
+// A soft constraint with its weight in an instance.
+type weightedConstraint struct {
+	SoftConstraint
+	weight int
+}
+
+// The weights of the soft constraints, by name. A weight of zero disables a
+// constraint.
+type SoftWeights map[string]int
+
+// The registered soft constraints, in the order they were registered.
+var softConstraints = []SoftConstraint{
+	{"single_class_day", singleClassDay, nil, nil, true},
+	{"consecutive_classes", consecutiveClasses, nil, nil, true},
+	{"last_period", lastPeriod, nil, nil, true},
+}
+
+// The weights of the ITC2007 soft constraints, which are used unless an
+// instance is given others.
+var DefaultSoftWeights = SoftWeights{
+	"single_class_day":    1,
+	"consecutive_classes": 1,
+	"last_period":         1,
+}
+
+// Register a soft constraint so that it can be given a weight. This panics if
+// the constraint is malformed or its name is already registered, so it should
+// be called from an init function.
+func RegisterSoftConstraint(c SoftConstraint) {
+	if c.Name == "" {
+		panic("tt.RegisterSoftConstraint: constraint has no name")
+	} else if (c.Day == nil) == (c.Evaluate == nil || c.Affected == nil) {
+		panic("tt.RegisterSoftConstraint: " + c.Name + " must have either Day or both Evaluate and Affected")
+	}
+
+	if _, ok := findSoftConstraint(c.Name); ok {
+		panic("tt.RegisterSoftConstraint: " + c.Name + " is already registered")
+	}
+
+	softConstraints = append(softConstraints, c)
+}
+
+// Determine the names of the registered soft constraints, in the order they
+// were registered.
+func SoftConstraintNames() (names []string) {
+	for _, c := range softConstraints {
+		names = append(names, c.Name)
+	}
+
+	return
+}
+
+// Find the registered soft constraint with the given name.
+func findSoftConstraint(name string) (c SoftConstraint, ok bool) {
+	for _, c = range softConstraints {
+		if c.Name == name {
+			return c, true
+		}
+	}
+
+	return
+}
+
+// Read soft constraint weights from the reader. Each non-blank line has the
+// name of a constraint and its weight, separated by whitespace; everything
+// after a '#' is a comment. The weights are applied on top of the default
+// weights, so a constraint that is not listed keeps its default weight.
+func ReadSoftWeights(r io.Reader) (weights SoftWeights, err error) {
+	weights = make(SoftWeights)
+	for name, weight := range DefaultSoftWeights {
+		weights[name] = weight
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if comment := strings.IndexByte(text, '#'); comment >= 0 {
+			text = text[:comment]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) != 2 {
+			return nil, fmt.Errorf(formatError, line, "expected a constraint and a weight")
+		}
+
+		if _, ok := findSoftConstraint(fields[0]); !ok {
+			return nil, fmt.Errorf(formatError, line, "no such soft constraint "+fields[0])
+		}
+
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf(formatError, line, err.Error())
+		} else if weight < 0 {
+			return nil, fmt.Errorf(formatError, line, fmt.Sprintf("expected a non-negative weight; got %d", weight))
+		}
+
+		weights[fields[0]] = weight
+	}
+
+	return weights, scanner.Err()
+}
+
+// Set the weights of the soft constraints of a post-enrolment instance.
+// Constraints without a weight are disabled. This must be called before any
+// solutions to the instance are created.
+func (inst *Instance) SetSoftWeights(weights SoftWeights) error {
+	if inst.Format() != PostEnrolment {
+		return fmt.Errorf("soft constraint weights only apply to post-enrolment instances")
+	}
+
+	for name := range weights {
+		if _, ok := findSoftConstraint(name); !ok {
+			return fmt.Errorf("no such soft constraint %s", name)
+		}
+	}
+
+	inst.soft = nil
+	for _, c := range softConstraints {
+		if weight := weights[c.Name]; weight > 0 {
+			inst.soft = append(inst.soft, weightedConstraint{c, weight})
+		}
+	}
+
+	// Some constraints may penalize a timetable with no events in it.
+	inst.empty = Value{}
+	empty := inst.allocSolution()
+	inst.empty.Fitness = empty.evaluateSoft()
+
+	return nil
+}
+
+// Get the weights of the instance's soft constraints. Only the enabled
+// constraints are included.
+func (inst *Instance) SoftWeights() SoftWeights {
+	weights := make(SoftWeights)
+	for _, c := range inst.soft {
+		weights[c.Name] = c.weight
+	}
+
+	return weights
+}
+
+// Compute the weighted penalty of the soft constraints by scanning the whole
+// solution.
+func (s *Solution) evaluateSoft() (fitness int) {
+	for _, c := range s.inst.soft {
+		fitness += c.weight * s.softPenalty(c.SoftConstraint)
+	}
+
+	return
+}
+
+// Compute the (unweighted) penalty of a single soft constraint by scanning the
+// whole solution.
+func (s *Solution) softPenalty(c SoftConstraint) (penalty int) {
+	if c.Day == nil {
+		return c.Evaluate(s)
+	}
+
+	for student := 0; student < s.inst.nStudents; student++ {
+		for day := 0; day < s.inst.week.Days; day++ {
+			penalty += c.Day(s, student, day)
+		}
+	}
+
+	return
+}
+
+// Compute the weighted penalty of the soft constraints that consider each day
+// of a student's timetable on its own for a single student on a single day.
+func (s *Solution) dayFitness(student, day int) (fit int) {
+	for _, c := range s.inst.soft {
+		if c.Day != nil {
+			fit += c.weight * c.Day(s, student, day)
+		}
+	}
+
+	return
+}
+
+// Compute the weighted penalty of the soft constraints that consider each day
+// of a student's timetable on its own, other than the ITC2007 constraints, for
+// the event's students on the event's day.
+func (s *Solution) dayQuality(event int) (fit int) {
+	day := s.inst.week.day(s.rats[event].Time)
+
+	for _, c := range s.inst.soft {
+		if c.Day == nil || c.itc {
+			continue
+		}
+
+		for _, student := range s.inst.events[event].students {
+			fit += c.weight * c.Day(s, student, day)
+		}
+	}
+
+	return
+}
+
+// Compute the weighted part of the penalty of the other soft constraints that
+// can change when the event is moved between the two given Rats.
+func (s *Solution) affectedSoft(event int, from, to Rat) (fit int) {
+	for _, c := range s.inst.soft {
+		if c.Day == nil {
+			fit += c.weight * c.Affected(s, event, from, to)
+		}
+	}
+
+	return
+}
+
+// Determine the weight of the named soft constraint in the instance, which is
+// zero if it is disabled.
+func (inst *Instance) softWeight(name string) int {
+	for _, c := range inst.soft {
+		if c.Name == name {
+			return c.weight
+		}
+	}
+
+	return 0
+}
+
+// Penalize a student who has only one class on a day.
+func singleClassDay(s *Solution, student, day int) int {
+	start := day * s.inst.week.Periods
+
+	if s.nBusy(student, start, start+s.inst.week.Periods) == 1 {
+		return 1
+	}
+
+	return 0
+}
+
+// Penalize a student once for each class past the second in a row on a day.
+func consecutiveClasses(s *Solution, student, day int) (penalty int) {
+	consecutive := 0
+	start := day * s.inst.week.Periods
+
+	for period := 0; period < s.inst.week.Periods; period++ {
+		if s.attends(student, start+period) {
+			consecutive++
+
+			if consecutive > 2 {
+				penalty++
+			}
+		} else {
+			consecutive = 0
+		}
+	}
+
+	return
+}
+
+// Penalize a student who has a class in the last period of a day.
+func lastPeriod(s *Solution, student, day int) int {
+	if s.attends(student, (day+1)*s.inst.week.Periods-1) {
+		return 1
+	}
+
+	return 0
+}
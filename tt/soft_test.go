@@ -0,0 +1,110 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSoftWeights(t *testing.T) {
+	weights, err := ReadSoftWeights(strings.NewReader("# The weights of a run.\n\nlast_period 0\nidle_gaps\t2 # Gaps hurt twice as much.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The constraints that are not listed keep their default weights.
+	want := SoftWeights{"single_class_day": 1, "consecutive_classes": 1, "last_period": 0, "idle_gaps": 2}
+	if !reflect.DeepEqual(weights, want) {
+		t.Errorf("got the weights %v; want %v", weights, want)
+	}
+
+	if DefaultSoftWeights["last_period"] != 1 {
+		t.Error("reading weights changed the default weights")
+	}
+}
+
+func TestReadSoftWeightsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string // A substring of the error.
+	}{
+		{"no weight", "last_period\n", "line 1"},
+		{"extra field", "\nlast_period 1 2\n", "line 2"},
+		{"unknown constraint", "last_periods 1\n", "no such soft constraint last_periods"},
+		{"bad weight", "last_period one\n", "line 1"},
+		{"negative weight", "last_period -1\n", "expected a non-negative weight"},
+	}
+
+	for _, test := range tests {
+		if _, err := ReadSoftWeights(strings.NewReader(test.source)); err == nil {
+			t.Errorf("%s: read the weights; want an error containing %q", test.name, test.want)
+		} else if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %q; want one containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestSetSoftWeights(t *testing.T) {
+	// The feasible solution of TestSolutionValues has one single class day,
+	// one run of three classes in a row, and two classes in the last period.
+	rats := []Rat{{0, 0}, {0, 1}, {1, 2}, {0, 2}}
+
+	tests := []struct {
+		weights SoftWeights
+		fitness int
+	}{
+		{DefaultSoftWeights, 4},
+		{SoftWeights{"single_class_day": 2, "consecutive_classes": 3, "last_period": 0}, 5},
+		{SoftWeights{"last_period": 5}, 10},
+		{SoftWeights{}, 0},
+	}
+
+	for _, test := range tests {
+		inst := tinyInstance(t)
+		if err := inst.SetSoftWeights(test.weights); err != nil {
+			t.Fatalf("%v: %s", test.weights, err)
+		}
+
+		// Only the enabled constraints have weights.
+		for name, weight := range inst.SoftWeights() {
+			if weight != test.weights[name] {
+				t.Errorf("%v: %s has the weight %d", test.weights, name, weight)
+			}
+		}
+
+		s := inst.SolutionFromRats(rats)
+		if got := s.Fitness(); got != test.fitness {
+			t.Errorf("%v: got fitness %d; want %d", test.weights, got, test.fitness)
+		} else if got := s.Report().Value; got != s.Value() {
+			t.Errorf("%v: the report has value %s; want %s", test.weights, got, s.Value())
+		}
+
+		s.Free()
+	}
+
+	if err := tinyInstance(t).SetSoftWeights(SoftWeights{"lunch": 1}); err == nil {
+		t.Error("set the weight of an unknown constraint")
+	}
+
+	if err := tinyExamInstance(t).SetSoftWeights(DefaultSoftWeights); err == nil {
+		t.Error("set the weights of an examination instance")
+	}
+}
@@ -20,20 +20,107 @@ package tt
 import (
 	"fmt"
 	"io"
+	"math/bits"
 	"math/rand"
 )
 
 // A solution to an instance.
+//
+// The attendance of the students is kept as a count of the events each student
+// attends at each time, indexed by student*nTimes + time, along with a bitset
+// of the times at which each student attends any event (busyWords words per
+// student). Neither needs any allocation once the solution has been created,
+// so solutions are cheap to clone and to reuse.
 type Solution struct {
-	inst       *Instance          // The problem instance.
-	attendance [][45]map[int]bool // Student attendance matrix.
-	events     []map[int]bool     // Map each room and time to an event.
-	rats       []Rat              // Map each event to a room and time.
-	Domains    [][]Rat            // The domains.
+	inst       *Instance // The problem instance.
+	attendance []uint16  // The number of events each student attends at each time.
+	busy       []uint64  // The times at which each student attends any event.
+	events     [][]int   // The events assigned to each room and time.
+	rats       []Rat     // Map each event to a room and time.
+	Domains    [][]Rat   // The domains.
+	value      Value     // The value of the solution, maintained by Assign.
 }
 
+// Determine if the student attends any event at the given time.
 func (s *Solution) attends(student, time int) bool {
-	return len(s.attendance[student][time]) > 0
+	word := student*s.inst.busyWords() + time/64
+	return s.busy[word]&(1<<uint(time%64)) != 0
+}
+
+// Determine the number of events the student attends at the given time.
+func (s *Solution) nEventsAt(student, time int) int {
+	return int(s.attendance[student*s.inst.nTimes+time])
+}
+
+// Determine the number of times in the range [from, to) at which the student
+// attends any event.
+func (s *Solution) nBusy(student, from, to int) (count int) {
+	words := s.busy[student*s.inst.busyWords():]
+
+	for from < to {
+		// Count the bits of one word at a time.
+		n := 64 - from%64
+		if to-from < n {
+			n = to - from
+		}
+
+		mask := (uint64(1)<<uint(n) - 1) << uint(from%64)
+		if n == 64 {
+			mask = ^uint64(0)
+		}
+
+		count += bits.OnesCount64(words[from/64] & mask)
+		from += n
+	}
+
+	return
+}
+
+// Determine the events the student attends at the given time, in increasing
+// order.
+func (s *Solution) eventsAt(student, time int) (events []int) {
+	for _, event := range s.inst.studentEvents[student] {
+		if s.rats[event].Time == time {
+			events = append(events, event)
+		}
+	}
+
+	return
+}
+
+// Record that the student attends an event at the given time.
+func (s *Solution) attend(student, time int) {
+	s.attendance[student*s.inst.nTimes+time]++
+	s.busy[student*s.inst.busyWords()+time/64] |= 1 << uint(time%64)
+}
+
+// Record that the student no longer attends an event at the given time.
+func (s *Solution) unattend(student, time int) {
+	index := student*s.inst.nTimes + time
+	s.attendance[index]--
+
+	if s.attendance[index] == 0 {
+		s.busy[student*s.inst.busyWords()+time/64] &^= 1 << uint(time%64)
+	}
+}
+
+// Add the event to the events assigned to a room and time.
+func (s *Solution) addEvent(ratIndex, event int) {
+	s.events[ratIndex] = append(s.events[ratIndex], event)
+}
+
+// Remove the event from the events assigned to a room and time. The order of
+// the other events is not kept.
+func (s *Solution) removeEvent(ratIndex, event int) {
+	events := s.events[ratIndex]
+
+	for i, other := range events {
+		if other == event {
+			events[i] = events[len(events)-1]
+			s.events[ratIndex] = events[:len(events)-1]
+			return
+		}
+	}
 }
 
 // Retrieve the assignments of a solution as a copy. This is a lighter-weight
@@ -56,47 +143,171 @@ func (s *Solution) Assigned(eventIndex int) bool {
 	}
 }
 
-// Assign an event to a room and time.
+// Assign an event to a room and time. The value of the solution is updated
+// incrementally, so only the penalties involving the event are recomputed.
 func (s *Solution) Assign(event int, rat Rat) {
 	if event > s.inst.nEvents {
 		panic("Solution.Assign: event > nEvents")
-	} else if rat.index() > s.inst.nRooms*NTimes {
+	} else if s.inst.ratIndex(rat) >= len(s.events) {
 		panic("Solution.Assign: invalid Rat")
 	}
 
-	ratIndex := rat.index()
+	oldRat := s.rats[event]
+	before := s.affected(event, oldRat, rat)
 
 	// If the event is already assigned to a room and time (oldRat), we
 	// unassign it and replace the entries in the attendance matrix.
 	//
 	// Otherwise, we just add the new entries to the attendance matrix.
-	if oldRat := s.rats[event]; oldRat.Assigned() {
-		delete(s.events[oldRat.index()], event)
+	if oldRat.Assigned() {
+		s.removeEvent(s.inst.ratIndex(oldRat), event)
 
-		for student := range s.inst.events[event].students {
-			delete(s.attendance[student][oldRat.Time], event)
-			s.attendance[student][rat.Time][event] = true
+		for _, student := range s.inst.events[event].students {
+			s.unattend(student, oldRat.Time)
+			s.attend(student, rat.Time)
 		}
 	} else {
-		for student := range s.inst.events[event].students {
-			s.attendance[student][rat.Time][event] = true
+		for _, student := range s.inst.events[event].students {
+			s.attend(student, rat.Time)
 		}
 	}
 
 	s.rats[event] = rat
-	s.events[ratIndex][event] = true
+	s.addEvent(s.inst.ratIndex(rat), event)
+
+	after := s.affected(event, oldRat, rat)
+	s.value.Violations += after.Violations - before.Violations
+	s.value.Fitness += after.Fitness - before.Fitness
+}
+
+// Remove the assignment of an event, if it has one.
+func (s *Solution) Unassign(event int) {
+	if event > s.inst.nEvents {
+		panic("Solution.Unassign: event > nEvents")
+	}
+
+	oldRat := s.rats[event]
+	if !oldRat.Assigned() {
+		return
+	}
+
+	before := s.affected(event, oldRat, badRat)
+
+	s.removeEvent(s.inst.ratIndex(oldRat), event)
+	for _, student := range s.inst.events[event].students {
+		s.unattend(student, oldRat.Time)
+	}
+	s.rats[event] = badRat
+
+	after := s.affected(event, oldRat, badRat)
+	s.value.Violations += after.Violations - before.Violations
+	s.value.Fitness += after.Fitness - before.Fitness
+}
+
+// Determine the value the solution would have if the event were assigned to
+// the given room and time. The solution is left as it was.
+func (s *Solution) AssignValue(event int, rat Rat) (value Value) {
+	oldRat := s.rats[event]
+	if oldRat == rat {
+		return s.value
+	}
+
+	s.Assign(event, rat)
+	value = s.value
+
+	if oldRat.Assigned() {
+		s.Assign(event, oldRat)
+	} else {
+		s.Unassign(event)
+	}
+
+	return
+}
+
+// Determine the part of the solution value that can change when an event is
+// moved between the two given Rats (either of which may be unassigned). This
+// covers every student-time, Rat, and student-day that the move touches as
+// well as the ordering constraints of the event itself, so the difference of
+// this value before and after the move is the change in the solution value.
+func (s *Solution) affected(event int, from, to Rat) (value Value) {
+	switch {
+	case s.inst.exam != nil:
+		return s.examAffected(event, from, to)
+
+	case s.inst.ctt != nil:
+		return s.cttAffected(event, from, to)
+	}
+
+	e := &s.inst.events[event]
+
+	// Each student of the event is affected at the times (and days) that the
+	// event is moved from and to.
+	for _, student := range e.students {
+		if from.Assigned() {
+			if nEvents := s.nEventsAt(student, from.Time); nEvents >= 2 {
+				value.Violations += nEvents - 1
+			}
+			value.Fitness += s.dayFitness(student, s.inst.week.day(from.Time))
+		}
+
+		if to.Assigned() {
+			if !from.Assigned() || to.Time != from.Time {
+				if nEvents := s.nEventsAt(student, to.Time); nEvents >= 2 {
+					value.Violations += nEvents - 1
+				}
+			}
+
+			if !from.Assigned() || s.inst.week.day(to.Time) != s.inst.week.day(from.Time) {
+				value.Fitness += s.dayFitness(student, s.inst.week.day(to.Time))
+			}
+		}
+	}
+
+	if from.Assigned() {
+		if nEvents := len(s.events[s.inst.ratIndex(from)]); nEvents >= 2 {
+			value.Violations += (nEvents * (nEvents - 1)) / 2
+		}
+	}
+
+	if to.Assigned() && to != from {
+		if nEvents := len(s.events[s.inst.ratIndex(to)]); nEvents >= 2 {
+			value.Violations += (nEvents * (nEvents - 1)) / 2
+		}
+	}
+
+	if rat := s.rats[event]; rat.Assigned() {
+		for _, after := range e.after {
+			if other := s.rats[after]; other.Assigned() && !other.After(rat) {
+				value.Violations++
+			}
+		}
+
+		for _, before := range e.before {
+			if other := s.rats[before]; other.Assigned() && !rat.After(other) {
+				value.Violations++
+			}
+		}
+	}
+
+	value.Fitness += s.affectedSoft(event, from, to)
+
+	return
 }
 
 func (s *Solution) AssignAndShrink(event int, rat Rat, domains []map[Rat]bool) {
 	s.Assign(event, rat)
 
 	// Remove the assignment from the domains of all events.
-	for other := range domains {
-		delete(domains[other], rat)
+	if s.inst.exam != nil {
+		s.shrinkExamDomains(event, rat, domains)
+	} else {
+		for other := range domains {
+			delete(domains[other], rat)
+		}
 	}
 
 	// Remove the time slot from all events that share a student.
-	for exclude := range s.inst.events[event].exclude {
+	for _, exclude := range s.inst.events[event].exclude {
 		if s.inst.events[exclude].times[rat.Time] {
 			for room := range s.inst.events[exclude].rooms {
 				delete(domains[exclude], Rat{room, rat.Time})
@@ -105,9 +316,9 @@ func (s *Solution) AssignAndShrink(event int, rat Rat, domains []map[Rat]bool) {
 	}
 
 	// Remove the domain entries from all events that must occur before it.
-	for before := range s.inst.events[event].before {
+	for _, before := range s.inst.events[event].before {
 		for room := range s.inst.events[before].rooms {
-			for time := rat.Time; time < NTimes; time++ {
+			for time := rat.Time; time < s.inst.nTimes; time++ {
 				if s.inst.events[before].times[time] {
 					delete(domains[before], Rat{room, time})
 				}
@@ -116,7 +327,7 @@ func (s *Solution) AssignAndShrink(event int, rat Rat, domains []map[Rat]bool) {
 	}
 
 	// Remove the domain entries from all events that must occur after it.
-	for after := range s.inst.events[event].after {
+	for _, after := range s.inst.events[event].after {
 		for room := range s.inst.events[after].rooms {
 			for time := 0; time <= rat.Time; time++ {
 				if s.inst.events[after].times[time] {
@@ -128,6 +339,12 @@ func (s *Solution) AssignAndShrink(event int, rat Rat, domains []map[Rat]bool) {
 
 }
 
+// Determine the quality of an assignment to an examination or curriculum-based
+// instance, i.e., the value of every constraint that involves the event.
+func (s *Solution) quality(event int) Value {
+	return s.affected(event, badRat, s.rats[event])
+}
+
 // Determine the quality of each assignment, as determined by the number of
 // soft constraints each event breaks.
 //
@@ -149,39 +366,43 @@ func (s *Solution) AssignmentQualities() (quality []Value) {
 func (s *Solution) AssignmentQuality(event int) (quality Value) {
 	if event > s.inst.nEvents {
 		panic("Solution.AssignmentQuality: event > nEvents")
+	} else if s.inst.Format() != PostEnrolment {
+		return s.quality(event)
 	}
 
 	nStudents := len(s.inst.events[event].students)
+	consecutiveWeight := s.inst.softWeight("consecutive_classes")
+	lastPeriodWeight := s.inst.softWeight("last_period")
 	time := s.rats[event].Time
-	startOfDay := time - time%9
-	endOfDay := startOfDay + 8
+	startOfDay := time - time%s.inst.week.Periods
+	endOfDay := startOfDay + s.inst.week.Periods - 1
 
 	// We find the number of consecutive events that the
 	// event is a part of.
-	for student := range s.inst.events[event].students {
+	for _, student := range s.inst.events[event].students {
 		blockStart := time
 		blockEnd := time
 
-		for blockStart > startOfDay && len(s.attendance[student][blockStart-1]) > 0 {
+		for blockStart > startOfDay && s.nEventsAt(student, blockStart-1) > 0 {
 			blockStart--
 		}
-		for blockEnd < endOfDay && len(s.attendance[student][blockEnd+1]) > 0 {
+		for blockEnd < endOfDay && s.nEventsAt(student, blockEnd+1) > 0 {
 			blockEnd++
 		}
 
 		if consecutive := blockEnd - blockStart + 1; consecutive > 2 {
-			quality.Fitness += consecutive - 2
+			quality.Fitness += consecutiveWeight * (consecutive - 2)
 		} else {
 			// Find the total number of events in the day
 			count := 0
 			for t := startOfDay; t <= endOfDay; t++ {
-				if len(s.attendance[student][t]) > 0 {
+				if s.nEventsAt(student, t) > 0 {
 					count++
 				}
 			}
 		}
 
-		if nEvents := len(s.attendance[student][time]); nEvents >= 2 {
+		if nEvents := s.nEventsAt(student, time); nEvents >= 2 {
 			quality.Violations += (nEvents * (nEvents - 1)) / 2
 		}
 	}
@@ -189,24 +410,28 @@ func (s *Solution) AssignmentQuality(event int) (quality Value) {
 	// If the event is scheduled at the end of the day, then the
 	// penalty is the number of students that would attend the event.
 	if time == endOfDay {
-		quality.Fitness += nStudents
+		quality.Fitness += lastPeriodWeight * nStudents
 	}
 
+	// The other soft constraints consider the event's students on its day and
+	// the event's assignment on their own.
+	quality.Fitness += s.dayQuality(event) + s.affectedSoft(event, badRat, s.rats[event])
+
 	// If there are multiple assignments to the event's room and time, then
 	// the penalty is the number of assignments minus one.
-	quality.Violations += len(s.events[s.rats[event].index()]) - 1
+	quality.Violations += len(s.events[s.inst.ratIndex(s.rats[event])]) - 1
 
 	// We consider ordering violations for events. Unlike in Violations(),
 	// we consider both the before and after relations because we are
 	// concerned with how well we have assigned each variable, not the
 	// quality of the overall solution.
-	for after := range s.inst.events[event].after {
+	for _, after := range s.inst.events[event].after {
 		if s.rats[after].Time <= s.rats[event].Time {
 			quality.Violations++
 		}
 	}
 
-	for before := range s.inst.events[event].before {
+	for _, before := range s.inst.events[event].before {
 		if s.rats[before].Time >= s.rats[event].Time {
 			quality.Violations++
 		}
@@ -222,25 +447,27 @@ func (s *Solution) AssignmentQuality(event int) (quality Value) {
 func (s *Solution) AssignmentViolations(event int) (violations int) {
 	if event > s.inst.nEvents {
 		panic("Solution.AssignmentQuality: event > nEvents")
+	} else if s.inst.Format() != PostEnrolment {
+		return s.quality(event).Violations
 	}
 
 	time := s.rats[event].Time
 
-	for student := range s.inst.events[event].students {
-		if nEvents := len(s.attendance[student][time]); nEvents >= 2 {
+	for _, student := range s.inst.events[event].students {
+		if nEvents := s.nEventsAt(student, time); nEvents >= 2 {
 			violations += (nEvents * (nEvents - 1)) / 2
 		}
 	}
 
-	violations += len(s.events[s.rats[event].index()]) - 1
+	violations += len(s.events[s.inst.ratIndex(s.rats[event])]) - 1
 
-	for after := range s.inst.events[event].after {
+	for _, after := range s.inst.events[event].after {
 		if s.rats[after].Time <= s.rats[event].Time {
 			violations++
 		}
 	}
 
-	for before := range s.inst.events[event].before {
+	for _, before := range s.inst.events[event].before {
 		if s.rats[before].Time >= s.rats[event].Time {
 			violations++
 		}
@@ -253,10 +480,13 @@ func (s *Solution) AssignmentViolations(event int) (violations int) {
 func (s *Solution) Clone() (clone *Solution) {
 	clone = s.inst.NewSolution()
 
-	for event, rat := range s.rats {
-		if rat.Assigned() {
-			clone.Assign(event, rat)
-		}
+	copy(clone.attendance, s.attendance)
+	copy(clone.busy, s.busy)
+	copy(clone.rats, s.rats)
+	clone.value = s.value
+
+	for ratIndex := range s.events {
+		clone.events[ratIndex] = append(clone.events[ratIndex][:0], s.events[ratIndex]...)
 	}
 
 	return clone
@@ -266,27 +496,29 @@ func (s *Solution) Clone() (clone *Solution) {
 func (s *Solution) HasViolations(event int) bool {
 	if event > s.inst.nEvents {
 		panic("Solution.HasConflicts: event > nEvents")
+	} else if s.inst.Format() != PostEnrolment {
+		return s.quality(event).Violations > 0
 	}
 
 	time := s.rats[event].Time
 
-	for student := range s.inst.events[event].students {
-		if len(s.attendance[student][time]) >= 2 {
+	for _, student := range s.inst.events[event].students {
+		if s.nEventsAt(student, time) >= 2 {
 			return true
 		}
 	}
 
-	if len(s.events[s.rats[event].index()]) > 1 {
+	if len(s.events[s.inst.ratIndex(s.rats[event])]) > 1 {
 		return true
 	}
 
-	for after := range s.inst.events[event].after {
+	for _, after := range s.inst.events[event].after {
 		if s.rats[after].Time <= s.rats[event].Time {
 			return true
 		}
 	}
 
-	for before := range s.inst.events[event].before {
+	for _, before := range s.inst.events[event].before {
 		if s.rats[before].Time >= s.rats[event].Time {
 			return true
 		}
@@ -295,61 +527,80 @@ func (s *Solution) HasViolations(event int) bool {
 	return false
 }
 
-// Find an event that can be improved, randomly. If one cannot be found, then
-// the return value is an event of -1 and an unassigned Rat.
-func (s *Solution) FindImprovement() (event int, rat Rat) {
-	for _, event = range rand.Perm(s.inst.nEvents) {
-		rat = s.improve(event)
-
-		if rat != badRat {
-			return
+// Find a move that improves the solution by examining events in a random
+// order. If one cannot be found, then the return value is nil.
+func (s *Solution) FindImprovement(rng *rand.Rand) Move {
+	for _, event := range rng.Perm(s.inst.nEvents) {
+		if move := s.improve(event); move != nil {
+			return move
 		}
 	}
-	event = -1
-	return
+
+	return nil
 }
 
-// Determine the best assignment for the given event if one exists. In the
-// even that one cannot be found, an unassigned Rat is returned.
-func (s *Solution) improve(event int) Rat {
+// Determine the best move involving the given event if one exists. The moves
+// considered are reassigning the event to any Rat in its domain, swapping it
+// with the events it conflicts with, and Kempe chain interchanges between its
+// time and every other time. In the event that no move improves the solution,
+// nil is returned.
+func (s *Solution) improve(event int) (bestMove Move) {
 	if event > s.inst.nEvents {
 		panic("Solution.Improve : event > nEvents")
 	}
 
-	if s.HasViolations(event) {
-		bestValue := s.Value()
-		originalRat := s.rats[event]
-		bestRat := originalRat
-
-		for _, rat := range s.Domains[event] {
-			s.Assign(event, rat)
-			value := s.Value()
+	if !s.rats[event].Assigned() || !s.HasViolations(event) {
+		return nil
+	}
 
-			if value.Less(bestValue) {
+	bestValue := s.value
+	consider := func(move Move) {
+		if s.CanApply(move) {
+			if value := s.MoveValue(move); value.Less(bestValue) {
 				bestValue = value
-				bestRat = rat
+				bestMove = move
 			}
 		}
+	}
 
-		s.Assign(event, originalRat)
-
-		// If we cannot find something better than the current assignment, we
-		if bestRat != s.rats[event] {
-			return bestRat
+	for _, rat := range s.Domains[event] {
+		if rat != s.rats[event] {
+			consider(s.AssignMove(event, rat))
 		}
 	}
 
-	return badRat
+	// The events are considered in order so that ties between equally good
+	// moves are always broken the same way.
+	for _, other := range s.inst.events[event].exclude {
+		consider(s.SwapMove(event, other))
+	}
+
+	for _, other := range sorted(s.events[s.inst.ratIndex(s.rats[event])]) {
+		consider(s.SwapMove(event, other))
+	}
+
+	for time := 0; time < s.inst.nTimes; time++ {
+		consider(s.KempeMove(event, time))
+	}
+
+	return
 }
 
 // Compute the distance to feasibility of a solution. The distance to
 // to feasibility is defined as the sum of the number of students who attend
-// unscheduled classes.
+// unscheduled classes. For curriculum-based instances, these are the students
+// enrolled in the course of each unscheduled lecture.
 func (s *Solution) Distance() (dist int) {
 	dist = 0
 
 	for event, rat := range s.rats {
-		if !rat.Assigned() {
+		if rat.Assigned() {
+			continue
+		}
+
+		if s.inst.ctt != nil {
+			dist += s.inst.ctt.courses[s.inst.events[event].course].students
+		} else {
 			dist += len(s.inst.events[event].students)
 		}
 	}
@@ -358,46 +609,19 @@ func (s *Solution) Distance() (dist int) {
 }
 
 // Compute the fitness of the solution.
-// The fitness is defined to be the sum of the following:
-//   1. for each student, the number of days s/he has only one class;
-//   2. for each student, if that student has one or more periods of more than
-//      two consecutive classes on that day then for each period the number of
-//      consecutive classes greater than two; and
-//   3. for each student, the number of days s/he has a class in the last
-//      period of the day.
-func (s *Solution) Fitness() (fit int) {
-	fit = 0
-
-	for student := range s.attendance {
-		// There are 5 days of 9 hours each.
-		for day := 0; day < 5; day++ {
-			consecutive := 0
-			count := 0
-
-			for hour := 0; hour < 9; hour++ {
-				if len(s.attendance[student][day*9+hour]) > 0 {
-					count++
-					consecutive++
-
-					if consecutive > 2 {
-						fit++
-					}
-				} else {
-					consecutive = 0
-				}
-			}
-
-			if count == 1 {
-				fit++
-			}
-
-			if len(s.attendance[student][day*9+8]) > 0 {
-				fit++
-			}
-		}
-	}
-
-	return
+// For post-enrolment instances, the fitness is the weighted sum of the
+// penalties of the instance's soft constraints (see SoftConstraint). By
+// default, these are the ITC2007 constraints, each with a weight of one:
+//  1. for each student, the number of days s/he has only one class;
+//  2. for each student, if that student has one or more periods of more than
+//     two consecutive classes on that day then for each period the number of
+//     consecutive classes greater than two; and
+//  3. for each student, the number of days s/he has a class in the last
+//     period of the day.
+//
+// The fitness is maintained by Assign, so this does not scan the solution.
+func (s *Solution) Fitness() int {
+	return s.value.Fitness
 }
 
 // Free the solution back to the object pool.
@@ -408,19 +632,20 @@ func (s *Solution) Free() {
 			s.rats[event] = badRat
 		}
 
+		s.value = s.inst.empty
+
+		// The events of each room and time keep their memory for reuse.
 		for ratIndex := range s.events {
-			for event := range s.events[ratIndex] {
-				delete(s.events[ratIndex], event)
-			}
+			s.events[ratIndex] = s.events[ratIndex][:0]
 		}
 
 		// Reset the attendance matrix
-		for student := range s.attendance {
-			for time := range s.attendance[student] {
-				for event := range s.attendance[student][time] {
-					delete(s.attendance[student][time], event)
-				}
-			}
+		for index := range s.attendance {
+			s.attendance[index] = 0
+		}
+
+		for word := range s.busy {
+			s.busy[word] = 0
 		}
 
 		s.inst.solnPool.Put(s)
@@ -460,60 +685,35 @@ func (s *Solution) RatAt(eventIndex int) Rat {
 
 // Determine the value of the solution (ie. the distance and fitness).
 func (s *Solution) Value() Value {
-	return Value{s.Violations(), s.Fitness()}
+	return s.value
 }
 
-// Determine the number of hard constraint violations in the solution.
-func (s *Solution) Violations() (violations int) {
-	violations = 0
-
-	// We consider the number of students that must attend a multiple events
-	// at once. In this case, the penality is the number of events that each
-	// student must attend more than one in each time slot.
-	for student := range s.attendance {
-		for time := range s.attendance[student] {
-			if nEvents := len(s.attendance[student][time]); nEvents >= 2 {
-				violations += nEvents - 1
-			}
-		}
-	}
-
-	// We consider the number of events assigned to each Rat. If there are
-	// multiple events assigned to a single Rat, then the penalty is the
-	// number of pairs of conflicting events.
-	for ratIndex := range s.events {
-		if nEvents := len(s.events[ratIndex]); nEvents >= 2 {
-			// n choose 2 = 1 + 2 + ... + n-1 = n(n-1)/2, for n >=2
-			violations += (nEvents * (nEvents - 1)) / 2
-		}
-	}
-
-	// We consider the order of events. We only consider the `after' relation
-	// as  A `after` B is equivalent to B `before` A. If there is an event
-	// that is supposed to occur after another that is not scheduled as such,
-	// the penality is 1 per such event.
-	for eventIndex := range s.rats {
-		event := &s.inst.events[eventIndex]
-		if rat := s.rats[eventIndex]; rat.Assigned() {
-			for otherIndex := range event.after {
-				if other := s.rats[otherIndex]; other.Assigned() && !other.After(rat) {
-
-					violations++
-				}
-			}
-		}
-	}
-
-	// We do not have to check if events are scheduled in invalid timeslots or
-	// rooms (e.g., that are too small or do not contain appropriate features)
-	// as the domain generation at the beginning removes that possibility.
-	return
-
+// Determine the number of hard constraint violations in the solution. The
+// violations are made up of the following:
+//  1. for each student and time, the number of events the student attends at
+//     that time beyond the first;
+//  2. for each room and time, the number of pairs of events assigned to it;
+//     and
+//  3. the number of pairs of events that are not ordered as required.
+//
+// We do not have to check if events are scheduled in invalid timeslots or
+// rooms (e.g., that are too small or do not contain appropriate features) as
+// the domain generation at the beginning removes that possibility.
+//
+// The violations are maintained by Assign, so this does not scan the
+// solution.
+func (s *Solution) Violations() int {
+	return s.value.Violations
 }
 
 // Write the solution to the given writer.
 func (s *Solution) Write(w io.Writer) {
+	if s.inst.ctt != nil {
+		s.writeCtt(w)
+		return
+	}
+
 	for _, rat := range s.rats {
-		fmt.Fprintf(w, "%d %d\n", rat.Time, rat.Room)
+		fmt.Fprintf(w, s.inst.solutionFormat(), rat.Time, rat.Room)
 	}
 }
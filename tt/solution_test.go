@@ -0,0 +1,164 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"testing"
+)
+
+func TestSolutionValues(t *testing.T) {
+	inst := tinyInstance(t)
+
+	// The values of these solutions to the tiny instance are worked out by
+	// hand. Times 0 to 2 are on the first day and 3 to 5 on the second, and
+	// times 2 and 5 are the last periods.
+	tests := []struct {
+		name       string
+		rats       []Rat
+		violations int
+		fitness    int
+		distance   int
+	}{
+		// Student 0 has three classes in a row ending in the last period,
+		// student 1 has a class in the last period, and student 2 has a
+		// single class on the first day.
+		{"feasible", []Rat{{0, 0}, {0, 1}, {1, 2}, {0, 2}}, 0, 4, 0},
+
+		// Events 0 and 1 share student 0 and room 0 at time 2. Every student
+		// has a single class on the first day, in its last period. Events 2
+		// and 3 each have one student.
+		{"clashes", []Rat{{0, 2}, {0, 2}, badRat, badRat}, 2, 6, 2},
+
+		// Event 0 is after event 2. Students 0 and 1 each have a single class
+		// on the first day, student 0 has a class in the last period of the
+		// second day, student 1 has a single class on the second day, and so
+		// does student 2.
+		{"precedence", []Rat{{0, 4}, {0, 0}, {1, 3}, {1, 5}}, 1, 5, 0},
+
+		{"empty", []Rat{badRat, badRat, badRat, badRat}, 0, 0, 6},
+	}
+
+	for _, test := range tests {
+		s := inst.SolutionFromRats(test.rats)
+
+		if got := s.Violations(); got != test.violations {
+			t.Errorf("%s: got %d violations; want %d", test.name, got, test.violations)
+		}
+
+		if got := s.Fitness(); got != test.fitness {
+			t.Errorf("%s: got fitness %d; want %d", test.name, got, test.fitness)
+		}
+
+		if got := s.Distance(); got != test.distance {
+			t.Errorf("%s: got distance %d; want %d", test.name, got, test.distance)
+		}
+
+		// The report scans the whole solution instead of maintaining the
+		// value as events are assigned.
+		if got := s.Report().Value; got != s.Value() {
+			t.Errorf("%s: the report has value %s; want %s", test.name, got, s.Value())
+		}
+
+		// Unassigning and reassigning every event must give the same value.
+		clone := s.Clone()
+		for event, rat := range test.rats {
+			clone.Unassign(event)
+			if rat.Assigned() {
+				clone.Assign(event, rat)
+			}
+		}
+
+		if clone.Value() != s.Value() {
+			t.Errorf("%s: got value %s after reassigning every event; want %s", test.name, clone.Value(), s.Value())
+		}
+
+		clone.Free()
+		s.Free()
+	}
+}
+
+func TestCloneAndFree(t *testing.T) {
+	inst := tinyInstance(t)
+	s := inst.SolutionFromRats([]Rat{{0, 2}, {0, 2}, {1, 3}, badRat})
+
+	clone := s.Clone()
+	if clone.Value() != s.Value() {
+		t.Errorf("the clone has value %s; want %s", clone.Value(), s.Value())
+	}
+
+	// Changing the clone must not change the original.
+	clone.Assign(1, Rat{0, 0})
+	if s.RatAt(1) != (Rat{0, 2}) || s.Violations() != 2 {
+		t.Errorf("changing the clone changed the original")
+	}
+
+	// A freed solution is reused by the pool, so it must be empty.
+	clone.Free()
+	s.Free()
+
+	for i := 0; i < 2; i++ {
+		s = inst.NewSolution()
+		if s.Value() != (Value{0, 0}) || s.Distance() != 6 {
+			t.Errorf("a new solution has value %s and distance %d; want (0, 0) and 6", s.Value(), s.Distance())
+		}
+
+		for event := 0; event < s.NEvents(); event++ {
+			if s.Assigned(event) {
+				t.Errorf("event %d of a new solution is assigned", event)
+			}
+		}
+
+		defer s.Free()
+	}
+}
+
+func TestAssignAndShrink(t *testing.T) {
+	inst := tinyInstance(t)
+	s := inst.NewSolution()
+	defer s.Free()
+
+	domains := s.MakeShrinkableDomains()
+	s.AssignAndShrink(0, Rat{0, 1}, domains)
+
+	// No other event can have room 0 at time 1, events 1 and 3 share a
+	// student with event 0 so they cannot be at time 1 at all, and event 2
+	// must be after event 0.
+	want := [][]Rat{
+		{{0, 0}, {0, 2}, {0, 3}, {0, 4}, {0, 5}},
+		{{0, 0}, {0, 2}, {0, 3}, {0, 4}, {0, 5}},
+		{{0, 2}, {0, 3}, {0, 4}, {0, 5}, {1, 2}, {1, 3}, {1, 4}, {1, 5}},
+		{{0, 0}, {0, 2}, {0, 3}, {0, 4}, {0, 5}, {1, 0}, {1, 2}, {1, 3}, {1, 4}, {1, 5}},
+	}
+
+	for event := range want {
+		if len(domains[event]) != len(want[event]) {
+			t.Errorf("event %d has %d values in its domain; want %d", event, len(domains[event]), len(want[event]))
+		}
+
+		for _, rat := range want[event] {
+			if !domains[event][rat] {
+				t.Errorf("event %d lost %v from its domain", event, rat)
+			}
+		}
+	}
+
+	// The master copy of the domains is left alone.
+	if len(inst.Domains[1]) != 6 {
+		t.Errorf("the master domain of event 1 has %d values; want 6", len(inst.Domains[1]))
+	}
+}
@@ -0,0 +1,375 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A summary of a distribution of counts.
+type Distribution struct {
+	Min    int     `json:"min"`    // The smallest count.
+	Q1     int     `json:"q1"`     // The first quartile.
+	Median int     `json:"median"` // The median.
+	Q3     int     `json:"q3"`     // The third quartile.
+	Max    int     `json:"max"`    // The largest count.
+	Mean   float64 `json:"mean"`   // The mean count.
+}
+
+// The load on a set of rooms from the events that can only be held in those
+// rooms. Each event is one unit of load and each room can hold one unit at
+// each time, except in examination instances, where rooms are shared: there,
+// each student is one unit of load and each room can hold its capacity at each
+// time.
+type RoomLoad struct {
+	Rooms    []int `json:"rooms"`    // The rooms, in increasing order.
+	NEvents  int   `json:"events"`   // The number of events that can only be held in the rooms.
+	Load     int   `json:"load"`     // The load of those events.
+	Capacity int   `json:"capacity"` // The load the rooms can hold over the whole timetable.
+}
+
+// Determine the share of the rooms' capacity that must be used, which is a
+// lower bound on their utilisation. A share above 1 means that the events do
+// not fit in the rooms.
+func (l RoomLoad) Utilisation() float64 {
+	if l.Capacity == 0 {
+		return 0
+	}
+
+	return float64(l.Load) / float64(l.Capacity)
+}
+
+// Statistics of an instance that hint at how hard it is to solve.
+type Stats struct {
+	Format    Format `json:"format"`   // The format of the instance.
+	NEvents   int    `json:"events"`   // The number of events.
+	NRooms    int    `json:"rooms"`    // The number of rooms.
+	NTimes    int    `json:"times"`    // The number of times.
+	NStudents int    `json:"students"` // The number of students (curricula, for curriculum-based instances).
+
+	DomainSizes      Distribution `json:"domain_sizes"`      // The number of rooms and times each event can have.
+	EmptyDomains     []int        `json:"empty_domains"`     // The events that cannot be assigned at all.
+	SingletonDomains []int        `json:"singleton_domains"` // The events with a single room and time.
+
+	// The conflict graph has an edge between each pair of events that cannot
+	// be at the same time.
+	Conflicts int          `json:"conflicts"` // The number of edges of the conflict graph.
+	Density   float64      `json:"density"`   // The share of the pairs of events that are edges.
+	Degrees   Distribution `json:"degrees"`   // The number of edges of each event.
+	Clique    []int        `json:"clique"`    // The largest clique found, in increasing order.
+
+	PrecedencePairs int   `json:"precedence_pairs"` // The number of pairs of events that must be in order.
+	LongestChain    int   `json:"longest_chain"`    // The number of events in the longest chain of ordered events.
+	Cyclic          []int `json:"cyclic"`           // The events on or after a cycle of ordered events.
+
+	Utilisation   RoomLoad `json:"utilisation"`    // The load on every room.
+	TightestRooms RoomLoad `json:"tightest_rooms"` // The set of rooms with the largest share of its capacity used.
+}
+
+// Compute the statistics of the instance.
+func (inst *Instance) Stats() *Stats {
+	stats := &Stats{
+		Format:           inst.Format(),
+		NEvents:          inst.nEvents,
+		NRooms:           inst.nRooms,
+		NTimes:           inst.nTimes,
+		NStudents:        inst.nStudents,
+		EmptyDomains:     []int{},
+		SingletonDomains: []int{},
+	}
+
+	sizes := make([]int, inst.nEvents)
+	for event, domain := range inst.Domains {
+		sizes[event] = len(domain)
+
+		switch len(domain) {
+		case 0:
+			stats.EmptyDomains = append(stats.EmptyDomains, event)
+
+		case 1:
+			stats.SingletonDomains = append(stats.SingletonDomains, event)
+		}
+	}
+	stats.DomainSizes = distribution(sizes)
+
+	conflicts := inst.conflicts()
+	degrees := make([]int, inst.nEvents)
+	for event := range conflicts {
+		degrees[event] = len(conflicts[event])
+		stats.Conflicts += len(conflicts[event])
+	}
+	stats.Conflicts /= 2
+	stats.Degrees = distribution(degrees)
+
+	if inst.nEvents > 1 {
+		stats.Density = float64(2*stats.Conflicts) / float64(inst.nEvents*(inst.nEvents-1))
+	}
+
+	stats.Clique = largestClique(conflicts)
+
+	for event := range inst.events {
+		stats.PrecedencePairs += len(inst.events[event].after)
+	}
+
+	var chains []int
+	chains, stats.Cyclic = inst.precedenceChains()
+	for _, length := range chains {
+		if length > stats.LongestChain {
+			stats.LongestChain = length
+		}
+	}
+
+	stats.Utilisation, stats.TightestRooms = inst.roomLoads()
+
+	return stats
+}
+
+// Summarize the distribution of the counts.
+func distribution(counts []int) (d Distribution) {
+	if len(counts) == 0 {
+		return
+	}
+
+	counts = sorted(counts)
+	last := len(counts) - 1
+
+	d.Min = counts[0]
+	d.Q1 = counts[last/4]
+	d.Median = counts[last/2]
+	d.Q3 = counts[3*last/4]
+	d.Max = counts[last]
+
+	sum := 0
+	for _, count := range counts {
+		sum += count
+	}
+	d.Mean = float64(sum) / float64(len(counts))
+
+	return
+}
+
+// Determine the events that each event cannot be at the same time as, in
+// increasing order.
+func (inst *Instance) conflicts() [][]int {
+	conflicts := make([][]int, inst.nEvents)
+
+	for event := range inst.events {
+		e := &inst.events[event]
+
+		if len(e.separate) == 0 {
+			conflicts[event] = e.exclude
+		} else {
+			conflicts[event] = distinct(append(append([]int(nil), e.exclude...), e.separate...))
+		}
+	}
+
+	return conflicts
+}
+
+// Find a large clique of the graph with the given adjacency lists, in
+// increasing order. Finding the largest clique is NP-hard, so a clique is grown
+// greedily from each vertex, trying its neighbours with the most edges first.
+// The size of any clique is a lower bound on the number of colours, i.e.,
+// times, that the graph needs.
+func largestClique(adjacent [][]int) (best []int) {
+	n := len(adjacent)
+	words := (n + 63) / 64
+
+	// The adjacency matrix as bitsets, for checking edges quickly.
+	matrix := make([]uint64, n*words)
+	for vertex := range adjacent {
+		for _, other := range adjacent[vertex] {
+			matrix[vertex*words+other/64] |= 1 << uint(other%64)
+		}
+	}
+
+	byDegree := func(vertices []int) []int {
+		vertices = append([]int(nil), vertices...)
+		sort.SliceStable(vertices, func(i, j int) bool {
+			return len(adjacent[vertices[i]]) > len(adjacent[vertices[j]])
+		})
+
+		return vertices
+	}
+
+	for vertex := range adjacent {
+		// A vertex cannot be in a clique larger than its degree plus one.
+		if len(adjacent[vertex])+1 <= len(best) {
+			continue
+		}
+
+		clique := []int{vertex}
+		for _, candidate := range byDegree(adjacent[vertex]) {
+			inClique := true
+			for _, member := range clique {
+				if matrix[candidate*words+member/64]&(1<<uint(member%64)) == 0 {
+					inClique = false
+					break
+				}
+			}
+
+			if inClique {
+				clique = append(clique, candidate)
+			}
+		}
+
+		if len(clique) > len(best) {
+			best = clique
+		}
+	}
+
+	return sorted(best)
+}
+
+// Determine the number of events in the longest chain of ordered events that
+// ends with each event. Events on a cycle of ordered events, or after one, have
+// no such chain; they are returned in increasing order and their chains have
+// length zero.
+func (inst *Instance) precedenceChains() (chains []int, cyclic []int) {
+	chains = make([]int, inst.nEvents)
+	cyclic = []int{}
+
+	// Visit the events in a topological order, starting from those that no
+	// event must come before.
+	nBefore := make([]int, inst.nEvents)
+	var queue []int
+	for event := range inst.events {
+		if nBefore[event] = len(inst.events[event].before); nBefore[event] == 0 {
+			queue = append(queue, event)
+			chains[event] = 1
+		}
+	}
+
+	for len(queue) > 0 {
+		event := queue[0]
+		queue = queue[1:]
+
+		for _, next := range inst.events[event].after {
+			if chains[event]+1 > chains[next] {
+				chains[next] = chains[event] + 1
+			}
+
+			if nBefore[next]--; nBefore[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for event := range inst.events {
+		if nBefore[event] > 0 {
+			chains[event] = 0
+			cyclic = append(cyclic, event)
+		}
+	}
+
+	return
+}
+
+// Determine the load of each room and each event, as described by RoomLoad.
+func (inst *Instance) loadUnits() (roomUnits, eventUnits []int) {
+	roomUnits = make([]int, inst.nRooms)
+	eventUnits = make([]int, inst.nEvents)
+
+	for room := range roomUnits {
+		if inst.exam != nil {
+			roomUnits[room] = inst.rooms[room].capacity * inst.nTimes
+		} else {
+			roomUnits[room] = inst.nTimes
+		}
+	}
+
+	for event := range eventUnits {
+		if inst.exam != nil {
+			eventUnits[event] = len(inst.events[event].students)
+		} else {
+			eventUnits[event] = 1
+		}
+	}
+
+	return
+}
+
+// Determine the load on every room, and the set of rooms with the largest
+// share of its capacity used. Only the sets of rooms that some event can be
+// held in are considered.
+func (inst *Instance) roomLoads() (all, tightest RoomLoad) {
+	roomUnits, eventUnits := inst.loadUnits()
+	words := (inst.nRooms + 63) / 64
+
+	// The rooms each event can be held in, as a bitset.
+	sets := make([][]uint64, inst.nEvents)
+	for event := range inst.events {
+		sets[event] = make([]uint64, words)
+		for room := range inst.events[event].rooms {
+			sets[event][room/64] |= 1 << uint(room%64)
+		}
+	}
+
+	subset := func(a, b []uint64) bool {
+		for word := range a {
+			if a[word]&^b[word] != 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	load := func(set []uint64) (l RoomLoad) {
+		l.Rooms = []int{}
+		for room := 0; room < inst.nRooms; room++ {
+			if set[room/64]&(1<<uint(room%64)) != 0 {
+				l.Rooms = append(l.Rooms, room)
+				l.Capacity += roomUnits[room]
+			}
+		}
+
+		for event := range inst.events {
+			if len(inst.events[event].rooms) > 0 && subset(sets[event], set) {
+				l.NEvents++
+				l.Load += eventUnits[event]
+			}
+		}
+
+		return
+	}
+
+	every := make([]uint64, words)
+	for room := 0; room < inst.nRooms; room++ {
+		every[room/64] |= 1 << uint(room%64)
+	}
+
+	all = load(every)
+	tightest = all
+
+	seen := make(map[string]bool)
+	for event := range inst.events {
+		key := fmt.Sprint(sets[event])
+		if len(inst.events[event].rooms) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if l := load(sets[event]); l.Utilisation() > tightest.Utilisation() {
+			tightest = l
+		}
+	}
+
+	return
+}
@@ -0,0 +1,94 @@
+// spaghetti: Applying Hierarchical Parallel Genetic Algorithms to solve the
+// University Timetabling Problem.
+// Copyright (C) 2014  Barret Rennie
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats := tinyInstance(t).Stats()
+
+	// Events 0 and 1 only fit in room 0, so they have 6 values each, and
+	// events 2 and 3 have 12.
+	if want := (Distribution{6, 6, 6, 12, 12, 9}); stats.DomainSizes != want {
+		t.Errorf("got domain sizes %+v; want %+v", stats.DomainSizes, want)
+	}
+
+	if len(stats.EmptyDomains) != 0 || len(stats.SingletonDomains) != 0 {
+		t.Errorf("got empty domains %v and singleton domains %v; want none", stats.EmptyDomains, stats.SingletonDomains)
+	}
+
+	// Student 0 attends events 0, 1, and 3, which form the largest clique,
+	// and student 1 attends events 1 and 2.
+	if stats.Conflicts != 4 || stats.Density != 4.0/6.0 {
+		t.Errorf("got %d conflicts with density %g; want 4 with density %g", stats.Conflicts, stats.Density, 4.0/6.0)
+	}
+
+	if got := fmt.Sprint(stats.Clique); got != "[0 1 3]" {
+		t.Errorf("got the clique %s; want [0 1 3]", got)
+	}
+
+	if stats.PrecedencePairs != 1 || stats.LongestChain != 2 || len(stats.Cyclic) != 0 {
+		t.Errorf("got %d ordered pairs, a longest chain of %d, and cyclic events %v; want 1, 2, and none", stats.PrecedencePairs, stats.LongestChain, stats.Cyclic)
+	}
+
+	// Every event needs one of the 12 rooms and times, and events 0 and 1
+	// need two of the 6 in room 0, which is the same share.
+	if stats.Utilisation.Load != 4 || stats.Utilisation.Capacity != 12 {
+		t.Errorf("got a load of %d of %d on every room; want 4 of 12", stats.Utilisation.Load, stats.Utilisation.Capacity)
+	}
+
+	if got := stats.TightestRooms.Utilisation(); got != 1.0/3.0 {
+		t.Errorf("got a utilisation of %g for the tightest rooms; want %g", got, 1.0/3.0)
+	}
+}
+
+func TestStatsCycle(t *testing.T) {
+	// Event 2 must also be before event 0.
+	lines := tinyLines()
+	lines[tinyPrecedence+2*4] = "1"
+
+	inst, err := ParseWithWeek(strings.NewReader(joinLines(lines)), tinyWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := inst.Stats()
+	if got := fmt.Sprint(stats.Cyclic); got != "[0 2]" {
+		t.Errorf("got the cyclic events %s; want [0 2]", got)
+	}
+
+	if stats.LongestChain != 1 {
+		t.Errorf("got a longest chain of %d; want 1", stats.LongestChain)
+	}
+}
+
+func TestLargestClique(t *testing.T) {
+	// Two triangles joined by an edge, and a square with both diagonals.
+	adjacent := [][]int{
+		{1, 2}, {0, 2}, {0, 1, 3}, {2, 4, 5}, {3, 5}, {3, 4},
+		{7, 8, 9}, {6, 8, 9}, {6, 7, 9}, {6, 7, 8},
+	}
+
+	if got := fmt.Sprint(largestClique(adjacent)); got != "[6 7 8 9]" {
+		t.Errorf("got the clique %s; want [6 7 8 9]", got)
+	}
+}
@@ -18,8 +18,57 @@
 // The timetabling package.
 package tt
 
-// The number of available time slots.
-const NTimes = 45
+import (
+	"fmt"
+	"sort"
+)
+
+// The shape of a week of a post-enrolment or curriculum-based instance. Times
+// are numbered day by day, so time t is period t % Periods of day t / Periods.
+type Week struct {
+	Days    int // The number of days in a week.
+	Periods int // The number of periods in a day.
+}
+
+// The week of the ITC2007 post-enrolment track: 5 days of 9 periods.
+var StandardWeek = Week{5, 9}
+
+// Determine the number of times in the week.
+func (w Week) NTimes() int {
+	return w.Days * w.Periods
+}
+
+// Determine the day of the given time.
+func (w Week) day(time int) int {
+	return time / w.Periods
+}
+
+// Describe the week, e.g., "5x9" for 5 days of 9 periods.
+func (w Week) String() string {
+	return fmt.Sprintf("%dx%d", w.Days, w.Periods)
+}
 
 // The unassigned room and time.
 var badRat = Rat{-1, -1}
+
+// Sort the values in place and remove their duplicates.
+func distinct(values []int) []int {
+	sort.Ints(values)
+
+	n := 0
+	for i, value := range values {
+		if i == 0 || value != values[n-1] {
+			values[n] = value
+			n++
+		}
+	}
+
+	return values[:n]
+}
+
+// Make a copy of a list of events in increasing order.
+func sorted(events []int) []int {
+	events = append([]int(nil), events...)
+	sort.Ints(events)
+	return events
+}
@@ -24,8 +24,8 @@ import (
 
 // A solution valuation.
 type Value struct {
-	Violations int // The number of hard constraint violations.
-	Fitness    int // The solution fitness.
+	Violations int `json:"violations"` // The number of hard constraint violations.
+	Fitness    int `json:"fitness"`    // The solution fitness.
 }
 
 // Determine if the value corresponds to an ideal solution.
@@ -38,6 +38,12 @@ func (v Value) IsValid() bool {
 	return v.Violations == 0
 }
 
+// Add another value to the value.
+func (v *Value) add(u Value) {
+	v.Violations += u.Violations
+	v.Fitness += u.Fitness
+}
+
 // Compare two values using a lexicographical compare.
 func (v Value) Less(u Value) bool {
 	switch {